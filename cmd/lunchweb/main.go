@@ -0,0 +1,8 @@
+// Command lunchweb helps us order lunch, see the README or lunchweb -h.
+package main
+
+import "github.com/datacamp/lunchweb/web"
+
+func main() {
+	web.Main()
+}
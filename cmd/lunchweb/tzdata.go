@@ -0,0 +1,8 @@
+//go:build tzdata
+
+package main
+
+// Embed the time zone database so -tz works on systems without
+// /usr/share/zoneinfo, such as scratch or distroless containers. Build with
+// "go build -tags tzdata".
+import _ "time/tzdata"
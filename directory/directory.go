@@ -0,0 +1,398 @@
+// Package directory lists the members of a group in the company directory,
+// Google Workspace, Microsoft Entra ID (Azure AD) or an LDAP server, as the
+// people expected to order lunch.
+//
+//	d := &directory.Azure{Tenant: tenant, Group: groupID, ClientID: id, ClientSecret: secret}
+//	members, err := d.Members(ctx)
+package directory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Member is a person in the directory
+type Member struct {
+	Name  string
+	Email string
+}
+
+// Source lists the members of a group. Suspended or disabled accounts are
+// left out.
+type Source interface {
+	// Name identifies the directory in logs
+	Name() string
+	Members(ctx context.Context) ([]Member, error)
+}
+
+// Google lists the members of a Google Workspace group, including those of
+// nested groups, with the Admin SDK Directory API. The service account
+// needs domain-wide delegation of the admin.directory.group.member.readonly
+// and admin.directory.user.readonly scopes.
+type Google struct {
+	// Group is the email address of the group
+	Group string
+	// Key is the JSON key file of the service account
+	Key string
+	// Admin is the email address of an administrator the service account
+	// acts as
+	Admin string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (g *Google) Name() string { return "google" }
+
+const googleAPI = "https://admin.googleapis.com/admin/directory/v1"
+
+func (g *Google) Members(ctx context.Context) ([]Member, error) {
+	token, err := g.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("google: %v", err)
+	}
+	var members []Member
+	pageToken := ""
+	for {
+		var page struct {
+			Members []struct {
+				Email  string `json:"email"`
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"members"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		query := url.Values{"includeDerivedMembership": {"true"}, "maxResults": {"200"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := googleAPI + "/groups/" + url.PathEscape(g.Group) + "/members?" + query.Encode()
+		if err := getJSON(ctx, g.Client, u, token, nil, &page); err != nil {
+			return nil, fmt.Errorf("google: %v", err)
+		}
+		for _, m := range page.Members {
+			// nested groups are listed too, their members separately
+			if m.Type != "USER" || (m.Status != "" && m.Status != "ACTIVE") {
+				continue
+			}
+			var user struct {
+				PrimaryEmail string `json:"primaryEmail"`
+				Suspended    bool   `json:"suspended"`
+				Name         struct {
+					FullName string `json:"fullName"`
+				} `json:"name"`
+			}
+			u := googleAPI + "/users/" + url.PathEscape(m.Email) + "?fields=primaryEmail,suspended,name/fullName"
+			if err := getJSON(ctx, g.Client, u, token, nil, &user); err != nil {
+				return nil, fmt.Errorf("google: %v", err)
+			}
+			if user.Suspended {
+				continue
+			}
+			members = append(members, Member{Name: user.Name.FullName, Email: user.PrimaryEmail})
+		}
+		if page.NextPageToken == "" {
+			return members, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// token signs in as the service account, acting as the admin
+func (g *Google) token(ctx context.Context) (string, error) {
+	b, err := os.ReadFile(g.Key)
+	if err != nil {
+		return "", err
+	}
+	var key struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return "", fmt.Errorf("%s: %v", g.Key, err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("%s: no private key", g.Key)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", g.Key, err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("%s: not an RSA key", g.Key)
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"sub":   g.Admin,
+		"scope": "https://www.googleapis.com/auth/admin.directory.group.member.readonly https://www.googleapis.com/auth/admin.directory.user.readonly",
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return fetchToken(ctx, g.Client, key.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+}
+
+// Azure lists the members of a Microsoft Entra ID (Azure AD) group,
+// including those of nested groups, with Microsoft Graph. The app
+// registration needs the GroupMember.Read.All and User.Read.All application
+// permissions.
+type Azure struct {
+	// Tenant is the directory (tenant) ID or domain
+	Tenant string
+	// Group is the object ID of the group
+	Group        string
+	ClientID     string
+	ClientSecret string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (a *Azure) Name() string { return "azure" }
+
+func (a *Azure) Members(ctx context.Context) ([]Member, error) {
+	token, err := fetchToken(ctx, a.Client, "https://login.microsoftonline.com/"+url.PathEscape(a.Tenant)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.ClientID},
+		"client_secret": {a.ClientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: %v", err)
+	}
+	var members []Member
+	next := "https://graph.microsoft.com/v1.0/groups/" + url.PathEscape(a.Group) +
+		"/transitiveMembers/microsoft.graph.user?$select=displayName,mail,userPrincipalName,accountEnabled&$top=999&$count=true"
+	for next != "" {
+		var page struct {
+			Value []struct {
+				DisplayName       string `json:"displayName"`
+				Mail              string `json:"mail"`
+				UserPrincipalName string `json:"userPrincipalName"`
+				AccountEnabled    *bool  `json:"accountEnabled"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		// casting to users is an advanced query
+		header := http.Header{"ConsistencyLevel": {"eventual"}}
+		if err := getJSON(ctx, a.Client, next, token, header, &page); err != nil {
+			return nil, fmt.Errorf("azure: %v", err)
+		}
+		for _, u := range page.Value {
+			if u.AccountEnabled != nil && !*u.AccountEnabled {
+				continue
+			}
+			email := u.Mail
+			if email == "" {
+				email = u.UserPrincipalName
+			}
+			members = append(members, Member{Name: u.DisplayName, Email: email})
+		}
+		next = page.NextLink
+	}
+	return members, nil
+}
+
+// LDAP lists the entries matching a filter on an LDAP server, like Active
+// Directory or OpenLDAP, with the ldapsearch command of the OpenLDAP
+// clients. Their name is the displayName, or the cn without one.
+type LDAP struct {
+	// URL is the server, like ldaps://ldap.example.com
+	URL string
+	// Base is the DN to search under, like ou=people,dc=example,dc=com
+	Base string
+	// Filter selects the people, like (memberOf=cn=lunch,ou=groups,dc=example,dc=com)
+	Filter string
+	// BindDN and Password log in; anonymously without BindDN
+	BindDN   string
+	Password string
+	// Command is the ldapsearch binary, "ldapsearch" when empty
+	Command string
+}
+
+func (l *LDAP) Name() string { return "ldap" }
+
+func (l *LDAP) Members(ctx context.Context) ([]Member, error) {
+	command := l.Command
+	if command == "" {
+		command = "ldapsearch"
+	}
+	args := []string{"-LLL", "-x", "-o", "ldif-wrap=no", "-H", l.URL, "-b", l.Base}
+	if l.BindDN != "" {
+		// -y reads the password from a file, so it isn't in the process list
+		f, err := os.CreateTemp("", "lunchweb-ldap-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(l.Password)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-D", l.BindDN, "-y", f.Name())
+	}
+	filter := l.Filter
+	if filter == "" {
+		filter = "(objectClass=person)"
+	}
+	args = append(args, filter, "displayName", "cn", "mail")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ldap: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ldap: %v", err)
+	}
+	return parseLDIF(&stdout)
+}
+
+// parseLDIF reads the people in the output of ldapsearch, entries of
+// "attribute: value" lines separated by blank lines
+func parseLDIF(r io.Reader) ([]Member, error) {
+	var members []Member
+	entry := make(map[string]string)
+	flush := func() {
+		name := entry["displayname"]
+		if name == "" {
+			name = entry["cn"]
+		}
+		if name != "" {
+			members = append(members, Member{Name: name, Email: entry["mail"]})
+		}
+		entry = make(map[string]string)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		attr, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		attr = strings.ToLower(attr)
+		// "attr:: value" is base64, for values that aren't plain ASCII
+		if encoded, ok := strings.CutPrefix(value, ":"); ok {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("ldap: invalid %s: %v", attr, err)
+			}
+			value = string(decoded)
+		}
+		// the first value of attributes with several
+		if _, seen := entry[attr]; !seen {
+			entry[attr] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return members, scanner.Err()
+}
+
+// fetchToken gets an OAuth access token at tokenURL
+func fetchToken(ctx context.Context, client *http.Client, tokenURL string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := do(client, req, &token); err != nil {
+		if token.ErrorDescription != "" {
+			return "", fmt.Errorf("could not sign in: %s", token.ErrorDescription)
+		}
+		return "", fmt.Errorf("could not sign in: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("could not sign in: no access token")
+	}
+	return token.AccessToken, nil
+}
+
+// getJSON fetches u with the access token and decodes the answer into v
+func getJSON(ctx context.Context, client *http.Client, u, token string, header http.Header, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	return do(client, req, v)
+}
+
+// do sends req and decodes the JSON answer into v, also when the status is
+// an error so the caller can report the details
+func do(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(b, v)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("invalid response: %v", decodeErr)
+	}
+	return nil
+}
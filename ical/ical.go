@@ -0,0 +1,144 @@
+// Package ical reads the all-day events of an iCalendar (.ics) file, like a
+// calendar of company holidays exported from Google Calendar or Outlook.
+// Events with a time of day are skipped, and of recurrence rules only
+// FREQ=YEARLY is understood, as used for fixed holidays.
+//
+//	events, err := ical.Parse(r)
+//	for _, e := range events {
+//		fmt.Println(e.Summary, e.Start, e.End)
+//	}
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is an all-day event
+type Event struct {
+	Summary string
+	// Start is the first day of the event, at midnight UTC
+	Start time.Time
+	// End is the day after the last day, as in iCalendar
+	End time.Time
+	// Yearly is whether the event repeats every year
+	Yearly bool
+	// Free is whether the event leaves the time free, like a birthday, as
+	// opposed to busy, like a vacation
+	Free bool
+}
+
+// Days returns the days (2006-01-02) of the event between from and to
+// (inclusive), repeating yearly events
+func (e *Event) Days(from, to time.Time) []string {
+	var days []string
+	start, end := e.Start, e.End
+	if e.Yearly {
+		// the first repetition that could reach into from
+		years := from.Year() - start.Year() - 1
+		if years > 0 {
+			start, end = start.AddDate(years, 0, 0), end.AddDate(years, 0, 0)
+		}
+	}
+	for !start.After(to) {
+		for day := start; day.Before(end) && !day.After(to); day = day.AddDate(0, 0, 1) {
+			if !day.Before(from) {
+				days = append(days, day.Format("2006-01-02"))
+			}
+		}
+		if !e.Yearly {
+			break
+		}
+		start, end = start.AddDate(1, 0, 0), end.AddDate(1, 0, 0)
+	}
+	return days
+}
+
+const dateLayout = "20060102"
+
+// Parse reads the all-day events of a calendar
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	var e *Event
+	allDay, cancelled, recurring := false, false, false
+	for i, line := range lines {
+		name, params, value := property(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			e = &Event{}
+			allDay, cancelled, recurring = false, false, false
+		case e == nil:
+			// outside of events, e.g. the calendar's name or time zones
+		case name == "END" && value == "VEVENT":
+			if allDay && !cancelled && (!recurring || e.Yearly) {
+				if e.End.IsZero() || !e.End.After(e.Start) {
+					e.End = e.Start.AddDate(0, 0, 1)
+				}
+				events = append(events, *e)
+			}
+			e = nil
+		case name == "DTSTART":
+			// all-day events have dates, VALUE=DATE or not
+			if len(value) != len(dateLayout) && !strings.Contains(params, "VALUE=DATE") {
+				continue
+			}
+			if e.Start, err = time.Parse(dateLayout, value[:min(len(value), len(dateLayout))]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTSTART %q", i+1, value)
+			}
+			allDay = true
+		case name == "DTEND":
+			if len(value) == len(dateLayout) {
+				if e.End, err = time.Parse(dateLayout, value); err != nil {
+					return nil, fmt.Errorf("line %d: invalid DTEND %q", i+1, value)
+				}
+			}
+		case name == "SUMMARY":
+			e.Summary = unescape(value)
+		case name == "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		case name == "TRANSP":
+			e.Free = strings.EqualFold(value, "TRANSPARENT")
+		case name == "RRULE":
+			recurring = true
+			e.Yearly = strings.EqualFold(value, "FREQ=YEARLY")
+		}
+	}
+	return events, nil
+}
+
+// unfold reads the content lines, joining lines continued with a space or
+// tab
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// property splits "DTSTART;VALUE=DATE:20261224" into its name, parameters
+// and value
+func property(line string) (name, params, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	name, params, _ = strings.Cut(head, ";")
+	return strings.ToUpper(name), strings.ToUpper(params), strings.TrimSpace(value)
+}
+
+// unescape undoes the escaping of TEXT values
+func unescape(text string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(text)
+}
@@ -0,0 +1,237 @@
+// Package notify delivers messages about lunch by email, to Slack or to any
+// URL accepting JSON.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a notification for the office
+type Message struct {
+	Subject string
+	Text    string
+	// Chat is Text formatted for chat apps, sent instead by notifiers set up
+	// with ChatFormat; empty to send Text everywhere
+	Chat string
+	// To overrides the default email recipients
+	To []string
+	// Cc and Bcc are copied on emails
+	Cc  []string
+	Bcc []string
+	// Calendar is an iCalendar invitation (METHOD:REQUEST) emailed along with
+	// Text, so mail clients add the event to the recipients' calendars
+	Calendar string
+}
+
+// Notifier delivers messages, e.g. by email or to a chat channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// ChatFormat returns n sending the Chat text of messages that have one
+func ChatFormat(n Notifier) Notifier {
+	return chatFormat{n}
+}
+
+type chatFormat struct {
+	Notifier
+}
+
+func (c chatFormat) Notify(ctx context.Context, msg Message) error {
+	if msg.Chat != "" {
+		msg.Text = msg.Chat
+	}
+	return c.Notifier.Notify(ctx, msg)
+}
+
+// Email sends messages over SMTP
+type Email struct {
+	// Addr is the SMTP server, host:port
+	Addr string
+	// User and Password authenticate when User is set
+	User     string
+	Password string
+	From     string
+	// To receives messages without recipients of their own
+	To []string
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Notify(ctx context.Context, msg Message) error {
+	to := msg.To
+	if len(to) == 0 {
+		to = e.To
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", e.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	if len(msg.Cc) > 0 {
+		fmt.Fprintf(&body, "Cc: %s\r\n", strings.Join(msg.Cc, ", "))
+	}
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	if msg.Calendar == "" {
+		body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		body.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&body, msg.Text)
+	} else {
+		// Outlook and Gmail only offer to add the event as an alternative
+		// to the text, not as an attachment
+		parts := multipart.NewWriter(&body)
+		fmt.Fprintf(&body, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+		for _, p := range []struct{ contentType, text string }{
+			{"text/plain; charset=utf-8", msg.Text},
+			{"text/calendar; charset=utf-8; method=REQUEST", msg.Calendar},
+		} {
+			w, err := parts.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {p.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			writeQuotedPrintable(w, p.text)
+		}
+		parts.Close()
+	}
+
+	var auth smtp.Auth
+	if e.User != "" {
+		host, _, _ := net.SplitHostPort(e.Addr)
+		auth = smtp.PlainAuth("", e.User, e.Password, host)
+	}
+
+	// net/smtp has no context support, so give up waiting when ctx is done
+	done := make(chan error, 1)
+	// Bcc recipients are only on the envelope, not in the headers
+	var envelope []string
+	for _, list := range [][]string{to, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			// "Office <office@example.org>" is fine in headers only
+			if addr, err := mail.ParseAddress(a); err == nil {
+				a = addr.Address
+			}
+			envelope = append(envelope, a)
+		}
+	}
+	go func() { done <- smtp.SendMail(e.Addr, auth, e.From, envelope, body.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeQuotedPrintable writes text with CRLF line endings, as mail wants
+func writeQuotedPrintable(w io.Writer, text string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+}
+
+// SlackOrderAction is the action_id of the button Slack.Button adds
+const SlackOrderAction = "lunchweb_order"
+
+// Slack posts messages to a Slack incoming webhook
+type Slack struct {
+	URL string
+	// Button labels a button under every message that lets people order,
+	// for the webhook of a Slack app with interactivity; empty for none
+	Button string
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	text := fmt.Sprintf("*%s*\n%s", msg.Subject, msg.Text)
+	payload := map[string]interface{}{"text": text}
+	if s.Button != "" {
+		// with blocks, text is only what notifications show; a section
+		// holds at most 3000 characters
+		section := text
+		if len(section) > 3000 {
+			section = strings.ToValidUTF8(section[:2990], "") + "…"
+		}
+		payload["blocks"] = []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": section},
+			},
+			map[string]interface{}{
+				"type": "actions",
+				"elements": []interface{}{map[string]interface{}{
+					"type":      "button",
+					"action_id": SlackOrderAction,
+					"text":      map[string]string{"type": "plain_text", "text": s.Button},
+					"style":     "primary",
+				}},
+			},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return PostJSON(ctx, s.URL, body, nil)
+}
+
+// Webhook posts messages as JSON to any URL
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"subject": msg.Subject,
+		"text":    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	return PostJSON(ctx, w.URL, body, nil)
+}
+
+// PostJSON posts body and treats any non-2xx response as an error
+func PostJSON(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
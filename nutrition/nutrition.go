@@ -0,0 +1,198 @@
+// Package nutrition estimates the calories and macronutrients of dishes,
+// from Open Food Facts or a table of our own.
+package nutrition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Facts are the estimated nutrients of one portion
+type Facts struct {
+	Calories float64 `json:"kcal"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+}
+
+// Add returns the sum of f and g, e.g. for food and drink
+func (f Facts) Add(g Facts) Facts {
+	return Facts{f.Calories + g.Calories, f.Protein + g.Protein, f.Carbs + g.Carbs, f.Fat + g.Fat}
+}
+
+// Div returns f divided by n, e.g. for an average
+func (f Facts) Div(n int) Facts {
+	if n == 0 {
+		return Facts{}
+	}
+	d := float64(n)
+	return Facts{f.Calories / d, f.Protein / d, f.Carbs / d, f.Fat / d}
+}
+
+func (f Facts) String() string {
+	return fmt.Sprintf("~%.0f kcal, %.0f g protein, %.0f g carbs, %.0f g fat", f.Calories, f.Protein, f.Carbs, f.Fat)
+}
+
+// Source looks up the nutrients of a dish by its name. ok is false when it
+// knows no such dish.
+type Source interface {
+	Name() string
+	Lookup(ctx context.Context, item string) (facts Facts, ok bool, err error)
+}
+
+// OpenFoodFacts searches the Open Food Facts database and takes the best
+// match. Products list their nutrients per serving or per 100 g; without a
+// serving size Portion grams are assumed.
+type OpenFoodFacts struct {
+	// URL is the API, https://world.openfoodfacts.org when empty
+	URL string
+	// Portion is the weight of a dish in grams, 300 when 0
+	Portion float64
+	// Interval spaces the searches, as the API allows about 10 a minute
+	Interval time.Duration
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (o *OpenFoodFacts) Name() string { return "openfoodfacts" }
+
+func (o *OpenFoodFacts) Lookup(ctx context.Context, item string) (Facts, bool, error) {
+	if err := o.wait(ctx); err != nil {
+		return Facts{}, false, err
+	}
+	base := o.URL
+	if base == "" {
+		base = "https://world.openfoodfacts.org"
+	}
+	q := url.Values{
+		"search_terms":  {item},
+		"search_simple": {"1"},
+		"json":          {"1"},
+		"page_size":     {"1"},
+		"fields":        {"product_name,nutriments"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/cgi/search.pl?"+q.Encode(), nil)
+	if err != nil {
+		return Facts{}, false, err
+	}
+	req.Header.Set("User-Agent", "lunchweb (https://github.com/datacamp/lunchweb)")
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Facts{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Facts{}, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var result struct {
+		Products []struct {
+			Nutriments map[string]interface{} `json:"nutriments"`
+		} `json:"products"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Facts{}, false, fmt.Errorf("invalid response: %v", err)
+	}
+	if len(result.Products) == 0 {
+		return Facts{}, false, nil
+	}
+	return o.facts(result.Products[0].Nutriments)
+}
+
+// facts reads the nutrients of a product per serving, or per 100 g scaled
+// to a portion
+func (o *OpenFoodFacts) facts(n map[string]interface{}) (Facts, bool, error) {
+	get := func(name, per string) (float64, bool) {
+		v, ok := n[name+"_"+per].(float64)
+		return v, ok
+	}
+	for _, per := range []string{"serving", "100g"} {
+		kcal, ok := get("energy-kcal", per)
+		if !ok {
+			continue
+		}
+		protein, _ := get("proteins", per)
+		carbs, _ := get("carbohydrates", per)
+		fat, _ := get("fat", per)
+		f := Facts{kcal, protein, carbs, fat}
+		if per == "100g" {
+			portion := o.Portion
+			if portion == 0 {
+				portion = 300
+			}
+			f = Facts{f.Calories * portion / 100, f.Protein * portion / 100, f.Carbs * portion / 100, f.Fat * portion / 100}
+		}
+		return f, true, nil
+	}
+	return Facts{}, false, nil
+}
+
+// wait keeps Interval between searches
+func (o *OpenFoodFacts) wait(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if d := o.Interval - time.Since(o.last); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	o.last = time.Now()
+	return nil
+}
+
+// Table knows the nutrients of dishes by words in their names, like
+// {"club sandwich": {...}, "pizza": {...}}. The longest matching name wins,
+// so "club sandwich" beats "sandwich".
+type Table map[string]Facts
+
+// LoadTable reads a table from a JSON file
+func LoadTable(path string) (Table, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t Table
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t Table) Name() string { return "table" }
+
+func (t Table) Lookup(ctx context.Context, item string) (Facts, bool, error) {
+	item = " " + strings.Join(words(item), " ") + " "
+	best := ""
+	for name := range t {
+		if strings.Contains(item, " "+strings.Join(words(name), " ")+" ") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Facts{}, false, nil
+	}
+	return t[best], true, nil
+}
+
+// words returns the lower case words of s without punctuation
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
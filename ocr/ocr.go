@@ -0,0 +1,121 @@
+// Package ocr recognizes the text on photos and scans, like a vendor's
+// paper menu, with Tesseract or an OCR service reached over HTTP.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// Reader recognizes the text in an image or PDF
+type Reader interface {
+	Name() string
+	Text(ctx context.Context, data []byte, contentType string) (string, error)
+}
+
+// Tesseract runs the tesseract command. PDFs go through pdftotext from
+// poppler first, and when they have no text layer the first page is
+// rendered with pdftoppm and recognized like a photo.
+type Tesseract struct {
+	// Command is the tesseract binary, "tesseract" when empty
+	Command string
+	// Languages are the language codes to recognize, like "eng+deu"; empty
+	// for Tesseract's default
+	Languages string
+}
+
+func (t *Tesseract) Name() string { return "tesseract" }
+
+func (t *Tesseract) Text(ctx context.Context, data []byte, contentType string) (string, error) {
+	if contentType == "application/pdf" {
+		text, err := run(ctx, data, "pdftotext", "-layout", "-", "-")
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(text) != "" {
+			return text, nil
+		}
+		page, err := run(ctx, data, "pdftoppm", "-png", "-r", "300", "-singlefile", "-")
+		if err != nil {
+			return "", err
+		}
+		data = []byte(page)
+	}
+	command := t.Command
+	if command == "" {
+		command = "tesseract"
+	}
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+	return run(ctx, data, command, args...)
+}
+
+// run feeds input to a command and returns what it prints
+func run(ctx context.Context, input []byte, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// HTTP posts the file to an OCR service, which answers with the text as
+// text/plain or as JSON like {"text": "..."}
+type HTTP struct {
+	URL string
+	// Client sends the request, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (h *HTTP) Name() string { return "http" }
+
+func (h *HTTP) Text(ctx context.Context, data []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "text/plain, application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var result struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return "", fmt.Errorf("invalid response: %v", err)
+		}
+		return result.Text, nil
+	}
+	return string(body), nil
+}
@@ -0,0 +1,67 @@
+package order
+
+import (
+	"strings"
+	"unicode"
+)
+
+// allergenWords maps common allergens to words of dishes that usually
+// contain them. It only catches the obvious, a warning is a reminder to ask
+// and no absence of one a promise.
+var allergenWords = map[string][]string{
+	"gluten":    {"wheat", "bread", "baguette", "bun", "burger", "sandwich", "blt", "club", "panini", "wrap", "pita", "pizza", "calzone", "pasta", "spaghetti", "lasagna", "penne", "tagliatelle", "noodle", "ramen", "udon", "couscous", "bulgur", "seitan", "croissant", "cake", "cookie", "brownie", "muffin", "beer", "barley", "rye", "tempura", "breaded", "schnitzel", "dumpling", "gyoza", "quiche"},
+	"peanut":    {"peanut", "satay", "pad thai", "gado"},
+	"nuts":      {"nut", "almond", "hazelnut", "walnut", "cashew", "pistachio", "pecan", "pesto", "praline", "marzipan", "nutella"},
+	"dairy":     {"milk", "cheese", "cream", "butter", "yogurt", "yoghurt", "mozzarella", "parmesan", "feta", "halloumi", "burrata", "ricotta", "mascarpone", "paneer", "latte", "cappuccino", "carbonara", "alfredo", "tzatziki", "pizza", "quiche", "lasagna", "milkshake"},
+	"egg":       {"egg", "mayo", "mayonnaise", "aioli", "omelette", "quiche", "carbonara", "frittata", "meringue", "brioche", "tamago"},
+	"fish":      {"fish", "salmon", "tuna", "cod", "anchovy", "sardine", "mackerel", "trout", "sushi", "sashimi", "maki", "poke", "nigiri", "fish sauce", "pad thai"},
+	"shellfish": {"shrimp", "prawn", "crab", "lobster", "scampi", "crayfish", "mussel", "oyster", "clam", "scallop", "squid", "calamari", "octopus"},
+	"soy":       {"soy", "soya", "tofu", "edamame", "miso", "tempeh", "teriyaki"},
+	"sesame":    {"sesame", "tahini", "hummus", "halva", "falafel"},
+	"celery":    {"celery", "celeriac"},
+	"mustard":   {"mustard", "dijon"},
+}
+
+// allergenAliases are other names people use for the allergens above
+var allergenAliases = map[string]string{
+	"peanuts":    "peanut",
+	"tree nuts":  "nuts",
+	"nut":        "nuts",
+	"lactose":    "dairy",
+	"milk":       "dairy",
+	"eggs":       "egg",
+	"wheat":      "gluten",
+	"coeliac":    "gluten",
+	"celiac":     "gluten",
+	"crustacean": "shellfish",
+	"seafood":    "shellfish",
+	"soya":       "soy",
+}
+
+// Allergens returns those of allergens, like "gluten" or "peanuts", that
+// item may contain judging by its words. Allergens this package doesn't
+// know, like "coriander", match when item mentions them.
+func Allergens(item string, allergens []string) []string {
+	text := " " + strings.Join(strings.FieldsFunc(strings.ToLower(item), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ") + " "
+	var found []string
+	for _, allergen := range allergens {
+		key := strings.ToLower(strings.TrimSpace(allergen))
+		if alias, ok := allergenAliases[key]; ok {
+			key = alias
+		}
+		words, ok := allergenWords[key]
+		if !ok {
+			words = []string{key}
+		}
+		for _, w := range words {
+			// plurals too, but "egg" doesn't find "eggplant"
+			if w != "" && (strings.Contains(text, " "+w+" ") || strings.Contains(text, " "+w+"s ") || strings.Contains(text, " "+w+"es ")) {
+				found = append(found, allergen)
+				break
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,37 @@
+package order
+
+import (
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// nameCollator sorts names the way people of the configured language expect,
+// e.g. Émile next to Emma rather than after Zoë. A Collator is not safe for
+// concurrent use, hence the mutex.
+var nameCollator struct {
+	mu sync.Mutex
+	c  *collate.Collator
+}
+
+// SetLanguage makes LessName and ByName sort in the alphabetical order of
+// tag. Until it is called names are compared byte by byte.
+func SetLanguage(tag language.Tag) {
+	nameCollator.mu.Lock()
+	defer nameCollator.mu.Unlock()
+	nameCollator.c = collate.New(tag, collate.IgnoreCase)
+}
+
+// LessName reports whether name a sorts before b
+func LessName(a, b string) bool {
+	nameCollator.mu.Lock()
+	defer nameCollator.mu.Unlock()
+	if nameCollator.c == nil {
+		return a < b
+	}
+	if c := nameCollator.c.CompareString(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
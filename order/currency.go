@@ -0,0 +1,122 @@
+package order
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Currency is how amounts are written in an office
+type Currency struct {
+	// Code is the ISO 4217 code, e.g. "EUR"; orders may use it instead of
+	// the symbol
+	Code   string
+	Symbol string
+	// Decimal separates the cents, "." or ","
+	Decimal string
+	// After writes the symbol after the amount, as in "7,50 kr"
+	After bool
+	// Rounding is the smallest coin in cents, e.g. 5 for Swiss francs;
+	// amounts are shown rounded to it. 0 or 1 for none.
+	Rounding Money
+}
+
+// currencies are the ones SetCurrency knows by code
+var currencies = map[string]Currency{
+	"EUR": {Code: "EUR", Symbol: "€", Decimal: "."},
+	"USD": {Code: "USD", Symbol: "$", Decimal: "."},
+	"GBP": {Code: "GBP", Symbol: "£", Decimal: "."},
+	"CHF": {Code: "CHF", Symbol: "CHF", Decimal: ".", Rounding: 5},
+	"SEK": {Code: "SEK", Symbol: "kr", Decimal: ",", After: true},
+	"NOK": {Code: "NOK", Symbol: "kr", Decimal: ",", After: true},
+	"DKK": {Code: "DKK", Symbol: "kr.", Decimal: ",", After: true},
+	"PLN": {Code: "PLN", Symbol: "zł", Decimal: ",", After: true},
+	"CZK": {Code: "CZK", Symbol: "Kč", Decimal: ",", After: true},
+	"CAD": {Code: "CAD", Symbol: "$", Decimal: ".", Rounding: 5},
+	"AUD": {Code: "AUD", Symbol: "$", Decimal: ".", Rounding: 5},
+	"INR": {Code: "INR", Symbol: "₹", Decimal: "."},
+}
+
+// LookupCurrency returns the currency with the ISO 4217 code
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// CurrencyCodes lists the codes LookupCurrency knows, sorted
+func CurrencyCodes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// money is the currency prices are parsed and formatted in, euros until
+// SetCurrency is called. priceRe finds amounts next to its symbol or code
+// ("€7.50", "7,50 EUR") or with cents ("6.50"); plain numbers like
+// "2 tacos" are not prices.
+var money struct {
+	mu       sync.RWMutex
+	currency Currency
+	priceRe  *regexp.Regexp
+}
+
+func init() {
+	SetCurrency(currencies["EUR"])
+}
+
+// SetCurrency makes prices parse and format in c
+func SetCurrency(c Currency) {
+	var before, after []string
+	for _, m := range []string{c.Symbol, c.Code} {
+		if m == "" {
+			continue
+		}
+		before = append(before, regexp.QuoteMeta(m))
+		// "7 eur" but not "7 euros worth"; \b only knows ASCII letters
+		if r, _ := utf8.DecodeLastRuneInString(m); r < utf8.RuneSelf && unicode.IsLetter(r) {
+			after = append(after, regexp.QuoteMeta(m)+`\b`)
+		} else {
+			after = append(after, regexp.QuoteMeta(m))
+		}
+	}
+	amount := `(\d+(?:[.,]\d{1,2})?)`
+	re := regexp.MustCompile(fmt.Sprintf(`(?i)(?:(?:%s)\s*%s)|(?:%s\s*(?:%s))|(?:\b(\d+[.,]\d{2})\b)`,
+		strings.Join(before, "|"), amount, amount, strings.Join(after, "|")))
+
+	money.mu.Lock()
+	defer money.mu.Unlock()
+	money.currency = c
+	money.priceRe = re
+}
+
+// CurrentCurrency returns the currency set with SetCurrency
+func CurrentCurrency() Currency {
+	money.mu.RLock()
+	defer money.mu.RUnlock()
+	return money.currency
+}
+
+func currentPriceRe() *regexp.Regexp {
+	money.mu.RLock()
+	defer money.mu.RUnlock()
+	return money.priceRe
+}
+
+// Round rounds m to the currency's smallest coin, halves away from zero
+func (m Money) Round() Money {
+	step := CurrentCurrency().Rounding
+	if step <= 1 {
+		return m
+	}
+	if m < 0 {
+		return -(-m).Round()
+	}
+	return (m + step/2) / step * step
+}
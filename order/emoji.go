@@ -0,0 +1,67 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// emojis maps words in common items to an emoji, the first match wins
+var emojis = []struct {
+	words []string
+	emoji string
+}{
+	{[]string{"pizza", "calzone"}, "🍕"},
+	{[]string{"ramen", "noodle", "pho", "udon", "soba", "pad thai"}, "🍜"},
+	{[]string{"sushi", "maki", "sashimi", "poke"}, "🍣"},
+	{[]string{"salad"}, "🥗"},
+	{[]string{"burger"}, "🍔"},
+	{[]string{"sandwich", "blt", "club", "panini", "baguette", "sub"}, "🥪"},
+	{[]string{"wrap", "burrito"}, "🌯"},
+	{[]string{"taco"}, "🌮"},
+	{[]string{"kebab", "falafel", "gyro", "shawarma", "pita"}, "🥙"},
+	{[]string{"pasta", "spaghetti", "lasagna", "penne", "tagliatelle"}, "🍝"},
+	{[]string{"curry"}, "🍛"},
+	{[]string{"soup"}, "🍲"},
+	{[]string{"rice", "bowl"}, "🍚"},
+	{[]string{"chicken", "wings"}, "🍗"},
+	{[]string{"fries", "frites"}, "🍟"},
+	{[]string{"hot dog", "hotdog"}, "🌭"},
+	{[]string{"quiche", "omelette", "egg"}, "🍳"},
+	{[]string{"cake", "cookie", "brownie", "muffin"}, "🍰"},
+	{[]string{"coffee", "latte", "espresso", "cappuccino"}, "☕"},
+	{[]string{"juice", "smoothie", "cola", "soda", "water"}, "🥤"},
+}
+
+// Emoji returns an emoji for the kind of food item is, or "" when it isn't a
+// common one
+func Emoji(item string) string {
+	words := strings.FieldsFunc(strings.ToLower(item), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	text := " " + strings.Join(words, " ") + " "
+	for _, e := range emojis {
+		for _, w := range e.words {
+			// whole words or their plural, so "noodles" finds "noodle"
+			// but "subway" doesn't find "sub"
+			if strings.Contains(text, " "+w+" ") || strings.Contains(text, " "+w+"s ") {
+				return e.emoji
+			}
+		}
+	}
+	return ""
+}
+
+// ChatSummary returns the orders as a bullet list for chat apps, sorted like
+// Items: names in bold, orders of common items behind their emoji
+func (o *Overview) ChatSummary(sortBy string) string {
+	var b strings.Builder
+	for _, li := range o.Items(sortBy) {
+		order := li.Order
+		if e := Emoji(order); e != "" {
+			order = e + " " + order
+		}
+		fmt.Fprintf(&b, "• *%s*: %s\n", li.Name, order)
+	}
+	return b.String()
+}
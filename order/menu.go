@@ -0,0 +1,78 @@
+package order
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MenuItem is a dish on a vendor's menu
+type MenuItem struct {
+	Name   string `json:"name"`
+	Price  Money  `json:"price,omitempty"`
+	Priced bool   `json:"priced,omitempty"`
+}
+
+// String formats the item as a menu line, e.g. "Margherita €8.50", which
+// ParseMenu reads back
+func (m MenuItem) String() string {
+	if !m.Priced {
+		return m.Name
+	}
+	return m.Name + " " + m.Price.String()
+}
+
+// ParseMenu reads a menu with an item per line, like the text recognized on
+// a photo of a paper menu. The price is the last amount on the line; dot
+// leaders, numbering and blank or letterless lines are dropped.
+func ParseMenu(text string) []MenuItem {
+	var items []MenuItem
+	for _, line := range strings.Split(text, "\n") {
+		item := MenuItem{}
+		if loc := lastPrice(line); loc != nil {
+			item.Price, item.Priced = ParsePrice(line[loc[0]:loc[1]])
+			line = line[:loc[0]] + " " + line[loc[1]:]
+		}
+		item.Name = cleanMenuName(line)
+		if !hasLetters(item.Name, 2) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// lastPrice returns the position of the last price on line, or nil
+func lastPrice(line string) []int {
+	all := currentPriceRe().FindAllStringIndex(line, -1)
+	if len(all) == 0 {
+		return nil
+	}
+	return all[len(all)-1]
+}
+
+// menuNumberRe matches the number in front of items like "12. " or "#3)"
+var menuNumberRe = regexp.MustCompile(`^#?\d+[.)]\s*`)
+
+// cleanMenuName trims numbering like "12." and leaders like "....." or
+// " - " around a menu item's name
+func cleanMenuName(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = menuNumberRe.ReplaceAllString(s, "")
+	s = strings.TrimRightFunc(s, func(r rune) bool {
+		return r == '.' || r == '-' || r == '–' || r == '—' || r == '_' || r == ':' || r == '…' || unicode.IsSpace(r)
+	})
+	return s
+}
+
+func hasLetters(s string, n int) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			n--
+			if n == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
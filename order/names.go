@@ -0,0 +1,60 @@
+package order
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName cleans up a name from the header: surrounding and repeated
+// whitespace goes, and accents typed as combining characters ("E" + "´")
+// become the single character other cells use ("É")
+func NormalizeName(name string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(name), " "))
+}
+
+// NameKey makes "joe", "Joe " and "JOE" the same person
+func NameKey(name string) string {
+	return strings.ToLower(NormalizeName(name))
+}
+
+// ItemKey makes "BLT  sandwich" and "blt Sandwich" count as the same item
+func ItemKey(order string) string {
+	return strings.ToLower(strings.Join(strings.Fields(order), " "))
+}
+
+// columnSeparators split a column name into person and label, as in
+// "Joe — Food" and "Joe - Drink"
+var columnSeparators = []string{" — ", " – ", " - "}
+
+// SplitColumnName returns the person and the label of a column name. Names
+// without a separator have no label.
+func SplitColumnName(column string) (name, label string) {
+	for _, sep := range columnSeparators {
+		if name, label, ok := strings.Cut(column, sep); ok {
+			return strings.TrimSpace(name), strings.TrimSpace(label)
+		}
+	}
+	return column, ""
+}
+
+// JoinParts combines the orders from the columns of one person into one
+// text: "Food: BLT; Drink: Coke", or "BLT; Soup" without labels
+func JoinParts(parts []Part) string {
+	var texts []string
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		if p.Order == "" {
+			continue
+		}
+		text := p.Order
+		if p.Label != "" {
+			text = p.Label + ": " + p.Order
+		}
+		if !seen[text] {
+			seen[text] = true
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "; ")
+}
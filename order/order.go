@@ -0,0 +1,180 @@
+// Package order turns the names and orders of one row of the lunch sheet into
+// line items: one per person who ordered, with the orders from all of their
+// columns and the price written in them.
+//
+//	oo := order.New(header[1:], row[1:])
+//	for _, li := range oo.LineItems() {
+//		fmt.Println(li.Name, li.Order)
+//	}
+package order
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Overview is the orders of one day
+type Overview struct {
+	Names  []string
+	Orders []string
+	// parts are the orders per column of each name, see New
+	parts [][]Part
+
+	// lineItems caches LineItems, which the page, the summary and the
+	// percentages all ask for; Names and Orders must not change after the
+	// first call
+	lineItemsOnce sync.Once
+	lineItems     []*LineItem
+}
+
+// LineItem is the order of one person
+type LineItem struct {
+	Name  string
+	Order string
+	// Parts are the filled in columns of the person, e.g. food and drink
+	Parts []Part
+}
+
+// Part is the order in one of several columns of a person
+type Part struct {
+	// Label is the column's label, "Drink" for "Joe — Drink"
+	Label string
+	Order string
+}
+
+// Grouped reports whether the order has labelled parts worth showing
+// separately
+func (li *LineItem) Grouped() bool {
+	for _, p := range li.Parts {
+		if p.Label != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// New pairs names with orders. Names are normalized and a person with
+// several columns gets one line with the orders of all of them: "Joe — Food"
+// and "Joe — Drink" become Joe's food and drink, a second "joe " column is
+// merged into "Joe".
+func New(names, orders []string) *Overview {
+	o := &Overview{}
+	seen := make(map[string]int)
+	for i, column := range names {
+		name, label := SplitColumnName(NormalizeName(column))
+		part := Part{Label: label}
+		if i < len(orders) {
+			part.Order = strings.TrimSpace(orders[i])
+		}
+		if name != "" {
+			key := NameKey(name)
+			if j, ok := seen[key]; ok {
+				o.parts[j] = append(o.parts[j], part)
+				continue
+			}
+			seen[key] = len(o.Names)
+		}
+		o.Names = append(o.Names, name)
+		o.parts = append(o.parts, []Part{part})
+	}
+	for _, parts := range o.parts {
+		o.Orders = append(o.Orders, JoinParts(parts))
+	}
+	return o
+}
+
+// LineItems returns the orders sorted by name. The slice and the line items
+// are computed once and shared by every caller, so they are read-only: copy
+// the slice before sorting it differently, and a line item before changing
+// it.
+func (o *Overview) LineItems() []*LineItem {
+	o.lineItemsOnce.Do(func() {
+		o.lineItems = o.ColumnItems()
+		sort.Sort(ByName(o.lineItems))
+	})
+	return o.lineItems
+}
+
+// filledParts returns the parts of the i-th name that have an order
+func (o *Overview) filledParts(i int, order string) []Part {
+	if i >= len(o.parts) {
+		return []Part{{Order: order}}
+	}
+	var parts []Part
+	for _, p := range o.parts[i] {
+		if p.Order != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// ColumnItems returns the orders in the order of the sheet's columns
+func (o *Overview) ColumnItems() []*LineItem {
+	lines := make([]*LineItem, 0)
+	for i, name := range o.Names {
+		// a short row has no orders for the last names
+		if i >= len(o.Orders) {
+			break
+		}
+		order := strings.TrimSpace(o.Orders[i])
+		if name != "" && order != "" {
+			lines = append(lines, &LineItem{Name: name, Order: order, Parts: o.filledParts(i, order)})
+		}
+	}
+	return lines
+}
+
+// OrderOf returns the order of the person with name, "" when they didn't
+// order or have no column
+func (o *Overview) OrderOf(name string) string {
+	key := NameKey(name)
+	for i, n := range o.Names {
+		if NameKey(n) == key && i < len(o.Orders) {
+			return strings.TrimSpace(o.Orders[i])
+		}
+	}
+	return ""
+}
+
+// Without returns a copy of the orders in which the people with names
+// haven't ordered, still counting as people who could have
+func (o *Overview) Without(names ...string) *Overview {
+	leave := make(map[string]bool)
+	for _, name := range names {
+		leave[NameKey(name)] = true
+	}
+	c := &Overview{
+		Names:  o.Names,
+		Orders: append([]string(nil), o.Orders...),
+		parts:  append([][]Part(nil), o.parts...),
+	}
+	for i, name := range o.Names {
+		if !leave[NameKey(name)] || i >= len(c.Orders) {
+			continue
+		}
+		c.Orders[i] = ""
+		if i < len(c.parts) {
+			c.parts[i] = nil
+		}
+	}
+	return c
+}
+
+// MaxCount returns the number of people who could have ordered
+func (o *Overview) MaxCount() int {
+	return len(o.Names)
+}
+
+// OrderPercent returns the percentage of people who ordered
+func (o *Overview) OrderPercent() float32 {
+	return 100 * float32(len(o.LineItems())) / float32(o.MaxCount())
+}
+
+// ByName sorts line items by name, see LessName
+type ByName []*LineItem
+
+func (a ByName) Len() int           { return len(a) }
+func (a ByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByName) Less(i, j int) bool { return LessName(a[i].Name, a[j].Name) }
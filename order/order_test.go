@@ -0,0 +1,77 @@
+package order
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkRow returns the names and orders of a sheet row with n people,
+// every third of them with food and drink columns
+func benchmarkRow(n int) (names, orders []string) {
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("Person %d", i)
+		if i%3 == 0 {
+			names = append(names, name+" — Food", name+" — Drink")
+			orders = append(orders, "Club sandwich €7.50", "Cola")
+			continue
+		}
+		names = append(names, name)
+		orders = append(orders, []string{"", "Poke bowl", "1/2 pizza margherita", "BLT no mayo 6,20"}[i%4])
+	}
+	return names, orders
+}
+
+func BenchmarkNew(b *testing.B) {
+	names, orders := benchmarkRow(60)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		New(names, orders)
+	}
+}
+
+func BenchmarkLineItems(b *testing.B) {
+	names, orders := benchmarkRow(60)
+	b.Run("first", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			New(names, orders).LineItems()
+		}
+	})
+	b.Run("again", func(b *testing.B) {
+		oo := New(names, orders)
+		oo.LineItems()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			oo.LineItems()
+		}
+	})
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+		ok   bool
+	}{
+		{"7", 700, true},
+		{"7.5", 750, true},
+		{"7,50", 750, true},
+		{"0.05", 5, true},
+		{"12.", 1200, true},
+		{"1,500", 0, false},
+		{"1.234,56", 0, false},
+		{"1,234.56", 0, false},
+		{"7.505", 0, false},
+		{"7.5a", 0, false},
+		{"7.-5", 0, false},
+		{"", 0, false},
+		{"seven", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAmount(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseAmount(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package order
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Money is an amount in cents
+type Money int64
+
+// String formats the amount in the current currency, rounded to its
+// smallest coin, e.g. "€7.50", "CHF 7.50" or "7,50 kr"
+func (m Money) String() string {
+	c := CurrentCurrency()
+	if c.After {
+		return m.Decimal() + " " + c.Symbol
+	}
+	symbol := c.Symbol
+	if utf8.RuneCountInString(symbol) > 1 {
+		symbol += " "
+	}
+	if m < 0 {
+		return "-" + symbol + (-m).Decimal()
+	}
+	return symbol + m.Decimal()
+}
+
+// Decimal formats the amount without currency symbol, e.g. for CSV exports,
+// with the current currency's decimal separator and rounding
+func (m Money) Decimal() string {
+	m = m.Round()
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d%s%02d", sign, m/100, CurrentCurrency().Decimal, m%100)
+}
+
+// ParsePrice extracts the price from an order text. When there are several
+// amounts the last one wins, as people tend to write the total at the end.
+func ParsePrice(order string) (Money, bool) {
+	matches := currentPriceRe().FindAllStringSubmatch(order, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	m := matches[len(matches)-1]
+	for _, amount := range m[1:] {
+		if amount != "" {
+			return ParseAmount(amount)
+		}
+	}
+	return 0, false
+}
+
+// WithoutPrice returns the order text with its prices taken out, e.g. to look
+// up the dish
+func WithoutPrice(order string) string {
+	return strings.Join(strings.Fields(currentPriceRe().ReplaceAllString(order, " ")), " ")
+}
+
+// Price adds up the prices of the order's parts, so food and drink in
+// separate columns both count. A share like "1/2 large pizza €14" costs that
+// part of the price. ok is false when no part has a price.
+func (li *LineItem) Price() (total Money, ok bool) {
+	if len(li.Parts) == 0 {
+		return sharePrice(li.Order)
+	}
+	for _, p := range li.Parts {
+		if price, priced := sharePrice(p.Order); priced {
+			total += price
+			ok = true
+		}
+	}
+	return total, ok
+}
+
+// ParseAmount parses "7", "7.5" or "7,50" into cents. Thousands separators
+// and more than two decimals, as in "1,500" or "1.234,56", make it fail
+// rather than read another amount.
+func ParseAmount(s string) (Money, bool) {
+	s = strings.Replace(s, ",", ".", 1)
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > 2 || strings.Trim(frac, "0123456789") != "" {
+		return 0, false
+	}
+	euros, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	cents, err := strconv.ParseInt(frac[:2], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return Money(euros*100 + cents), true
+}
@@ -0,0 +1,193 @@
+package order
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Share is one person's part of an item ordered together, as in
+// "1/2 large pizza"
+type Share struct {
+	Name     string
+	Fraction *big.Rat
+}
+
+// SharedItem is an item several people claimed shares of
+type SharedItem struct {
+	// Item is the item as first written, without the share and price
+	Item   string
+	Shares []Share
+	// Price is the price of the whole item, when a share has one
+	Price  Money
+	Priced bool
+}
+
+// shareRe matches "1/2 pizza", "½ pizza", "half a pizza" and "a third of the
+// lasagna", but not "quarter pounder"
+var shareRe = regexp.MustCompile(`(?i)^\s*(?:(\d+)\s*/\s*(\d+)|([½⅓⅔¼¾⅕⅙⅛])|(?:a |one )?(half|(?:third|quarter)\s+of))\s+(?:(?:of\s+)?(?:an?|the)\s+|of\s+)?(\S.*)$`)
+
+var shareWords = map[string]*big.Rat{
+	"½": big.NewRat(1, 2), "⅓": big.NewRat(1, 3), "⅔": big.NewRat(2, 3),
+	"¼": big.NewRat(1, 4), "¾": big.NewRat(3, 4), "⅕": big.NewRat(1, 5),
+	"⅙": big.NewRat(1, 6), "⅛": big.NewRat(1, 8),
+	"half": big.NewRat(1, 2), "third": big.NewRat(1, 3), "quarter": big.NewRat(1, 4),
+}
+
+// maxShareDenominator keeps the likes of "1/100 pizza" from counting as a
+// share
+const maxShareDenominator = 12
+
+// ParseShare recognizes an order for a share of an item, like "1/2 large
+// pizza", "½ pizza" or "half a pizza", and returns the item and the share
+// of it
+func ParseShare(text string) (item string, fraction *big.Rat, ok bool) {
+	m := shareRe.FindStringSubmatch(text)
+	if m == nil {
+		return "", nil, false
+	}
+	switch {
+	case m[1] != "":
+		num, _ := strconv.Atoi(m[1])
+		den, _ := strconv.Atoi(m[2])
+		if num <= 0 || den > maxShareDenominator || num >= den {
+			return "", nil, false
+		}
+		fraction = big.NewRat(int64(num), int64(den))
+	case m[3] != "":
+		fraction = shareWords[m[3]]
+	default:
+		fraction = shareWords[strings.ToLower(strings.Fields(m[4])[0])]
+	}
+	item = WithoutPrice(m[5])
+	if item == "" {
+		return "", nil, false
+	}
+	return item, new(big.Rat).Set(fraction), true
+}
+
+// sharePrice is the price of an order, which for a share is that part of the
+// whole item's price written with it
+func sharePrice(text string) (Money, bool) {
+	price, ok := ParsePrice(text)
+	if !ok {
+		return 0, false
+	}
+	if _, fraction, shared := ParseShare(text); shared {
+		num, den := fraction.Num().Int64(), fraction.Denom().Int64()
+		price = (price*Money(num) + Money(den)/2) / Money(den)
+	}
+	return price, true
+}
+
+// SharedItems groups the shares claimed in items by item, in the order of
+// the first share of each
+func SharedItems(items []*LineItem) []*SharedItem {
+	var shared []*SharedItem
+	byKey := make(map[string]*SharedItem)
+	for _, li := range items {
+		texts := []string{li.Order}
+		if len(li.Parts) > 0 {
+			texts = texts[:0]
+			for _, p := range li.Parts {
+				texts = append(texts, p.Order)
+			}
+		}
+		for _, text := range texts {
+			item, fraction, ok := ParseShare(text)
+			if !ok {
+				continue
+			}
+			key := ItemKey(item)
+			s := byKey[key]
+			if s == nil {
+				s = &SharedItem{Item: item}
+				byKey[key] = s
+				shared = append(shared, s)
+			}
+			s.Shares = append(s.Shares, Share{Name: li.Name, Fraction: fraction})
+			if price, ok := ParsePrice(text); ok && !s.Priced {
+				s.Price, s.Priced = price, true
+			}
+		}
+	}
+	return shared
+}
+
+// SharedPrice returns what name owes for their shares of priced items in
+// shared, for shares written without a price. ok is false when name has
+// none.
+func SharedPrice(shared []*SharedItem, name string) (total Money, ok bool) {
+	for _, s := range shared {
+		if !s.Priced {
+			continue
+		}
+		for _, share := range s.Shares {
+			if share.Name == name {
+				num, den := share.Fraction.Num().Int64(), share.Fraction.Denom().Int64()
+				total += (s.Price*Money(num) + Money(den)/2) / Money(den)
+				ok = true
+			}
+		}
+	}
+	return total, ok
+}
+
+// Claimed adds up the shares
+func (s *SharedItem) Claimed() *big.Rat {
+	sum := new(big.Rat)
+	for _, share := range s.Shares {
+		sum.Add(sum, share.Fraction)
+	}
+	return sum
+}
+
+// Wholes returns how many of the item to order, rounding up a share nobody
+// completed
+func (s *SharedItem) Wholes() int {
+	claimed := s.Claimed()
+	wholes := new(big.Int).Quo(claimed.Num(), claimed.Denom())
+	if !claimed.IsInt() {
+		wholes.Add(wholes, big.NewInt(1))
+	}
+	return int(wholes.Int64())
+}
+
+// Unclaimed returns the part of the last item nobody claimed, zero when the
+// shares add up to whole items
+func (s *SharedItem) Unclaimed() *big.Rat {
+	return new(big.Rat).Sub(new(big.Rat).SetInt64(int64(s.Wholes())), s.Claimed())
+}
+
+// Conflict describes why the shares don't add up, "" when they do
+func (s *SharedItem) Conflict() string {
+	unclaimed := s.Unclaimed()
+	if unclaimed.Sign() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s of a %s is not claimed yet", FormatFraction(unclaimed), s.Item)
+}
+
+// String describes the item for the restaurant, e.g. "1 × large pizza
+// (Joe 1/2, Ann 1/2)"
+func (s *SharedItem) String() string {
+	shares := append([]Share(nil), s.Shares...)
+	sort.SliceStable(shares, func(i, j int) bool { return LessName(shares[i].Name, shares[j].Name) })
+	var who []string
+	for _, share := range shares {
+		who = append(who, share.Name+" "+FormatFraction(share.Fraction))
+	}
+	text := fmt.Sprintf("%d × %s (%s)", s.Wholes(), s.Item, strings.Join(who, ", "))
+	if s.Priced {
+		text += fmt.Sprintf(", %s each", s.Price)
+	}
+	return text
+}
+
+// FormatFraction writes a share as "1/2"
+func FormatFraction(r *big.Rat) string {
+	return r.RatString()
+}
@@ -0,0 +1,52 @@
+package order
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// The orders Items and Summary can sort line items in
+const (
+	// SortName sorts by name, see LessName
+	SortName = "name"
+	// SortOrder groups the same items, as the restaurant wants them
+	SortOrder = "order"
+	// SortColumn keeps the order of the sheet's columns
+	SortColumn = "column"
+)
+
+// DefaultSummaryLine formats a line of Summary as "Joe: BLT Sandwich"
+var DefaultSummaryLine = template.Must(template.New("summary").Parse("{{.Name}}: {{.Order}}"))
+
+// Items returns the line items sorted by one of SortName, SortOrder or
+// SortColumn; anything else sorts by name
+func (o *Overview) Items(sortBy string) []*LineItem {
+	switch sortBy {
+	case SortColumn:
+		return o.ColumnItems()
+	case SortOrder:
+		lines := append([]*LineItem(nil), o.LineItems()...)
+		sort.SliceStable(lines, func(i, j int) bool {
+			return ItemKey(lines[i].Order) < ItemKey(lines[j].Order)
+		})
+		return lines
+	}
+	return o.LineItems()
+}
+
+// Summary returns one line per order, formatted with line (executed with a
+// *LineItem) and sorted like Items
+func (o *Overview) Summary(line *template.Template, sortBy string) string {
+	var buffer bytes.Buffer
+
+	for _, li := range o.Items(sortBy) {
+		if err := line.Execute(&buffer, li); err != nil {
+			buffer.WriteString(fmt.Sprintf("%v: %v", li.Name, li.Order))
+		}
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
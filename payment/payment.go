@@ -0,0 +1,227 @@
+// Package payment makes links that ask someone to pay an amount, with
+// PayPal.me, Tikkie, Payconiq or a URL template.
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Request is an amount From owes To
+type Request struct {
+	From string
+	To   string
+	// Account is the account of To with the provider, like their PayPal.me
+	// name, and Email their address; either may be ""
+	Account string
+	Email   string
+	Amount  Amount
+	// Description says what the payment is for, e.g. "Lunch 2026-10"
+	Description string
+}
+
+// Amount is an amount of money in cents of an ISO 4217 currency
+type Amount struct {
+	Cents    int64
+	Currency string
+}
+
+// Decimal formats the amount as in URLs, e.g. "12.50"
+func (a Amount) Decimal() string {
+	sign, cents := "", a.Cents
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (a Amount) String() string {
+	return a.Decimal() + " " + a.Currency
+}
+
+// Provider makes a link to pay a request. Link may create the request with
+// the provider, so a link is best made once and kept.
+type Provider interface {
+	Name() string
+	Link(ctx context.Context, r Request) (string, error)
+}
+
+// ErrNoAccount is returned by providers that pay into the account of the
+// payee when the request has none
+var ErrNoAccount = errors.New("no account to pay to")
+
+// PayPalMe links to paypal.me/<Account>/<amount>, which Account set up on
+// PayPal
+type PayPalMe struct{}
+
+func (PayPalMe) Name() string { return "paypal" }
+
+func (PayPalMe) Link(ctx context.Context, r Request) (string, error) {
+	if r.Account == "" {
+		return "", ErrNoAccount
+	}
+	return fmt.Sprintf("https://paypal.me/%s/%s%s", url.PathEscape(r.Account), r.Amount.Decimal(), r.Amount.Currency), nil
+}
+
+// Template fills in a URL template with the fields of the request, e.g.
+// https://pay.example.com/?to={{.Email | urlquery}}&amount={{.Amount.Decimal}}
+type Template struct {
+	t *template.Template
+}
+
+// NewTemplate parses a URL template
+func NewTemplate(text string) (*Template, error) {
+	t, err := template.New("payment").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &Template{t}
+	sample := Request{From: "Bob", To: "Ann", Email: "ann@example.com", Amount: Amount{1500, "EUR"}, Description: "Lunch"}
+	if _, err := p.Link(context.Background(), sample); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Template) Name() string { return "template" }
+
+func (p *Template) Link(ctx context.Context, r Request) (string, error) {
+	var b bytes.Buffer
+	if err := p.t.Execute(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Tikkie creates payment requests with ABN AMRO's Tikkie API. They pay into
+// the account of the app, whoever the request is to.
+type Tikkie struct {
+	APIKey   string
+	AppToken string
+	// URL is the API, https://api.abnamro.com when empty
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (t *Tikkie) Name() string { return "tikkie" }
+
+func (t *Tikkie) Link(ctx context.Context, r Request) (string, error) {
+	if r.Amount.Currency != "EUR" {
+		return "", fmt.Errorf("tikkie only requests euros, not %s", r.Amount.Currency)
+	}
+	base := t.URL
+	if base == "" {
+		base = "https://api.abnamro.com"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		// Tikkie cuts descriptions at 35 characters
+		"description":   truncate(fmt.Sprintf("%s: %s", r.From, r.Description), 35),
+		"amountInCents": r.Amount.Cents,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/v2/tikkie/paymentrequests", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Key", t.APIKey)
+	req.Header.Set("X-App-Token", t.AppToken)
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := do(t.Client, req, &result); err != nil {
+		return "", err
+	}
+	if result.URL == "" {
+		return "", errors.New("tikkie returned no url")
+	}
+	return result.URL, nil
+}
+
+// Payconiq creates payments with the Payconiq merchant API. They pay into
+// the merchant's account, whoever the request is to.
+type Payconiq struct {
+	APIKey string
+	// URL is the API, https://api.payconiq.com when empty
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (p *Payconiq) Name() string { return "payconiq" }
+
+func (p *Payconiq) Link(ctx context.Context, r Request) (string, error) {
+	if r.Amount.Currency != "EUR" {
+		return "", fmt.Errorf("payconiq only requests euros, not %s", r.Amount.Currency)
+	}
+	base := p.URL
+	if base == "" {
+		base = "https://api.payconiq.com"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"amount":      r.Amount.Cents,
+		"currency":    r.Amount.Currency,
+		"description": truncate(fmt.Sprintf("%s: %s", r.From, r.Description), 140),
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/v3/payments", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	var result struct {
+		Links struct {
+			Checkout struct {
+				Href string `json:"href"`
+			} `json:"checkout"`
+		} `json:"_links"`
+	}
+	if err := do(p.Client, req, &result); err != nil {
+		return "", err
+	}
+	if result.Links.Checkout.Href == "" {
+		return "", errors.New("payconiq returned no checkout link")
+	}
+	return result.Links.Checkout.Href, nil
+}
+
+// truncate cuts s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// do sends req and decodes the JSON response into v
+func do(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+// Package pdf writes simple PDF documents: pages of text in the standard
+// Helvetica fonts, which every reader has, and lines. Text is encoded as
+// WinAnsi, so Western European letters and € print; other characters come
+// out as "?".
+//
+//	doc := pdf.New(pdf.A4)
+//	page := doc.AddPage()
+//	page.Text(50, 800, pdf.HelveticaBold, 14, "Ann")
+//	err := doc.Write(w)
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Size is the size of a page in points, 1/72 inch
+type Size struct {
+	Width, Height float64
+}
+
+// A4 is the size of an A4 page
+var A4 = Size{595.28, 841.89}
+
+// Font is one of the standard fonts
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Document is a PDF document with pages of one size
+type Document struct {
+	size  Size
+	pages []*Page
+}
+
+// New returns an empty document with pages of size
+func New(size Size) *Document {
+	return &Document{size: size}
+}
+
+// Size returns the size of the pages
+func (d *Document) Size() Size {
+	return d.size
+}
+
+// AddPage adds a page at the end of the document and returns it
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Page is a page of a document. Its origin is at the bottom left.
+type Page struct {
+	content bytes.Buffer
+}
+
+// Text writes s on one line starting at x, with its baseline at y
+func (p *Page) Text(x, y float64, f Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %.2f %.2f Td %s Tj ET\n", f+1, size, x, y, literal(s))
+}
+
+// Line draws a thin gray line, dashed for cutting along
+func (p *Page) Line(x1, y1, x2, y2 float64, dashed bool) {
+	dash := "[] 0"
+	if dashed {
+		dash = "[4 3] 0"
+	}
+	fmt.Fprintf(&p.content, "q 0.6 G 0.5 w %s d %.2f %.2f m %.2f %.2f l S Q\n", dash, x1, y1, x2, y2)
+}
+
+// Width returns how wide s is in f at size
+func Width(f Font, size float64, s string) float64 {
+	widths, other := helveticaWidths, 556
+	if f == HelveticaBold {
+		widths, other = helveticaBoldWidths, 611
+	}
+	total := 0
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			total += widths[r-' ']
+		} else {
+			total += other
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width in f at size, between words
+// where it can
+func Wrap(f Font, size, width float64, s string) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		next := word
+		if line != "" {
+			next = line + " " + word
+		}
+		if Width(f, size, next) <= width {
+			line = next
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		// words too long for a line are broken anywhere
+		for Width(f, size, word) > width && utf8.RuneCountInString(word) > 1 {
+			runes := []rune(word)
+			n := 1
+			for n < len(runes)-1 && Width(f, size, string(runes[:n+1])) <= width {
+				n++
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Write writes the document as PDF
+func (d *Document) Write(w io.Writer) error {
+	bw := &countingWriter{w: bufio.NewWriter(w)}
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, bw.n)
+		fmt.Fprintf(bw, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	bw.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1 is the catalog, 2 the page tree, then the fonts, then each page
+	// followed by its content
+	firstPage := 3 + len(fontNames)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), d.size.Width, d.size.Height))
+	var fonts []string
+	for i, name := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, 3+i))
+	}
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			strings.Join(fonts, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.Bytes()))
+	}
+
+	xref := bw.n
+	fmt.Fprintf(bw, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(bw, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(bw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// countingWriter counts the bytes written, for the cross-reference table,
+// and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += n
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
+
+// literal encodes s as a PDF string in WinAnsi
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		c, ok := winAnsi(r)
+		switch {
+		case !ok:
+			b.WriteByte('?')
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsi returns the WinAnsi code of r
+func winAnsi(r rune) (byte, bool) {
+	switch {
+	case r >= ' ' && r <= '~', r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	case r == '\t' || r == '\n':
+		return ' ', true
+	}
+	c, ok := winAnsiExtra[r]
+	return c, ok
+}
+
+// winAnsiExtra are the characters WinAnsi has in 0x80–0x9f
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// The widths of ' ' to '~' in thousandths of the font size, from the fonts'
+// metrics; other characters are taken to be as wide as a digit
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [...]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
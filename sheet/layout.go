@@ -0,0 +1,140 @@
+package sheet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// Layout says where in the sheet the names and the orders are. Rows are
+// counted from 0.
+type Layout struct {
+	// Header is the row with the names
+	Header int
+	// FirstRow is the first row with orders, the row after Header when 0
+	FirstRow int
+	// LastRow is the last row with orders, to skip totals and notes below;
+	// the last row of the sheet when 0
+	LastRow int
+	// MaxRows limits the rows with orders to this many of the last ones, 0
+	// for all
+	MaxRows int
+}
+
+// FirstDataRow returns the first row with orders
+func (l Layout) FirstDataRow() int {
+	if l.FirstRow != 0 {
+		return l.FirstRow
+	}
+	return l.Header + 1
+}
+
+// HeaderRow returns the row with the names, or an ErrMalformed error
+// explaining why the sheet doesn't have one at Header
+func (l Layout) HeaderRow(rows [][]string) ([]string, error) {
+	if l.Header >= len(rows) {
+		return nil, fmt.Errorf("%w: the sheet has %d rows, so there is no header row %d (check -header)", ErrMalformed, len(rows), l.Header)
+	}
+	header := rows[l.Header]
+	if len(header) < 2 {
+		return nil, fmt.Errorf("%w: header row %d has no names, expected a date column followed by one column per person (check -header)", ErrMalformed, l.Header)
+	}
+	return header, nil
+}
+
+// DataRows returns the rows with orders, and the index of the first of them
+// in rows
+func (l Layout) DataRows(rows [][]string) (int, [][]string) {
+	first, last := l.FirstDataRow(), len(rows)-1
+	if l.LastRow != 0 && l.LastRow < last {
+		last = l.LastRow
+	}
+	if l.MaxRows > 0 && last-first+1 > l.MaxRows {
+		first = last - l.MaxRows + 1
+	}
+	if first > last {
+		return first, nil
+	}
+	return first, rows[first : last+1]
+}
+
+// Normalize pads or truncates every row to the width of the header row, so a
+// short row reads as empty cells. Columns past the header have no name and
+// can't hold orders anyway.
+func (l Layout) Normalize(rows [][]string) [][]string {
+	if len(rows) <= l.Header {
+		return rows
+	}
+	width := len(rows[l.Header])
+	for i, row := range rows {
+		if len(row) < width {
+			rows[i] = append(row, make([]string, width-len(row))...)
+		} else if len(row) > width {
+			rows[i] = row[:width]
+		}
+	}
+	return rows
+}
+
+// FindRow returns the row for the day of t, which is the time in whatever
+// zone the reader is in. Rows without a date are skipped.
+func (l Layout) FindRow(rows [][]string, t time.Time) ([]string, error) {
+	year, month, day := t.Date()
+
+	_, data := l.DataRows(rows)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w, the sheet has no rows with orders (%v)", ErrNoRow, t)
+	}
+	for _, row := range data {
+		if len(row) == 0 {
+			continue
+		}
+		date, err := ParseDate(row[0])
+		if err != nil {
+			continue
+		}
+		if date.Year() == year && date.Month() == month && date.Day() == day {
+			return row, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w (%v)", ErrNoRow, t)
+}
+
+// Dates returns the dates of the rows with orders, in the order of the
+// sheet. Rows without a date are skipped.
+func (l Layout) Dates(rows [][]string) []time.Time {
+	_, data := l.DataRows(rows)
+	var dates []time.Time
+	for _, row := range data {
+		if len(row) == 0 {
+			continue
+		}
+		if date, err := ParseDate(row[0]); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// Orders returns the orders in the row for the day of t
+func (l Layout) Orders(rows [][]string, t time.Time) (*order.Overview, error) {
+	header, err := l.HeaderRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	row, err := l.FindRow(rows, t)
+	if err != nil {
+		return nil, err
+	}
+	return order.New(Cells(header), Cells(row)), nil
+}
+
+// Cells returns the cells of row after the date column
+func Cells(row []string) []string {
+	if len(row) == 0 {
+		return nil
+	}
+	return row[1:]
+}
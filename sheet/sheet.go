@@ -0,0 +1,106 @@
+// Package sheet reads the lunch order sheet: a spreadsheet published as CSV,
+// e.g. through Google Sheets' "Publish to the web", with a header row of
+// names and below it one row per day starting with the date.
+//
+//	c := &sheet.Client{URL: csvURL, Layout: sheet.Layout{Header: 3}}
+//	oo, err := c.Orders(ctx, time.Now())
+package sheet
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// DateLayout is the format of the dates in the first column
+const DateLayout = "2006-01-02"
+
+// ParseDate reads the date in a cell of the first column, ignoring the
+// spaces typed around it
+func ParseDate(cell string) (time.Time, error) {
+	return time.Parse(DateLayout, strings.TrimSpace(cell))
+}
+
+var (
+	// ErrNoRow means the sheet has no row with the requested date
+	ErrNoRow = errors.New("no row found for today")
+	// ErrMalformed means the sheet isn't laid out the way the Layout says
+	ErrMalformed = errors.New("the sheet is not laid out as expected")
+)
+
+// Client fetches a sheet published as CSV
+type Client struct {
+	// URL is where the CSV is published
+	URL string
+	// HTTPClient fetches the URL, http.DefaultClient when nil
+	HTTPClient *http.Client
+	Layout     Layout
+}
+
+// Fetch returns the rows of the sheet as they are. Rows may have different
+// lengths.
+func (c *Client) Fetch(ctx context.Context) ([][]string, error) {
+	body, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parse(body)
+}
+
+// parse reads the rows of the CSV in body
+func parse(body []byte) ([][]string, error) {
+	// people add and remove columns all the time, so don't insist on every
+	// row having the same length
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	return r.ReadAll()
+}
+
+// Rows returns the rows of the sheet, all as wide as the header row, see
+// Layout.Normalize
+func (c *Client) Rows(ctx context.Context) ([][]string, error) {
+	rows, err := c.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Layout.Normalize(rows), nil
+}
+
+// Orders returns the orders in the row for the day of t
+func (c *Client) Orders(ctx context.Context, t time.Time) (*order.Overview, error) {
+	rows, err := c.Rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Layout.Orders(rows, t)
+}
+
+// get returns the body of the URL
+func (c *Client) get(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", c.URL, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,51 @@
+package sheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkCSV returns a sheet with a title and a note above the header, 60
+// people and a row for each of days days
+func benchmarkCSV(days int) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	header := []string{"Date"}
+	for i := 0; i < 60; i++ {
+		header = append(header, fmt.Sprintf("Person %d", i))
+	}
+	w.Write([]string{"Lunch sheet"})
+	w.Write([]string{"notes"})
+	w.Write(nil)
+	w.Write(header)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < days; d++ {
+		row := []string{start.AddDate(0, 0, d).Format(DateLayout)}
+		for i := 1; i < len(header); i++ {
+			row = append(row, []string{"", "Poke bowl", "Club sandwich €7.50"}[(d+i)%3])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return b.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	body := benchmarkCSV(250)
+	layout := Layout{Header: 3}
+	day := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rows, err := parse(body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := layout.Orders(layout.Normalize(rows), day); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
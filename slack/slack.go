@@ -0,0 +1,158 @@
+// Package slack talks to a Slack app: it checks the signature of the
+// requests Slack sends and calls the few Web API methods lunchweb needs to
+// take orders in a modal and send direct messages.
+//
+//	if err := slack.Verify(secret, r.Header, body, time.Now()); err != nil {
+//		// not from Slack
+//	}
+//	err := client.OpenView(ctx, triggerID, view)
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxAge is how old a request may be, so recorded ones can't be replayed
+const maxAge = 5 * time.Minute
+
+// Verify checks that a request with header and body was signed with the
+// app's signing secret a moment before now
+func Verify(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sent, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("no request timestamp")
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("request timestamp too far off: %s", age)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// Client calls the Slack Web API with a bot token
+type Client struct {
+	// Token is the bot token, "xoxb-..."
+	Token string
+	// URL is the API, https://slack.com/api when empty
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// OpenView opens a modal in answer to the interaction with triggerID
+func (c *Client) OpenView(ctx context.Context, triggerID string, view interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"trigger_id": triggerID, "view": view})
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, "views.open", "application/json; charset=utf-8", body, nil)
+}
+
+// User is what Slack knows about a user that helps finding their name in
+// the sheet
+type User struct {
+	ID       string `json:"id"`
+	RealName string `json:"real_name"`
+	Profile  struct {
+		DisplayName string `json:"display_name"`
+		// Email needs the users:read.email scope
+		Email string `json:"email"`
+	} `json:"profile"`
+}
+
+// UserInfo returns the user with the ID
+func (c *Client) UserInfo(ctx context.Context, id string) (*User, error) {
+	var result struct {
+		User User `json:"user"`
+	}
+	// read methods only take form arguments
+	body := []byte(url.Values{"user": {id}}.Encode())
+	if err := c.call(ctx, "users.info", "application/x-www-form-urlencoded", body, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+// LookupByEmail returns the user with the email address, which needs the
+// users:read.email scope
+func (c *Client) LookupByEmail(ctx context.Context, email string) (*User, error) {
+	var result struct {
+		User User `json:"user"`
+	}
+	body := []byte(url.Values{"email": {email}}.Encode())
+	if err := c.call(ctx, "users.lookupByEmail", "application/x-www-form-urlencoded", body, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+// PostMessage posts text to a channel, or as a direct message from the app
+// when channel is a user ID. It needs the chat:write scope.
+func (c *Client) PostMessage(ctx context.Context, channel, text string) error {
+	body, err := json.Marshal(map[string]string{"channel": channel, "text": text})
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, "chat.postMessage", "application/json; charset=utf-8", body, nil)
+}
+
+// call posts body to an API method and decodes the answer into v
+func (c *Client) call(ctx context.Context, method, contentType string, body []byte, v interface{}) error {
+	base := c.URL
+	if base == "" {
+		base = "https://slack.com/api"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: unexpected status %s", method, resp.Status)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("%s: invalid response: %v", method, err)
+	}
+	// Slack answers errors with 200 and "ok": false
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("%s: invalid response: %v", method, err)
+	}
+	if !status.OK {
+		return fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,384 @@
+// Package teams talks to Microsoft Teams as a Bot Framework bot: it checks
+// the tokens the Bot Framework sends with activities and answers them.
+//
+//	if err := verifier.Verify(ctx, r.Header.Get("Authorization"), activity.ServiceURL); err != nil {
+//		// not from the Bot Framework
+//	}
+//	err := client.Reply(ctx, activity, teams.CardAttachment(card))
+package teams
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OpenIDURL describes the keys the Bot Framework signs tokens with
+	OpenIDURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	// TokenURL issues the tokens a bot calls the Bot Framework with
+	TokenURL = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+
+	issuer = "https://api.botframework.com"
+	// keysTTL is how long the signing keys are cached
+	keysTTL = 24 * time.Hour
+	// skew is how far off the clocks of the Bot Framework and ours may be
+	skew = 5 * time.Minute
+)
+
+// Activity is the part of a Bot Framework activity lunchweb reads
+type Activity struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Text       string `json:"text"`
+	ServiceURL string `json:"serviceUrl"`
+	From       struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		AADObjectID string `json:"aadObjectId"`
+	} `json:"from"`
+	Conversation struct {
+		ID string `json:"id"`
+	} `json:"conversation"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Verifier checks the JWT the Bot Framework sends in the Authorization
+// header of every activity
+type Verifier struct {
+	// AppID is the bot's Microsoft app ID, the token's audience
+	AppID string
+	// OpenIDURL is OpenIDURL when empty
+	OpenIDURL string
+	// Client fetches the keys, http.DefaultClient when nil
+	Client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// Verify checks that authorization, "Bearer <JWT>", was signed by the Bot
+// Framework for the bot and the activity's serviceURL
+func (v *Verifier) Verify(ctx context.Context, authorization, serviceURL string) error {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return fmt.Errorf("no bearer token")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("unexpected algorithm %q", header.Alg)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+
+	var claims struct {
+		Issuer     string `json:"iss"`
+		Audience   string `json:"aud"`
+		Expiry     int64  `json:"exp"`
+		NotBefore  int64  `json:"nbf"`
+		ServiceURL string `json:"serviceurl"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != issuer:
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case claims.Audience != v.AppID:
+		return fmt.Errorf("token not issued for this bot")
+	case now.After(time.Unix(claims.Expiry, 0).Add(skew)):
+		return fmt.Errorf("token expired")
+	case claims.NotBefore != 0 && now.Add(skew).Before(time.Unix(claims.NotBefore, 0)):
+		return fmt.Errorf("token not valid yet")
+	case claims.ServiceURL != serviceURL:
+		// or a valid token could make the bot send its own elsewhere
+		return fmt.Errorf("token not issued for service URL %q", serviceURL)
+	}
+	return nil
+}
+
+// key returns the signing key with the ID, fetching the keys when it isn't
+// known or they are old
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok && time.Since(v.fetched) < keysTTL {
+		return key, nil
+	}
+	// keys rotate, but don't let unknown IDs fetch all the time
+	if time.Since(v.fetched) > time.Minute {
+		keys, err := v.fetchKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch signing keys: %v", err)
+		}
+		v.keys, v.fetched = keys, time.Now()
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	openID := v.OpenIDURL
+	if openID == "" {
+		openID = OpenIDURL
+	}
+	var config struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, v.Client, openID, &config); err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, v.Client, config.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// Client answers activities as the bot
+type Client struct {
+	AppID    string
+	Password string
+	// TokenURL is TokenURL when empty
+	TokenURL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Member is a member of a conversation
+type Member struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+// Member returns who sent a, with their email address
+func (c *Client) Member(ctx context.Context, a *Activity) (*Member, error) {
+	var m Member
+	u := fmt.Sprintf("%s/v3/conversations/%s/members/%s", strings.TrimSuffix(a.ServiceURL, "/"), url.PathEscape(a.Conversation.ID), url.PathEscape(a.From.ID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.do(ctx, req, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Reply answers a with a message of text and attachments, e.g. a card
+func (c *Client) Reply(ctx context.Context, a *Activity, text string, attachments ...interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":        "message",
+		"text":        text,
+		"attachments": attachments,
+		"replyToId":   a.ID,
+	})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/v3/conversations/%s/activities/%s", strings.TrimSuffix(a.ServiceURL, "/"), url.PathEscape(a.Conversation.ID), url.PathEscape(a.ID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(ctx, req, nil)
+}
+
+// CardAttachment wraps an adaptive card for Reply
+func CardAttachment(card interface{}) interface{} {
+	return map[string]interface{}{
+		"contentType": "application/vnd.microsoft.card.adaptive",
+		"content":     card,
+	}
+}
+
+// CardResponse is the answer to an "adaptiveCard/action" invoke activity
+// that replaces the card with card
+func CardResponse(card interface{}) interface{} {
+	return map[string]interface{}{
+		"statusCode": 200,
+		"type":       "application/vnd.microsoft.card.adaptive",
+		"value":      card,
+	}
+}
+
+// MessageResponse is the answer to an "adaptiveCard/action" invoke
+// activity that shows text instead of a new card
+func MessageResponse(text string) interface{} {
+	return map[string]interface{}{
+		"statusCode": 200,
+		"type":       "application/vnd.microsoft.activity.message",
+		"value":      text,
+	}
+}
+
+// do sends req with the bot's token and decodes the answer into v
+func (c *Client) do(ctx context.Context, req *http.Request, v interface{}) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// accessToken returns a token for the Bot Framework, getting a new one with
+// the app's credentials shortly before the last one expires
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = TokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.AppID},
+		"client_secret": {c.Password},
+		"scope":         {"https://api.botframework.com/.default"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: unexpected status %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("token endpoint: %v", err)
+	}
+	c.token = token.AccessToken
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - skew)
+	return c.token, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("malformed token: %v", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("malformed token: %v", err)
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
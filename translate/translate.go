@@ -0,0 +1,123 @@
+// Package translate translates menu items and orders with LibreTranslate or
+// DeepL.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Translator translates texts into the target language, e.g. "en",
+// detecting the language they are in
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, texts []string, target string) ([]string, error)
+}
+
+// LibreTranslate uses a LibreTranslate server, like a self-hosted one
+type LibreTranslate struct {
+	URL string
+	// APIKey is needed by servers that require one
+	APIKey string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (l *LibreTranslate) Name() string { return "libretranslate" }
+
+func (l *LibreTranslate) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"q":       texts,
+		"source":  "auto",
+		"target":  target,
+		"format":  "text",
+		"api_key": l.APIKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(l.URL, "/")+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := do(l.Client, req, &result); err != nil {
+		return nil, err
+	}
+	if len(result.TranslatedText) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(result.TranslatedText), len(texts))
+	}
+	return result.TranslatedText, nil
+}
+
+// DeepL uses the DeepL API
+type DeepL struct {
+	Key string
+	// URL is the API, https://api-free.deepl.com for keys ending in ":fx"
+	// and https://api.deepl.com for others when empty
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (d *DeepL) Name() string { return "deepl" }
+
+func (d *DeepL) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	base := d.URL
+	if base == "" {
+		base = "https://api.deepl.com"
+		if strings.HasSuffix(d.Key, ":fx") {
+			base = "https://api-free.deepl.com"
+		}
+	}
+	form := url.Values{"target_lang": {strings.ToUpper(target)}, "text": texts}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/v2/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.Key)
+	var result struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := do(d.Client, req, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Translations) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(result.Translations), len(texts))
+	}
+	translated := make([]string, len(texts))
+	for i, t := range result.Translations {
+		translated[i] = t.Text
+	}
+	return translated, nil
+}
+
+// do sends req and decodes the JSON response into v
+func do(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
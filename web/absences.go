@@ -0,0 +1,317 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// absence is a stretch of days someone is away, from and to (2006-01-02)
+// inclusive
+type absence struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Note string `json:"note,omitempty"`
+	// Calendar is whether it comes from their calendar, which replaces it
+	// on every sync
+	Calendar bool `json:"calendar,omitempty"`
+}
+
+// absenceStore keeps the absences by name in <data-dir>/absences.json
+type absenceStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newAbsenceStore(dataDir string) *absenceStore {
+	return &absenceStore{path: filepath.Join(dataDir, "absences.json")}
+}
+
+func (a *absenceStore) load() (map[string][]absence, error) {
+	all := make(map[string][]absence)
+	b, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", a.path, err)
+	}
+	return all, nil
+}
+
+// Of returns the absences of name, the first ones first
+func (a *absenceStore) Of(name string) ([]absence, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	return all[name], nil
+}
+
+// Away returns who is away on a date (2006-01-02), by name
+func (a *absenceStore) Away(date string) (map[string]absence, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	away := make(map[string]absence)
+	for name, list := range all {
+		for _, ab := range list {
+			if ab.From <= date && date <= ab.To {
+				away[name] = ab
+				break
+			}
+		}
+	}
+	return away, nil
+}
+
+// Update changes the absences of name with change and stores them sorted
+func (a *absenceStore) Update(name string, change func(list []absence) ([]absence, error)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all, err := a.load()
+	if err != nil {
+		return err
+	}
+	list, err := change(all[name])
+	if err != nil {
+		return err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].From < list[j].From })
+	if len(list) == 0 {
+		delete(all, name)
+	} else {
+		all[name] = list
+	}
+	return a.save(all)
+}
+
+func (a *absenceStore) save(all map[string][]absence) error {
+	b, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(a.path, b)
+}
+
+// Purge deletes the absences that ended before cutoff (2006-01-02)
+func (a *absenceStore) Purge(cutoff string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all, err := a.load()
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for name, list := range all {
+		kept := list[:0]
+		for _, ab := range list {
+			if ab.To < cutoff {
+				purged++
+				continue
+			}
+			kept = append(kept, ab)
+		}
+		if len(kept) == 0 {
+			delete(all, name)
+		} else {
+			all[name] = kept
+		}
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, a.save(all)
+}
+
+// withoutAbsent leaves out the columns of the people away on a date
+// (2006-01-02), unless they ordered anyway, so they don't count as
+// expected to order
+func (s *Server) withoutAbsent(date string, names, cells []string) ([]string, []string, error) {
+	away, err := s.absences.Away(date)
+	if err != nil || len(away) == 0 {
+		return names, cells, err
+	}
+	keys := make(map[string]bool)
+	for name := range away {
+		keys[order.NameKey(name)] = true
+	}
+	var keptNames, keptCells []string
+	for i, column := range names {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		person, _ := order.SplitColumnName(order.NormalizeName(column))
+		if keys[order.NameKey(person)] && strings.TrimSpace(cell) == "" {
+			continue
+		}
+		keptNames = append(keptNames, column)
+		keptCells = append(keptCells, cell)
+	}
+	return keptNames, keptCells, nil
+}
+
+// awayOn returns the names of the people away on a date, sorted
+func (s *Server) awayOn(date string) ([]string, error) {
+	away, err := s.absences.Away(date)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range away {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return order.LessName(names[i], names[j]) })
+	return names, nil
+}
+
+// validCalendarURL checks a calendar address given on /me, which unlike
+// -closures must not be a file on the server
+func validCalendarURL(source string) error {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "webcal") {
+		return fmt.Errorf("invalid calendar address %q, expected an https:// or webcal:// URL", source)
+	}
+	return nil
+}
+
+// calendarClient fetches the calendars given on /me. Anyone can give one,
+// so it only connects to public addresses over https, also when redirected,
+// and checks the address it dials so a name can't resolve to the server's
+// own network either.
+var calendarClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return fmt.Errorf("refusing to connect to %s, not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("refusing to follow a redirect to %s", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// sharedAddressSpace is 100.64.0.0/10, carrier-grade NAT, which
+// net.IP.IsPrivate leaves out
+var sharedAddressSpace = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is on the internet rather than the machine,
+// the local network or a cloud's metadata service (169.254.169.254)
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// syncAbsences replaces the absences of name from their calendar with its
+// busy all-day events in the coming year
+func (s *Server) syncAbsences(ctx context.Context, name, source string) (int, error) {
+	if err := validCalendarURL(source); err != nil {
+		return 0, err
+	}
+	events, err := fetchCalendar(ctx, calendarClient, source)
+	if err != nil {
+		return 0, err
+	}
+	now := s.cfg.now().In(s.cfg.location)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	var synced []absence
+	for _, e := range events {
+		// yearly events are birthdays and anniversaries rather than time off
+		if e.Free || e.Yearly {
+			continue
+		}
+		days := e.Days(from, to)
+		if len(days) == 0 {
+			continue
+		}
+		synced = append(synced, absence{From: days[0], To: days[len(days)-1], Note: e.Summary, Calendar: true})
+	}
+	err = s.absences.Update(name, func(list []absence) ([]absence, error) {
+		kept := synced
+		for _, ab := range list {
+			if !ab.Calendar {
+				kept = append(kept, ab)
+			}
+		}
+		return kept, nil
+	})
+	return len(synced), err
+}
+
+// runAbsences syncs the absences from everyone's calendar on /me every
+// interval until ctx is done
+func (s *Server) runAbsences(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runJob("absences", func() { s.syncAllAbsences(ctx) })
+	}
+}
+
+// syncAllAbsences syncs the absences from the calendar of everyone who set
+// one on /me
+func (s *Server) syncAllAbsences(ctx context.Context) {
+	all, err := s.preferences.All()
+	if err != nil {
+		slog.Error("could not read preferences", "err", err)
+		return
+	}
+	for name, pref := range all {
+		if pref.Calendar == "" {
+			continue
+		}
+		if _, err := s.syncAbsences(ctx, name, pref.Calendar); err != nil {
+			slog.Error("could not sync absences from calendar", "name", name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+var flagAddresses = flag.String("addresses", "", `JSON file with everyone's email addresses by sheet name, like {"Joe": ["joe@example.com"]}, to take orders by email from`)
+
+// loadAddresses reads the -addresses file
+func loadAddresses(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var addresses map[string][]string
+	if err := json.Unmarshal(b, &addresses); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, list := range addresses {
+		for _, a := range list {
+			if _, err := mail.ParseAddress(a); err != nil {
+				return nil, fmt.Errorf("%s: %s: invalid address %q: %v", path, name, a, err)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// nameOf returns the sheet name of whoever has the email address, or ""
+// for an unknown address. addr may include a display name, as in
+// "Joe <joe@example.com>".
+func (c *config) nameOf(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		addr = parsed.Address
+	}
+	for name, list := range c.addresses {
+		for _, a := range list {
+			if parsed, err := mail.ParseAddress(a); err == nil && strings.EqualFold(parsed.Address, addr) {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// adminAction is a button on the admin page, posted to /admin/<Name>
+type adminAction struct {
+	Name  string
+	Label string
+	// Run performs the action and returns a message for the admin
+	Run func(s *Server, r *http.Request) (string, error)
+}
+
+// adminActions lists the actions available on the admin page
+var adminActions = []adminAction{
+	{
+		Name:  "refresh",
+		Label: "Refresh sheet now",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if err := s.sheet.Refresh(r.Context(), requestLogger(r)); err != nil {
+				return "", err
+			}
+			return "Sheet refreshed", nil
+		},
+	},
+}
+
+// configEntry is a flag as shown on the admin page
+type configEntry struct {
+	Name  string
+	Value string
+	Usage string
+}
+
+// isAdmin reports whether the logged-in user is listed in -admin
+func (s *Server) isAdmin(r *http.Request) bool {
+	return identityFromRequest(r).listedIn(s.cfg.admins)
+}
+
+// requireAdmin only lets users listed in -admin through
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.cfg.admins) == 0 {
+			s.notFound(w, r)
+			return
+		}
+		if !s.isAdmin(r) {
+			requestLogger(r).Warn("non-admin tried to access admin page", "user", identityFromRequest(r).String())
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// sameOrigin guards state-changing requests against cross-site forms: the
+// browser has to say where the request comes from, and it has to be this
+// host. Browsers say so for every POST, only a GET or HEAD may go without.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return r.Method == http.MethodGet || r.Method == http.MethodHead
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	var config []configEntry
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value != "" && isSecretFlag(f.Name) {
+			value = "(hidden)"
+		} else {
+			value = redactURLs(value)
+		}
+		config = append(config, configEntry{f.Name, value, f.Usage})
+	})
+
+	data := map[string]interface{}{
+		"Message":     r.URL.Query().Get("msg"),
+		"Maintenance": inMaintenance(),
+		"Sheet":       s.sheet.Status(),
+		"Problems":    monitor.Problems(),
+		"Actions":     adminActions,
+		"Menus":       s.adminMenus(r),
+		"Delivery":    s.adminDelivery(r),
+		"Config":      config,
+	}
+	render(w, r, "admin", data)
+}
+
+// adminDelivery returns how far today's delivery got, nil when it can't be read
+func (s *Server) adminDelivery(r *http.Request) *deliveryView {
+	delivery, err := s.deliveryOn(s.cfg.now().Format(timeLayout))
+	if err != nil {
+		requestLogger(r).Error("could not read delivery state", "err", err)
+	}
+	return delivery
+}
+
+// adminMenu is a vendor on the admin page with the menu uploaded for it
+type adminMenu struct {
+	Vendor   *Vendor
+	Uploaded bool
+}
+
+func (s *Server) adminMenus(r *http.Request) []adminMenu {
+	var list []adminMenu
+	for _, v := range s.cfg.vendors {
+		name, err := s.menus.Upload(v)
+		if err != nil {
+			requestLogger(r).Error("could not read menu", "vendor", v.Name, "err", err)
+		}
+		list = append(list, adminMenu{v, name != ""})
+	}
+	return list
+}
+
+func (s *Server) handleAdminAction(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/admin/")
+	for _, action := range adminActions {
+		if action.Name != name {
+			continue
+		}
+		logger := requestLogger(r).With("action", name, "user", identityFromRequest(r).String())
+		msg, err := action.Run(s, r)
+		if err != nil {
+			logger.Warn("admin action failed", "err", err)
+			msg = "Failed: " + err.Error()
+		} else {
+			logger.Info("admin action")
+		}
+		s.audit.Record(r, "admin/"+name, msg)
+		http.Redirect(w, r, "/admin?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	s.notFound(w, r)
+}
+
+// secretFlags hold a secret without saying so in their name, like the token
+// in the path of a Slack webhook, or private data like -allergies-csvurl
+var secretFlags = map[string]bool{
+	"csvurl":           true,
+	"allergies-csvurl": true,
+	"sentry-dsn":       true,
+	"slack-webhook":    true,
+	"notify-webhook":   true,
+	"error-webhook":    true,
+	"announce-webhook": true,
+	"mqtt":             true,
+	"tts-url":          true,
+}
+
+// isSecretFlag reports whether a flag's value should not be displayed
+func isSecretFlag(name string) bool {
+	if secretFlags[name] {
+		return true
+	}
+	for _, s := range []string{"password", "secret", "key", "token"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagURLRe matches the URLs in the value of a flag, which may be one of a
+// comma separated list or follow a name, like confluence=https://…
+var flagURLRe = regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^,\s]+`)
+
+// redactURLs hides the user info and query of the URLs in the value of a
+// flag, where URLs tend to keep credentials, like url.URL.Redacted does for
+// passwords
+func redactURLs(value string) string {
+	return flagURLRe.ReplaceAllStringFunc(value, func(match string) string {
+		u, err := url.Parse(match)
+		if err != nil {
+			return "xxxxx"
+		}
+		if u.User != nil {
+			u.User = url.User("xxxxx")
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = "xxxxx"
+		}
+		return u.String()
+	})
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var flagAllergies = flag.String("allergies", "", `JSON file with everyone's allergies by sheet name, like {"Joe": ["gluten", "peanuts"]}, to warn about orders that may contain them`)
+var flagAllergiesCSVURL = flag.String("allergies-csvurl", "", "public URL of a sheet tab as CSV with a name and allergies in each row, added to -allergies")
+
+// allergyTabTTL is how long the -allergies-csvurl tab is cached; allergies
+// change rarely
+const allergyTabTTL = 10 * time.Minute
+
+// loadAllergies reads the -allergies file
+func loadAllergies(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profiles map[string][]string
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return profiles, nil
+}
+
+// newAllergyTab returns the cache of the -allergies-csvurl tab, or nil
+func newAllergyTab() *sheetCache {
+	if *flagAllergiesCSVURL == "" {
+		return nil
+	}
+	client := &sheet.Client{
+		URL:        *flagAllergiesCSVURL,
+		HTTPClient: &http.Client{Timeout: *flagFetchTimeout},
+	}
+	return newSheetCache(client.Fetch, allergyTabTTL, 0)
+}
+
+// allergies returns everyone's allergies by the order.NameKey of their sheet
+// name: -allergies plus the rows of the -allergies-csvurl tab, whose cells
+// may list several allergies separated by commas. A header row naming no one
+// is harmless.
+func (s *Server) allergies(ctx context.Context, logger *slog.Logger) map[string][]string {
+	profiles := make(map[string][]string, len(s.cfg.allergies))
+	for name, allergens := range s.cfg.allergies {
+		key := order.NameKey(name)
+		profiles[key] = append(profiles[key], allergens...)
+	}
+	if s.allergyTab == nil {
+		return profiles
+	}
+	rows, err := s.allergyTab.Rows(ctx, logger)
+	if err != nil {
+		logger.Warn("could not fetch allergies", "err", err)
+		return profiles
+	}
+	for _, row := range rows {
+		if len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		name := order.NameKey(row[0])
+		for _, cell := range row[1:] {
+			for _, allergen := range strings.Split(cell, ",") {
+				if allergen = strings.TrimSpace(allergen); allergen != "" {
+					profiles[name] = append(profiles[name], allergen)
+				}
+			}
+		}
+	}
+	return profiles
+}
+
+// allergyWarning is an order that may contain something its person is
+// allergic to
+type allergyWarning struct {
+	Name      string
+	Order     string
+	Allergens []string
+}
+
+func (w allergyWarning) String() string {
+	return fmt.Sprintf("%s: %s may contain %s", w.Name, w.Order, strings.Join(w.Allergens, ", "))
+}
+
+// allergyWarnings checks every order against the allergies of who ordered it
+func (s *Server) allergyWarnings(ctx context.Context, logger *slog.Logger, oo *order.Overview) []allergyWarning {
+	profiles := s.allergies(ctx, logger)
+	if len(profiles) == 0 {
+		return nil
+	}
+	var warnings []allergyWarning
+	for _, li := range oo.LineItems() {
+		if found := order.Allergens(li.Order, profiles[order.NameKey(li.Name)]); len(found) > 0 {
+			warnings = append(warnings, allergyWarning{li.Name, li.Order, found})
+		}
+	}
+	return warnings
+}
+
+// seesAllergiesOf reports whether the visitor may see the allergies of name,
+// which are health data: only name themselves, going by their login, and
+// admins can
+func (s *Server) seesAllergiesOf(r *http.Request, names []string, name string) bool {
+	if s.isAdmin(r) {
+		return true
+	}
+	return name != "" && identityFromRequest(r) != nil && order.NameKey(s.claimedName(r, names)) == order.NameKey(name)
+}
+
+// allergyNotes returns the allergens of the warnings the visitor may see,
+// see seesAllergiesOf, by name, as the page shows them next to the orders
+func (s *Server) allergyNotes(r *http.Request, logger *slog.Logger, oo *order.Overview) map[string]string {
+	notes := make(map[string]string)
+	for _, w := range s.allergyWarnings(r.Context(), logger, oo) {
+		if s.seesAllergiesOf(r, oo.Names, w.Name) {
+			notes[w.Name] = strings.Join(w.Allergens, ", ")
+		}
+	}
+	return notes
+}
+
+// menuItemText is a menu item as offered to name, with a warning when it may
+// contain one of their allergies, for the Slack modal and Teams card only
+// they see
+func menuItemText(item order.MenuItem, allergies map[string][]string, name string) string {
+	found := order.Allergens(item.Name, allergies[order.NameKey(name)])
+	if len(found) == 0 {
+		return item.String()
+	}
+	return fmt.Sprintf("⚠️ %s (may contain %s)", item, strings.Join(found, ", "))
+}
+
+// allergyText lists the warnings for a notification, or "" without any
+func allergyText(warnings []allergyWarning) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nAllergy warnings, please check with the restaurant:\n")
+	for _, w := range warnings {
+		b.WriteString("⚠️ " + w.String() + "\n")
+	}
+	return b.String()
+}
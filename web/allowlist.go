@@ -0,0 +1,74 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRs parses the -allow-cidr values. Plain addresses are accepted as
+// single-host ranges.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					return nil, fmt.Errorf("invalid address %q", s)
+				}
+				bits := 8 * len(ip.To4())
+				if bits == 0 {
+					bits = 128
+				}
+				s = fmt.Sprintf("%s/%d", s, bits)
+			}
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+	}
+	return nets, nil
+}
+
+// allowCIDRs rejects requests from clients outside the given ranges. Without
+// ranges every client is allowed.
+func (s *Server) allowCIDRs(nets []*net.IPNet, next http.Handler) http.Handler {
+	if len(nets) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := s.clientIP(r)
+		for _, n := range nets {
+			if ip != nil && n.Contains(ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		requestLogger(r).Warn("client not in allowed ranges", "ip", ip.String())
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+}
+
+// clientIP returns the address of the client. Behind a proxy (-trust-proxy)
+// that is the last address the proxy appended to X-Forwarded-For.
+func (s *Server) clientIP(r *http.Request) net.IP {
+	if s.cfg.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return net.ParseIP(strings.TrimSpace(parts[len(parts)-1]))
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
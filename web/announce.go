@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+var flagTTSURL = flag.String("tts-url", "", "text-to-speech service to serve /announce.mp3 with, {text} in the URL is replaced by the announcement, e.g. http://piper:5000/?text={text}")
+var flagAnnounceWebhook = flag.String("announce-webhook", "", `POST the announcement as JSON, {"message": "..."}, to this URL at -reminder time, e.g. a Home Assistant webhook speaking it on the office speaker`)
+
+// announcement is what the office speaker says: how long orders stay open
+// and how many ordered so far
+func (s *Server) announcement(ctx context.Context, logger *slog.Logger, now time.Time) (string, error) {
+	oo, err := s.ordersOn(ctx, logger, now)
+	if err != nil {
+		return "", err
+	}
+	subject := "Lunch orders"
+	if vendor := s.cfg.vendorName(now); vendor != "" {
+		subject = fmt.Sprintf("Lunch orders for %s", vendor)
+	}
+	ordered := fmt.Sprintf("%d of %d people have ordered", len(oo.LineItems()), oo.MaxCount())
+	deadline, ok := s.cfg.deadlineOn(now)
+	switch {
+	case !ok:
+		return fmt.Sprintf("%s are open, %s.", subject, ordered), nil
+	case now.After(deadline):
+		return fmt.Sprintf("%s are closed, %s.", subject, ordered), nil
+	default:
+		return fmt.Sprintf("%s close in %s, %s.", subject, spokenDuration(deadline.Sub(now)), ordered), nil
+	}
+}
+
+// spokenDuration writes d in words to the minute, like "1 hour and 5
+// minutes", as speech engines read "1h 05m" badly
+func spokenDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		return "less than a minute"
+	}
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch hours := minutes / 60; {
+	case hours == 0:
+		return plural(minutes, "minute")
+	case minutes%60 == 0:
+		return plural(hours, "hour")
+	default:
+		return plural(hours, "hour") + " and " + plural(minutes%60, "minute")
+	}
+}
+
+// speech keeps the last announcement spoken by -tts-url, which speakers
+// fetch a few times in a row
+var speech struct {
+	sync.Mutex
+	text        string
+	contentType string
+	audio       []byte
+}
+
+// handleAnnounce speaks the announcement at /announce.mp3 for the office
+// speaker, rendered by the -tts-url service
+func (s *Server) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	if s.cfg.ttsURL == "" {
+		s.notFound(w, r)
+		return
+	}
+	text, err := s.announcement(r.Context(), logger, s.cfg.now())
+	if err != nil {
+		logger.Error("could not make announcement", "err", err)
+		http.Error(w, "could not read the orders", http.StatusBadGateway)
+		return
+	}
+
+	speech.Lock()
+	defer speech.Unlock()
+	if speech.text != text {
+		contentType, audio, err := synthesize(r.Context(), s.cfg.ttsURL, text)
+		if err != nil {
+			logger.Error("could not synthesize announcement", "err", err)
+			http.Error(w, "could not synthesize the announcement", http.StatusBadGateway)
+			return
+		}
+		speech.text, speech.contentType, speech.audio = text, contentType, audio
+	}
+	w.Header().Set("Content-Type", speech.contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(speech.audio)
+}
+
+// synthesize asks the -tts-url service ttsURL to speak text
+func synthesize(ctx context.Context, ttsURL, text string) (contentType string, audio []byte, err error) {
+	u := strings.ReplaceAll(ttsURL, "{text}", url.QueryEscape(text))
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	audio, err = io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return "", nil, err
+	}
+	contentType = resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = "audio/mpeg"
+	}
+	return contentType, audio, nil
+}
+
+// announce posts the announcement to -announce-webhook
+func (s *Server) announce(ctx context.Context, now time.Time) error {
+	text, err := s.announcement(ctx, slog.Default(), now)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"message": text})
+	if err != nil {
+		return err
+	}
+	return notify.PostJSON(ctx, s.cfg.announceWebhook, body, nil)
+}
@@ -0,0 +1,195 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// sheetMonitor watches the sheet for structural changes that would break
+// the page and alerts through the notifiers, once per problem per day
+type sheetMonitor struct {
+	mu sync.Mutex
+	// header is the header row seen on the previous check
+	header []string
+	// alerted maps problems to the day they were reported, today only
+	alerted map[string]string
+	// current are the problems found by the last check
+	current []string
+}
+
+var monitor = &sheetMonitor{alerted: make(map[string]string)}
+
+// check looks for anomalies in rows at time t and returns the problems
+// found
+func (m *sheetMonitor) check(cfg *config, rows [][]string, t time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var problems []string
+	headerRow := cfg.layout.Header
+	if len(rows) <= headerRow {
+		problems = append(problems, fmt.Sprintf("the sheet has %d rows, so there is no header row %d", len(rows), headerRow))
+		m.current = problems
+		return problems
+	}
+	header := rows[headerRow]
+
+	// header row moved: it looks like data now, or last check's header
+	// turned up elsewhere
+	if len(header) > 0 {
+		if _, err := sheet.ParseDate(header[0]); err == nil {
+			problems = append(problems, fmt.Sprintf("row %d should be the header but starts with a date (%s)", headerRow, header[0]))
+		}
+	}
+	if m.header != nil && !sameRow(header, m.header) {
+		for i, row := range rows {
+			if i != headerRow && sameRow(row, m.header) {
+				problems = append(problems, fmt.Sprintf("the header row moved from row %d to row %d", headerRow, i))
+				break
+			}
+		}
+	}
+
+	// column count changed
+	if m.header != nil && len(header) != len(m.header) {
+		problems = append(problems, fmt.Sprintf("the number of columns changed from %d to %d", len(m.header), len(header)))
+	}
+
+	// duplicate dates
+	seen := make(map[string]int)
+	today := t.Format(timeLayout)
+	foundToday := false
+	start, data := cfg.layout.DataRows(rows)
+	for i, row := range data {
+		if len(row) == 0 {
+			continue
+		}
+		date, err := sheet.ParseDate(row[0])
+		if err != nil {
+			continue
+		}
+		day := date.Format(timeLayout)
+		index := start + i
+		if prev, ok := seen[day]; ok {
+			problems = append(problems, fmt.Sprintf("date %s appears on both row %d and row %d", day, prev, index))
+		}
+		seen[day] = index
+		if day == today {
+			foundToday = true
+		}
+	}
+
+	// today's row missing
+	if !foundToday && cfg.todayRowBy.IsSet() && !t.Before(cfg.todayRowBy.On(t)) && cfg.isOrderingDay(t) {
+		problems = append(problems, fmt.Sprintf("there is no row for today (%s) yet", today))
+	}
+
+	m.header = append([]string(nil), header...)
+	m.current = problems
+	return problems
+}
+
+// newProblems returns the problems not reported yet today and marks them as
+// reported
+func (m *sheetMonitor) newProblems(problems []string, t time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	today := t.Format(timeLayout)
+	// problems of earlier days may be reported again anyway
+	for p, day := range m.alerted {
+		if day != today {
+			delete(m.alerted, p)
+		}
+	}
+	var fresh []string
+	for _, p := range problems {
+		if m.alerted[p] != today {
+			m.alerted[p] = today
+			fresh = append(fresh, p)
+		}
+	}
+	return fresh
+}
+
+// Problems returns the problems found by the last check
+func (m *sheetMonitor) Problems() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// isOrderingDay reports whether we expect a row for the day. With vendors
+// configured those are the days a vendor is assigned, otherwise weekdays,
+// unless -closures has the office closed.
+func (c *config) isOrderingDay(t time.Time) bool {
+	if closures.Reason(t.In(c.location).Format(timeLayout)) != "" {
+		return false
+	}
+	if len(c.vendors) > 0 {
+		return c.vendorFor(t) != nil
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+func sameRow(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// runSheetMonitor checks the sheet every interval and alerts about new
+// problems
+func (s *Server) runSheetMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runJob("sheet monitor", func() { s.checkSheet(ctx) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) checkSheet(ctx context.Context) {
+	// the sheet owner is restructuring on purpose, don't cry wolf
+	if inMaintenance() {
+		return
+	}
+	rows, err := s.sheet.Rows(ctx, slog.Default())
+	if err != nil {
+		// repeated fetch failures are reported by the sheet cache
+		return
+	}
+
+	t := s.cfg.now()
+	problems := monitor.check(s.cfg, rows, t)
+	fresh := monitor.newProblems(problems, t)
+	if len(fresh) == 0 {
+		return
+	}
+	slog.Warn("sheet anomalies", "problems", fresh)
+
+	msg := notify.Message{
+		Subject: "LunchWeb: the order sheet looks broken",
+		Text:    "Problems found in the order sheet:\n\n- " + strings.Join(fresh, "\n- ") + "\n",
+	}
+	if err := s.notifyAll(ctx, nil, msg); err != nil {
+		slog.Error("could not send sheet anomaly alert", "err", err)
+	}
+}
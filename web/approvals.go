@@ -0,0 +1,282 @@
+package web
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagApprovalMax = flag.String("approval-max", "", "orders priced over this amount, e.g. 15.00, wait for approval before going to the restaurant")
+var flagApprovalBlock stringList
+var flagApprovers stringList
+
+func init() {
+	flag.Var(&flagApprovalBlock, "approval-block", "orders mentioning this, e.g. lobster, wait for approval before going to the restaurant (repeatable)")
+	flag.Var(&flagApprovers, "approver", "login or verified email of a budget owner who approves orders, requires authentication (repeatable, default: the -admin users)")
+}
+
+// approval is the decision of a budget owner on an order that breaks the
+// approval rules
+type approval struct {
+	// Order is the order decided on; once it changes, it needs a new decision
+	Order    string    `json:"order"`
+	Approved bool      `json:"approved"`
+	By       string    `json:"by"`
+	Time     time.Time `json:"time"`
+}
+
+// approvalStore keeps the decisions of each day by name in one JSON file per
+// day under <data-dir>/approvals
+type approvalStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newApprovalStore(dataDir string) *approvalStore {
+	return &approvalStore{dir: filepath.Join(dataDir, "approvals")}
+}
+
+// Load returns the decisions on a date (2006-01-02) by name
+func (a *approvalStore) Load(date string) (map[string]approval, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.load(date)
+}
+
+func (a *approvalStore) load(date string) (map[string]approval, error) {
+	day := make(map[string]approval)
+	b, err := os.ReadFile(filepath.Join(a.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return day, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &day); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return day, nil
+}
+
+// Set stores the decision on the order of name on a date
+func (a *approvalStore) Set(date, name string, decision approval) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	day, err := a.load(date)
+	if err != nil {
+		return err
+	}
+	day[name] = decision
+	b, err := json.MarshalIndent(day, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(a.dir, date+".json"), b)
+}
+
+// Purge deletes the decisions of every day before cutoff (2006-01-02)
+func (a *approvalStore) Purge(cutoff string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	files, err := os.ReadDir(a.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// approvalReason returns why li needs approval, "" when it doesn't
+func (c *config) approvalReason(li *order.LineItem) string {
+	var reasons []string
+	if price, ok := li.Price(); ok && c.approvalMax > 0 && price > c.approvalMax {
+		reasons = append(reasons, fmt.Sprintf("%s is over %s", price, c.approvalMax))
+	}
+	text := strings.ToLower(li.Order)
+	for _, blocked := range c.approvalBlock {
+		if strings.Contains(text, strings.ToLower(blocked)) {
+			reasons = append(reasons, blocked)
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// approvalItem is an order that needs approval
+type approvalItem struct {
+	Name   string
+	Order  string
+	Reason string
+	// Decision is nil while it waits for one
+	Decision *approval
+}
+
+// Held reports whether the order stays away from the restaurant
+func (a *approvalItem) Held() bool {
+	return a.Decision == nil || !a.Decision.Approved
+}
+
+// approvalsOn returns the orders in oo on a date (2006-01-02) that need
+// approval, with the decision on them
+func (s *Server) approvalsOn(date string, oo *order.Overview) ([]*approvalItem, error) {
+	if s.cfg.approvalMax == 0 && len(s.cfg.approvalBlock) == 0 {
+		return nil, nil
+	}
+	var items []*approvalItem
+	var day map[string]approval
+	for _, li := range oo.LineItems() {
+		reason := s.cfg.approvalReason(li)
+		if reason == "" {
+			continue
+		}
+		if day == nil {
+			var err error
+			if day, err = s.approvals.Load(date); err != nil {
+				return nil, err
+			}
+		}
+		item := &approvalItem{Name: li.Name, Order: li.Order, Reason: reason}
+		if decision, ok := day[li.Name]; ok && decision.Order == li.Order {
+			item.Decision = &decision
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// vendorOrders returns the orders in oo on a date that go to the restaurant,
+// without those waiting for approval or rejected, and the ones that need
+// approval
+func (s *Server) vendorOrders(date string, oo *order.Overview) (*order.Overview, []*approvalItem, error) {
+	items, err := s.approvalsOn(date, oo)
+	if err != nil {
+		return oo, nil, err
+	}
+	var held []string
+	for _, item := range items {
+		if item.Held() {
+			held = append(held, item.Name)
+		}
+	}
+	if len(held) == 0 {
+		return oo, items, nil
+	}
+	return oo.Without(held...), items, nil
+}
+
+// approvalText is the part of the order message about the orders left out,
+// "" when none are
+func approvalText(items []*approvalItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		switch {
+		case item.Decision == nil:
+			fmt.Fprintf(&b, "- %s: %s (%s), waiting for approval\n", item.Name, item.Order, item.Reason)
+		case !item.Decision.Approved:
+			fmt.Fprintf(&b, "- %s: %s (%s), not approved by %s\n", item.Name, item.Order, item.Reason, item.Decision.By)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\nLeft out:\n" + b.String()
+}
+
+// approvalView is today's orders that need approval on the page
+type approvalView struct {
+	Items []*approvalItem
+	// Approver can decide on them
+	Approver bool
+}
+
+// isApprover reports whether the logged-in user is a budget owner in
+// -approver, or an admin without -approver
+func (s *Server) isApprover(r *http.Request) bool {
+	if len(s.cfg.approvers) == 0 {
+		return s.isAdmin(r)
+	}
+	return identityFromRequest(r).listedIn(s.cfg.approvers)
+}
+
+// handleApproval approves or rejects the order of a person today
+func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	if !s.isApprover(r) {
+		http.Error(w, "only budget owners approve orders", http.StatusForbidden)
+		return
+	}
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	date := now.Format(timeLayout)
+	oo, err := s.todaysOrders(r.Context(), logger)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	items, err := s.approvalsOn(date, oo)
+	if err != nil {
+		logger.Error("could not read approvals", "err", err)
+		http.Error(w, "could not read approvals", http.StatusInternalServerError)
+		return
+	}
+	name, decided := r.FormValue("name"), r.FormValue("order")
+	var item *approvalItem
+	for _, it := range items {
+		if it.Name == name {
+			item = it
+		}
+	}
+	if item == nil || item.Order != decided {
+		// changed in the meantime, the approver should look again
+		http.Error(w, fmt.Sprintf("%s has no such order needing approval today, reload the page", name), http.StatusConflict)
+		return
+	}
+	decision := approval{
+		Order:    item.Order,
+		Approved: r.FormValue("do") == "approve",
+		By:       identityFromRequest(r).String(),
+		Time:     now,
+	}
+	if err := s.approvals.Set(date, name, decision); err != nil {
+		logger.Error("could not store approval", "err", err)
+		http.Error(w, "could not store the approval", http.StatusInternalServerError)
+		return
+	}
+	action := "approval/reject"
+	if decision.Approved {
+		action = "approval/approve"
+	}
+	s.audit.Record(r, action, fmt.Sprintf("%s: %q", name, item.Order))
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "approval")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
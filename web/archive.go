@@ -0,0 +1,268 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// Snapshot is the archived state of one day's orders
+type Snapshot struct {
+	Date   string    `json:"date"`
+	Taken  time.Time `json:"taken"`
+	Final  bool      `json:"final"`
+	Names  []string  `json:"names"`
+	Orders []string  `json:"orders"`
+}
+
+// Overview returns the archived orders as an order.Overview
+func (s *Snapshot) Overview() *order.Overview {
+	return order.New(s.Names, s.Orders)
+}
+
+// Time returns the snapshot's date at midnight UTC, for its weekday, month
+// and year
+func (s *Snapshot) Time() time.Time {
+	t, _ := time.Parse(timeLayout, s.Date)
+	return t
+}
+
+// archiveStore keeps one JSON file per day under <data-dir>/archive
+type archiveStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newArchiveStore(dataDir string) *archiveStore {
+	return &archiveStore{dir: filepath.Join(dataDir, "archive")}
+}
+
+// Save stores s, replacing an earlier snapshot of the same day
+func (a *archiveStore) Save(s *Snapshot) error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return err
+	}
+	// readers never see half a snapshot
+	return writeFileAtomic(filepath.Join(a.dir, s.Date+".json"), b)
+}
+
+// Load returns the snapshot for a date (2006-01-02), or nil when there is none
+func (a *archiveStore) Load(date string) (*Snapshot, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b, err := os.ReadFile(filepath.Join(a.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return &s, nil
+}
+
+// Dates returns the archived dates, oldest first
+func (a *archiveStore) Dates() ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := os.ReadDir(a.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dates []string
+	for _, e := range entries {
+		name := e.Name()
+		if date, ok := strings.CutSuffix(name, ".json"); ok {
+			if _, err := time.Parse(timeLayout, date); err == nil {
+				dates = append(dates, date)
+			}
+		}
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+// Range returns the snapshots between from and to (inclusive, 2006-01-02),
+// oldest first. Empty bounds are open.
+func (a *archiveStore) Range(from, to string) ([]*Snapshot, error) {
+	dates, err := a.Dates()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, date := range dates {
+		if (from != "" && date < from) || (to != "" && date > to) {
+			continue
+		}
+		s, err := a.Load(date)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return snapshots, nil
+}
+
+// archiveRows stores every day in the sheet up to today that isn't final in
+// the archive yet. Past days are final; today becomes final at
+// -archive-final.
+func (s *Server) archiveRows(rows [][]string) (int, error) {
+	header, err := s.cfg.layout.HeaderRow(rows)
+	if err != nil {
+		return 0, err
+	}
+	now := s.cfg.now()
+	today := now.Format(timeLayout)
+	todayFinal := s.cfg.archiveFinal.IsSet() && !now.Before(s.cfg.archiveFinal.On(now))
+
+	saved := 0
+	_, data := s.cfg.layout.DataRows(rows)
+	for _, row := range data {
+		if len(row) == 0 {
+			continue
+		}
+		date, err := sheet.ParseDate(row[0])
+		if err != nil {
+			continue
+		}
+		day := date.Format(timeLayout)
+		if day > today || !s.cfg.retained(day) {
+			continue
+		}
+
+		existing, err := s.archive.Load(day)
+		if err != nil {
+			return saved, err
+		}
+		if existing != nil && existing.Final {
+			continue
+		}
+
+		names, cells, err := s.entries.Overlay(day, sheet.Cells(header), sheet.Cells(row))
+		if err != nil {
+			return saved, err
+		}
+		current := order.New(names, cells)
+		// the absent don't count in the participation
+		if names, cells, err = s.withoutAbsent(day, names, cells); err != nil {
+			return saved, err
+		}
+		snap := &Snapshot{
+			Date:   day,
+			Taken:  now,
+			Final:  day < today || todayFinal,
+			Names:  names,
+			Orders: cells,
+		}
+		if existing == nil && len(current.LineItems()) == 0 {
+			// nobody ordered, e.g. weekends and holidays
+			continue
+		}
+		// the changes start with the orders in today's first snapshot; for a
+		// past day seen for the first time, when they came in is unknown
+		if existing != nil {
+			if err := s.orderChanges.Add(day, existing.Taken, diffOrders(existing.Overview(), current, existing.Taken, now)); err != nil {
+				return saved, err
+			}
+		} else if day == today {
+			if err := s.orderChanges.Add(day, time.Time{}, diffOrders(order.New(nil, nil), current, time.Time{}, now)); err != nil {
+				return saved, err
+			}
+		}
+		// an empty snapshot replaces one whose orders were all taken back
+		if err := s.archive.Save(snap); err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// runArchiver snapshots the sheet every interval until ctx is done
+func (s *Server) runArchiver(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runJob("archiver", func() {
+			s.archiveNow(ctx)
+			if s.cfg.retain.IsSet() {
+				if _, err := s.purgeExpired(); err != nil {
+					slog.Error("could not purge archive", "err", err)
+				}
+			}
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveNow takes a snapshot unless the sheet is in maintenance, when its
+// columns may not make sense
+func (s *Server) archiveNow(ctx context.Context) (int, error) {
+	if inMaintenance() {
+		slog.Debug("skipping archive in maintenance mode")
+		return 0, nil
+	}
+	rows, err := s.sheet.Rows(ctx, slog.Default())
+	if err != nil {
+		return 0, err
+	}
+	saved, err := s.archiveRows(rows)
+	if err != nil {
+		slog.Error("could not archive orders", "err", err)
+		return saved, err
+	}
+	slog.Debug("archived orders", "days", saved)
+	return saved, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "archive",
+		Label: "Archive orders now",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			saved, err := s.archiveNow(r.Context())
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Archived %d days", saved), nil
+		},
+	})
+}
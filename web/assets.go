@@ -0,0 +1,90 @@
+package web
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// embeddedFiles holds the page templates and, in assets, the files served
+// under /static/. htmx.min.js is not checked in, make install downloads it;
+// without it the pages still work as plain forms and links.
+//
+//go:embed templates assets
+var embeddedFiles embed.FS
+
+// webFiles is where templates and assets are read from: embeddedFiles, or
+// -dev-dir in -dev
+var webFiles fs.FS = embeddedFiles
+
+// fingerprints maps the name of every asset to its name with a hash of its
+// content, e.g. htmx.min.js to htmx.min.0123456789.js, and fingerprinted
+// back again. setupAssets fills them once; -dev leaves them empty.
+var (
+	fingerprints  = map[string]string{}
+	fingerprinted = map[string]string{}
+)
+
+// setupAssets fingerprints the assets, so pages can link to names that
+// change with the content and browsers can cache them forever
+func setupAssets() error {
+	if devMode {
+		return nil
+	}
+	return fs.WalkDir(webFiles, "assets", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		b, err := fs.ReadFile(webFiles, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		name := strings.TrimPrefix(p, "assets/")
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:5]) + ext
+		fingerprints[name] = hashed
+		fingerprinted[hashed] = name
+		return nil
+	})
+}
+
+// assetURL returns the URL of the named asset, fingerprinted unless in -dev
+// or the asset is missing. Templates call it as asset.
+func assetURL(name string) string {
+	if hashed, ok := fingerprints[name]; ok {
+		return "/static/" + hashed
+	}
+	return "/static/" + name
+}
+
+// handleStatic serves a file from assets. Fingerprinted names never change
+// content, so they are cached for a year. Directories and dot files are not
+// found.
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean(strings.TrimPrefix(r.URL.Path, "/static/"))
+	if strings.HasPrefix(path.Base(name), ".") {
+		s.notFound(w, r)
+		return
+	}
+	if original, ok := fingerprinted[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		name = original
+	}
+	name = path.Join("assets", name)
+	if info, err := fs.Stat(webFiles, name); err != nil || info.IsDir() {
+		s.notFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, webFiles, name)
+}
+
+// isHTMX reports whether htmx sent the request from a page, rather than the
+// browser loading it
+func isHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
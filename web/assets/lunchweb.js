@@ -0,0 +1,40 @@
+// Buttons with data-copy put the text of the element it selects on the
+// clipboard.
+document.addEventListener("click", function (event) {
+	var button = event.target.closest("[data-copy]");
+	if (!button) {
+		return;
+	}
+	var source = document.querySelector(button.dataset.copy);
+	if (!source) {
+		return;
+	}
+	var text = source.value || source.textContent;
+	if (!navigator.clipboard) {
+		// only secure pages may use the clipboard, leave copying to the user
+		source.select();
+		return;
+	}
+	navigator.clipboard.writeText(text).then(function () {
+		button.textContent = "Copied";
+	});
+});
+
+// Elements with data-left count down from that many seconds in minutes
+// between the refreshes of the page. Counting from when the element showed
+// up rather than by the browser's clock keeps -fake-now and clock skew out.
+setInterval(function () {
+	document.querySelectorAll("[data-left]").forEach(function (el) {
+		if (!el.dataset.shown) {
+			el.dataset.shown = Date.now();
+		}
+		var left = el.dataset.left * 1000 - (Date.now() - el.dataset.shown);
+		var minutes = Math.ceil(left / 60000);
+		if (minutes <= 0) {
+			el.textContent = "no time";
+			return;
+		}
+		var h = Math.floor(minutes / 60), m = minutes % 60;
+		el.textContent = h ? h + "h " + (m < 10 ? "0" : "") + m + "m" : m + "m";
+	});
+}, 10000);
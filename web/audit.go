@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditEntry records who did what, and when
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// auditLog is an append-only JSON lines file of administrative and write
+// actions
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	// loc is the time zone of the entries' times
+	loc *time.Location
+}
+
+func newAuditLog(dir string, loc *time.Location) *auditLog {
+	return &auditLog{path: filepath.Join(dir, "audit.jsonl"), loc: loc}
+}
+
+// Record appends an entry for the user making r. Pass a nil request for
+// actions the server takes on its own.
+func (a *auditLog) Record(r *http.Request, action, detail string) {
+	entry := auditEntry{Time: appClock.Now().In(a.loc), User: "system", Action: action, Detail: detail}
+	if r != nil {
+		entry.User = identityFromRequest(r).String()
+	}
+	if err := a.append(entry); err != nil {
+		slog.Error("could not write audit log", "action", action, "user", entry.User, "err", err)
+	}
+}
+
+func (a *auditLog) append(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns up to limit entries, newest first
+func (a *auditLog) Entries(limit int) ([]auditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Purge deletes the entries of every day before cutoff (2006-01-02), as they
+// name who did what
+func (a *auditLog) Purge(cutoff string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var kept bytes.Buffer
+	purged := 0
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		var e auditEntry
+		if json.Unmarshal(line, &e) == nil && e.Time.In(a.loc).Format(timeLayout) < cutoff {
+			purged++
+			continue
+		}
+		kept.Write(line)
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, writeFileAtomic(a.path, kept.Bytes())
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.audit.Entries(500)
+	if err != nil {
+		requestLogger(r).Error("could not read audit log", "err", err)
+		http.Error(w, "could not read audit log", http.StatusInternalServerError)
+		return
+	}
+	render(w, r, "audit", map[string]interface{}{"Entries": entries})
+}
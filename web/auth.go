@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Identity is the authenticated user behind a request
+type Identity struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	// EmailVerified is set when the identity provider vouched for Email
+	EmailVerified bool `json:"email_verified,omitempty"`
+}
+
+// String returns the most human friendly identifier available
+func (id *Identity) String() string {
+	switch {
+	case id == nil:
+		return "anonymous"
+	case id.Name != "":
+		return id.Name
+	case id.Email != "":
+		return id.Email
+	}
+	return id.Subject
+}
+
+// listedIn reports whether the user is one of users, by subject or by an
+// email the provider verified. Display names are left out, anyone can pick
+// theirs.
+func (id *Identity) listedIn(users []string) bool {
+	if id == nil {
+		return false
+	}
+	for _, u := range users {
+		if u == id.Subject || id.EmailVerified && id.Email != "" && strings.EqualFold(u, id.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// identityFromRequest returns the logged-in user, or nil when authentication
+// is disabled
+func identityFromRequest(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey).(*Identity)
+	return id
+}
+
+// authenticator identifies the user behind a request
+type authenticator interface {
+	// Authenticate returns the identity of the user making r. When the user
+	// can't be identified it writes a challenge or redirect to w and returns
+	// nil.
+	Authenticate(w http.ResponseWriter, r *http.Request) *Identity
+}
+
+// newAuthenticator returns the authenticator configured through the flags, or
+// nil when the site is public
+func newAuthenticator() (authenticator, error) {
+	switch {
+	case *flagOIDCIssuer != "":
+		return newOIDCAuth(*flagOIDCIssuer, *flagOIDCClientID, *flagOIDCClientSecret, *flagOIDCRedirectURL)
+	case *flagHtpasswd != "":
+		return newBasicAuthFromFile(*flagHtpasswd)
+	case *flagAuthUser != "":
+		if *flagAuthPassword == "" {
+			return nil, fmt.Errorf("-auth-user requires -auth-password")
+		}
+		return &basicAuth{users: map[string]string{*flagAuthUser: *flagAuthPassword}, plain: true}, nil
+	}
+	return nil, nil
+}
+
+// requireAuth only lets identified users through and makes their identity
+// available to handlers via identityFromRequest
+func requireAuth(auth authenticator, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.Authenticate(w, r)
+		if id == nil {
+			return
+		}
+		requestLogger(r).Debug("authenticated request", "user", id.String())
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey, id)))
+	})
+}
+
+// basicAuth checks HTTP basic auth credentials against a set of users
+type basicAuth struct {
+	// users maps user names to password hashes (or plain passwords)
+	users map[string]string
+	plain bool
+}
+
+// newBasicAuthFromFile reads an htpasswd file. Only bcrypt ("htpasswd -B")
+// and SHA1 ("htpasswd -s") hashes are supported.
+func newBasicAuthFromFile(path string) (*basicAuth, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected user:hash", path, n)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("%s:%d: unsupported hash for %q, use bcrypt (htpasswd -B)", path, n, user)
+		}
+		users[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users", path)
+	}
+	return &basicAuth{users: users}, nil
+}
+
+func (b *basicAuth) Authenticate(w http.ResponseWriter, r *http.Request) *Identity {
+	user, password, ok := r.BasicAuth()
+	if ok && b.check(user, password) {
+		return &Identity{Subject: user, Name: user}
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="LunchWeb", charset="UTF-8"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return nil
+}
+
+func (b *basicAuth) check(user, password string) bool {
+	hash, ok := b.users[user]
+	if !ok {
+		return false
+	}
+	switch {
+	case b.plain:
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
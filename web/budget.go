@@ -0,0 +1,138 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagBudgetDay = flag.String("budget-day", "", "lunch budget of a day, e.g. 150.00, shown with what's left of it on the page")
+var flagBudgetMonth = flag.String("budget-month", "", "lunch budget of a month, e.g. 2500.00, shown with what's left of it on the page and in the reports")
+var flagBudgetWarn = flag.Int("budget-warn", 80, "warn once this percentage of a budget is spent")
+
+// spend adds up the prices of the orders in oo. Shares without a price of
+// their own cost their part of the item; unpriced is how many orders have
+// no price at all.
+func spend(oo *order.Overview) (total order.Money, unpriced int) {
+	items := oo.LineItems()
+	shared := order.SharedItems(items)
+	for _, li := range items {
+		price, ok := li.Price()
+		if !ok {
+			price, ok = order.SharedPrice(shared, li.Name)
+		}
+		if ok {
+			total += price
+		} else {
+			unpriced++
+		}
+	}
+	return total, unpriced
+}
+
+// budgetLine is the spend against one budget
+type budgetLine struct {
+	// Label is "today" or "this month"
+	Label string
+	Cap   order.Money
+	Spent order.Money
+	// Warn is whether -budget-warn percent are spent
+	Warn bool
+}
+
+// Left returns what is left of the budget, negative when it's exceeded
+func (b *budgetLine) Left() order.Money {
+	return b.Cap - b.Spent
+}
+
+// Percent returns how much of the budget is spent
+func (b *budgetLine) Percent() int {
+	return int(100 * b.Spent / b.Cap)
+}
+
+// String describes the line, e.g. "Budget today: €42.00 of €150.00 spent,
+// €108.00 left"
+func (b *budgetLine) String() string {
+	text := fmt.Sprintf("Budget %s: %s of %s spent, ", b.Label, b.Spent, b.Cap)
+	if left := b.Left(); left < 0 {
+		return text + fmt.Sprintf("%s over", -left)
+	}
+	return text + fmt.Sprintf("%s left", b.Left())
+}
+
+// budgetView is the spend against the budgets on a day
+type budgetView struct {
+	Lines []*budgetLine
+	// Unpriced is how many of the day's orders have no price, so the spend
+	// is more than shown
+	Unpriced int
+}
+
+// budgetOn returns the spend against -budget-day and -budget-month on the
+// day of t with the orders that go to the restaurant, vendor. It is nil
+// without budgets.
+func (s *Server) budgetOn(t time.Time, vendor *order.Overview) (*budgetView, error) {
+	if s.cfg.budgetDay == 0 && s.cfg.budgetMonth == 0 {
+		return nil, nil
+	}
+	today, unpriced := spend(vendor)
+	view := &budgetView{Unpriced: unpriced}
+	if s.cfg.budgetDay > 0 {
+		view.Lines = append(view.Lines, s.cfg.budgetLine("today", s.cfg.budgetDay, today))
+	}
+	if s.cfg.budgetMonth > 0 {
+		date := t.Format(timeLayout)
+		// the archive may have a snapshot of today already, the live
+		// orders replace it
+		snapshots, err := s.archive.Range(date[:8]+"01", date)
+		if err != nil {
+			return nil, err
+		}
+		month := today
+		for _, snap := range snapshots {
+			if snap.Date != date {
+				spent, _ := spend(snap.Overview())
+				month += spent
+			}
+		}
+		view.Lines = append(view.Lines, s.cfg.budgetLine("this month", s.cfg.budgetMonth, month))
+	}
+	return view, nil
+}
+
+func (c *config) budgetLine(label string, budget, spent order.Money) *budgetLine {
+	return &budgetLine{
+		Label: label,
+		Cap:   budget,
+		Spent: spent,
+		Warn:  100*spent >= order.Money(c.budgetWarn)*budget,
+	}
+}
+
+// budgetText is the part of the order message about the budgets on the day
+// of t, "" without them
+func (s *Server) budgetText(t time.Time, vendor *order.Overview) string {
+	view, err := s.budgetOn(t, vendor)
+	if err != nil {
+		slog.Error("could not add up the budget", "err", err)
+	}
+	if view == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range view.Lines {
+		b.WriteString(line.String())
+		if line.Warn {
+			b.WriteString(" ⚠️")
+		}
+		b.WriteString("\n")
+	}
+	if view.Unpriced > 0 {
+		fmt.Fprintf(&b, "%d orders have no price and aren't counted.\n", view.Unpriced)
+	}
+	return b.String()
+}
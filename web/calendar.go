@@ -0,0 +1,181 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var flagCalendarInvite = flag.Bool("calendar-invite", false, "at the deadline, email everyone who ordered and is in -addresses a calendar invitation for the delivery with all orders (requires -delivery-time and -smtp-addr)")
+var flagDeliveryTime timeOfDay
+
+func init() {
+	flag.Var(&flagDeliveryTime, "delivery-time", "time of day lunch is usually delivered, e.g. 12:30, for -calendar-invite")
+}
+
+// deliveryDuration is how long the delivery event lasts in calendars
+const deliveryDuration = 30 * time.Minute
+
+// deliveryInvitation is the email inviting everyone who ordered on the day
+// of t to the delivery. Sending it again, e.g. after a late change, updates
+// the event it created.
+func (s *Server) deliveryInvitation(ctx context.Context, t time.Time) (notify.Message, error) {
+	oo, err := s.ordersOn(ctx, slog.Default(), t)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	var attendees, uninvited []string
+	for _, li := range oo.LineItems() {
+		if list := s.cfg.addresses[li.Name]; len(list) > 0 {
+			attendees = append(attendees, list[0])
+		} else {
+			uninvited = append(uninvited, li.Name)
+		}
+	}
+	if len(attendees) == 0 {
+		return notify.Message{}, fmt.Errorf("nobody who ordered has an address in -addresses")
+	}
+	if len(uninvited) > 0 {
+		slog.Warn("not inviting people without an address to the delivery", "names", uninvited)
+	}
+
+	start := s.cfg.deliveryTime.On(t)
+	title := fmt.Sprintf("Lunch delivery ~%s", start.Format("15:04"))
+	if vendor := s.cfg.vendorName(t); vendor != "" {
+		title += " from " + vendor
+	}
+	text := s.cfg.summary(oo) +
+		fmt.Sprintf("\n%d out of %d ordered something.\nSheet: %s\n", len(oo.LineItems()), oo.MaxCount(), s.cfg.sheetURL)
+
+	organizer := s.cfg.smtpFrom
+	if addr, err := mail.ParseAddress(organizer); err == nil {
+		organizer = addr.Address
+	}
+	_, domain, _ := strings.Cut(organizer, "@")
+	now := s.cfg.now()
+
+	var ics strings.Builder
+	line := func(format string, args ...interface{}) {
+		ics.WriteString(foldICS(fmt.Sprintf(format, args...)))
+		ics.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//lunchweb//EN")
+	line("METHOD:REQUEST")
+	line("BEGIN:VEVENT")
+	// one event per day, which later invitations update
+	line("UID:lunch-%s@%s", t.Format(timeLayout), domain)
+	line("SEQUENCE:%d", now.Unix())
+	line("DTSTAMP:%s", now.UTC().Format(icsTimeLayout))
+	line("DTSTART:%s", start.UTC().Format(icsTimeLayout))
+	line("DTEND:%s", start.Add(deliveryDuration).UTC().Format(icsTimeLayout))
+	line("SUMMARY:%s", escapeICS(title))
+	line("DESCRIPTION:%s", escapeICS(text))
+	line("ORGANIZER:mailto:%s", organizer)
+	for _, a := range attendees {
+		if addr, err := mail.ParseAddress(a); err == nil {
+			a = addr.Address
+		}
+		line("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=FALSE:mailto:%s", a)
+	}
+	line("TRANSP:TRANSPARENT")
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+
+	return notify.Message{
+		Subject:  title,
+		Text:     text,
+		To:       attendees,
+		Calendar: ics.String(),
+	}, nil
+}
+
+const icsTimeLayout = "20060102T150405Z"
+
+// escapeICS escapes text for an iCalendar TEXT value
+func escapeICS(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(strings.TrimSpace(text))
+}
+
+// foldICS splits a content line longer than 75 bytes into lines continued
+// by a space, without splitting a UTF-8 character
+func foldICS(line string) string {
+	var b strings.Builder
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// sendInvitation emails the delivery invitation for the day of t through
+// the email notifier. r is the request that triggered it, or nil for the
+// scheduled one.
+func (s *Server) sendInvitation(ctx context.Context, r *http.Request, t time.Time) error {
+	if inMaintenance() {
+		return fmt.Errorf("notifications are frozen in maintenance mode")
+	}
+	email := emailNotifier()
+	if email == nil {
+		return fmt.Errorf("no email notifier configured")
+	}
+	msg, err := s.deliveryInvitation(ctx, t)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	if err := email.Notify(ctx, msg); err != nil {
+		return err
+	}
+	slog.Info("sent delivery invitation", "subject", msg.Subject, "attendees", len(msg.To))
+	s.audit.Record(r, "notify/calendar", msg.Subject)
+	return nil
+}
+
+// runInvitations sends the delivery invitation at every deadline until ctx
+// is done
+func (s *Server) runInvitations(ctx context.Context) {
+	s.runBeforeDeadlines(ctx, "calendar invitation", 0, func(ctx context.Context, deadline time.Time) {
+		err := s.sendInvitation(ctx, nil, deadline)
+		switch {
+		case errors.Is(err, sheet.ErrNoRow):
+			slog.Info("not sending delivery invitation, the sheet has no row for today")
+		case err != nil:
+			slog.Error("could not send delivery invitation", "err", err)
+		}
+	})
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "calendar-invite",
+		Label: "Send or update today's delivery invitation",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if !s.cfg.calendarInvite {
+				return "", fmt.Errorf("calendar invitations are off, see -calendar-invite")
+			}
+			if err := s.sendInvitation(r.Context(), r, s.cfg.now()); err != nil {
+				return "", err
+			}
+			return "Delivery invitation sent", nil
+		},
+	})
+}
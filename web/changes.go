@@ -0,0 +1,224 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// orderChange is someone's order changing between two snapshots of a day,
+// From "" when they ordered and To "" when they took it back
+type orderChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Time is when the snapshot noticed it, and Since when the snapshot it
+	// was compared with was taken, zero for the day's first
+	Time  time.Time `json:"time"`
+	Since time.Time `json:"since"`
+}
+
+// String says what happened, "Joe changed Burger → Salad"
+func (c orderChange) String() string {
+	switch {
+	case c.From == "":
+		return fmt.Sprintf("%s ordered %s", c.Name, c.To)
+	case c.To == "":
+		return fmt.Sprintf("%s took back %s", c.Name, c.From)
+	}
+	return fmt.Sprintf("%s changed %s → %s", c.Name, c.From, c.To)
+}
+
+// diffOrders returns how the orders changed from before, the snapshot taken
+// at since, to after at t, by name
+func diffOrders(before, after *order.Overview, since, t time.Time) []orderChange {
+	type item struct{ name, order string }
+	was := make(map[string]item)
+	for _, li := range before.LineItems() {
+		was[order.NameKey(li.Name)] = item{li.Name, li.Order}
+	}
+	var changes []orderChange
+	for _, li := range after.LineItems() {
+		key := order.NameKey(li.Name)
+		prev, ok := was[key]
+		delete(was, key)
+		if !ok || prev.order != li.Order {
+			changes = append(changes, orderChange{Name: li.Name, From: prev.order, To: li.Order, Time: t, Since: since})
+		}
+	}
+	for _, prev := range was {
+		changes = append(changes, orderChange{Name: prev.name, From: prev.order, Time: t, Since: since})
+	}
+	slices.SortStableFunc(changes, func(a, b orderChange) int {
+		switch {
+		case order.LessName(a.Name, b.Name):
+			return -1
+		case order.LessName(b.Name, a.Name):
+			return 1
+		}
+		return 0
+	})
+	return changes
+}
+
+// changeStore keeps the changes of each day's orders, as the archiver sees
+// them, in one JSON file per day under <data-dir>/changes
+type changeStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newChangeStore(dataDir string) *changeStore {
+	return &changeStore{dir: filepath.Join(dataDir, "changes")}
+}
+
+// Load returns the changes to the orders on a date (2006-01-02), the
+// latest last
+func (c *changeStore) Load(date string) ([]orderChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load(date)
+}
+
+func (c *changeStore) load(date string) ([]orderChange, error) {
+	var changes []orderChange
+	b, err := os.ReadFile(filepath.Join(c.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &changes); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return changes, nil
+}
+
+// Add records the changes to the orders on a date since the snapshot taken
+// at since. They replace those recorded since the same snapshot before, so
+// trying again after the new snapshot couldn't be saved doesn't record them
+// twice.
+func (c *changeStore) Add(date string, since time.Time, changes []orderChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	earlier, err := c.load(date)
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(earlier, func(change orderChange) bool { return change.Since.Equal(since) })
+	if len(kept) == len(earlier) && len(changes) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(append(kept, changes...), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.dir, date+".json"), b)
+}
+
+// Purge deletes the changes of every day before cutoff (2006-01-02)
+func (c *changeStore) Purge(cutoff string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// changeView is a change on /changes, with whether it came after the
+// restaurant was called
+type changeView struct {
+	orderChange
+	Late bool
+}
+
+// handleChanges shows how a day's orders (?date=, today by default) changed
+// over the morning, to tell what the restaurant got when it was called
+// before the last changes. It answers JSON for ?format=json or when the
+// client asks for it.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	now := s.cfg.now()
+	date := r.FormValue("date")
+	if date == "" {
+		date = now.Format(timeLayout)
+	}
+	if _, err := time.Parse(timeLayout, date); err != nil {
+		http.Error(w, fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", date), http.StatusBadRequest)
+		return
+	}
+	logger := requestLogger(r)
+	changes, err := s.orderChanges.Load(date)
+	if err != nil {
+		logger.Error("could not read order changes", "err", err)
+		http.Error(w, "could not read the changes", http.StatusInternalServerError)
+		return
+	}
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if changes == nil {
+			changes = []orderChange{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"date": date, "changes": changes})
+		return
+	}
+
+	delivery, err := s.deliveries.Load(date)
+	if err != nil {
+		logger.Error("could not read delivery", "err", err)
+	}
+	// when the restaurant was called last, unless the delivery went back
+	// to collecting orders since
+	var ordered *deliveryChange
+	for i, change := range delivery {
+		switch change.State {
+		case deliveryCollecting:
+			ordered = nil
+		case deliveryOrdered:
+			ordered = &delivery[i]
+		}
+	}
+	var views []changeView
+	late := 0
+	for _, change := range changes {
+		view := changeView{orderChange: change, Late: ordered != nil && change.Time.After(ordered.Time)}
+		if view.Late {
+			late++
+		}
+		views = append(views, view)
+	}
+	render(w, r, "changes", map[string]interface{}{
+		"Date":    date,
+		"Today":   now.Format(timeLayout),
+		"Changes": views,
+		"Ordered": ordered,
+		"Late":    late,
+	})
+}
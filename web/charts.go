@@ -0,0 +1,57 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// chartPoint is one bar in a chart
+type chartPoint struct {
+	Label string
+	Value float64
+	// Text is shown for the value instead of formatting it, e.g. an amount
+	Text string
+}
+
+const (
+	chartWidth  = 600
+	chartHeight = 120
+)
+
+// barChart renders points as an inline SVG bar chart. Every bar has a
+// tooltip with its label and the value's Text, or else the value formatted
+// with format.
+func barChart(points []chartPoint, format string) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+	max := 0.0
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	step := float64(chartWidth) / float64(len(points))
+	gap := step * 0.2
+	for i, p := range points {
+		h := p.Value / max * (chartHeight - 1)
+		text := p.Text
+		if text == "" {
+			text = fmt.Sprintf(format, p.Value)
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s: %s</title></rect>`,
+			float64(i)*step+gap/2, chartHeight-h, step-gap, h,
+			template.HTMLEscapeString(p.Label),
+			template.HTMLEscapeString(text))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
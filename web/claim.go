@@ -0,0 +1,81 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// nameCookie remembers which sheet column a visitor claimed as theirs
+const nameCookie = "lunchweb_name"
+
+const claimDuration = 365 * 24 * time.Hour
+
+// claimedName returns the header name belonging to the visitor. Behind a
+// login it's the one of their login: by their verified email address in
+// -addresses, or else by the start of that address or their login. The name
+// claimed with "I am" only counts on a public site, as anyone can claim any
+// name there.
+func (s *Server) claimedName(r *http.Request, names []string) string {
+	if id := identityFromRequest(r); id != nil {
+		return s.cfg.loginName(id, names)
+	}
+	var claimed string
+	if signedCookie(r, nameCookie, &claimed) {
+		for _, name := range names {
+			if name == claimed {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// loginName returns the one of names belonging to a logged-in user, or "".
+// Their display name doesn't count, users pick it themselves at most
+// identity providers, and neither does an email address the provider didn't
+// verify.
+func (c *config) loginName(id *Identity, names []string) string {
+	if !id.EmailVerified {
+		return matchName(names, id.Subject)
+	}
+	if name := c.nameOf(id.Email); name != "" {
+		return matchName(names, name)
+	}
+	local, _, _ := strings.Cut(id.Email, "@")
+	return matchName(names, local, id.Subject)
+}
+
+// handleClaim stores the name picked in the "I am" form in a cookie. An
+// empty name forgets the claim. Instead of redirecting htmx, it tells the
+// page the claim changed so the order list reloads in place. Behind a login
+// the name comes from the login, so claims are refused.
+func handleClaim(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	if id := identityFromRequest(r); id != nil {
+		http.Error(w, fmt.Sprintf("you're logged in as %s, your name comes from that", id), http.StatusForbidden)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) > 100 {
+		http.Error(w, "name too long", http.StatusBadRequest)
+		return
+	}
+	if name == "" {
+		clearCookie(w, nameCookie)
+	} else if err := setSignedCookie(w, r, nameCookie, name, claimDuration); err != nil {
+		http.Error(w, "could not store name", http.StatusInternalServerError)
+		return
+	}
+	requestLogger(r).Debug("name claimed", "name", name)
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "claimed")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
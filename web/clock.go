@@ -0,0 +1,55 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var flagFakeNow = flag.String("fake-now", "", "pretend it is this time, e.g. 2024-05-02T10:00, for demos and screenshots")
+
+// clock tells the time for everything that depends on "today"
+type clock interface {
+	Now() time.Time
+}
+
+// appClock is the clock behind config.now
+var appClock clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// fakeClock starts at a fixed time and then keeps ticking, so pages still
+// show the time passing during a demo
+type fakeClock struct {
+	start   time.Time
+	started time.Time
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{start: start, started: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.start.Add(time.Since(c.started))
+}
+
+// setupClock applies -fake-now, which is in loc unless it has an offset
+func setupClock(loc *time.Location) error {
+	if *flagFakeNow == "" {
+		appClock = systemClock{}
+		return nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", timeLayout} {
+		if t, err := time.ParseInLocation(layout, *flagFakeNow, loc); err == nil {
+			appClock = newFakeClock(t)
+			return nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, *flagFakeNow); err == nil {
+		appClock = newFakeClock(t)
+		return nil
+	}
+	return fmt.Errorf("invalid -fake-now %q, expected e.g. 2024-05-02T10:00", *flagFakeNow)
+}
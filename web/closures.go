@@ -0,0 +1,178 @@
+package web
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/ical"
+)
+
+var flagClosures stringList
+
+func init() {
+	flag.Var(&flagClosures, "closures", "iCalendar file or URL (webcal:// too) of office closures like company holidays; its all-day events are days without ordering (repeatable)")
+}
+
+// closuresInterval is how often the -closures calendars are fetched again
+const closuresInterval = time.Hour
+
+// closureSet holds the days the office is closed, by date (2006-01-02), from
+// a month ago until a year ahead
+type closureSet struct {
+	mu   sync.RWMutex
+	days map[string]string
+}
+
+var closures = &closureSet{}
+
+// Reason returns why the office is closed on a date (2006-01-02), e.g.
+// "Christmas Day", or "" when it's open
+func (c *closureSet) Reason(date string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.days[date]
+}
+
+// Upcoming returns the closed days from the day of t on, the first ones first
+func (c *closureSet) Upcoming(t time.Time) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var days []string
+	for date := range c.days {
+		if date >= t.Format(timeLayout) {
+			days = append(days, date)
+		}
+	}
+	sort.Strings(days)
+	return days
+}
+
+func setupClosures() error {
+	for _, source := range flagClosures {
+		if strings.Contains(source, "://") {
+			u, err := url.Parse(source)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "webcal") {
+				return fmt.Errorf("invalid -closures %q, expected a file or an http(s):// or webcal:// URL", source)
+			}
+		}
+	}
+	return nil
+}
+
+// fetchCalendar reads the calendar at source, a file or URL fetched with
+// client
+func fetchCalendar(ctx context.Context, client *http.Client, source string) ([]ical.Event, error) {
+	if !strings.Contains(source, "://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ical.Parse(f)
+	}
+	// webcal:// is how calendar apps are offered a subscription
+	if rest, ok := strings.CutPrefix(source, "webcal://"); ok {
+		source = "https://" + rest
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", source, resp.Status)
+	}
+	return ical.Parse(io.LimitReader(resp.Body, 10<<20))
+}
+
+// loadClosures reads the -closures calendars into closures. When one can't
+// be read, the closures loaded before stay.
+func (s *Server) loadClosures(ctx context.Context) error {
+	now := s.cfg.now().In(s.cfg.location)
+	from, to := now.AddDate(0, -1, 0), now.AddDate(1, 0, 0)
+	// the events' days are dates, compared at midnight UTC
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := make(map[string]string)
+	for _, source := range s.cfg.closureSources {
+		events, err := fetchCalendar(ctx, http.DefaultClient, source)
+		if err != nil {
+			return fmt.Errorf("could not read office closures from %s: %v", source, err)
+		}
+		for _, e := range events {
+			reason := e.Summary
+			if reason == "" {
+				reason = "office closed"
+			}
+			for _, day := range e.Days(from, to) {
+				if days[day] == "" {
+					days[day] = reason
+				} else if !strings.Contains(days[day], reason) {
+					days[day] += ", " + reason
+				}
+			}
+		}
+	}
+	closures.mu.Lock()
+	closures.days = days
+	closures.mu.Unlock()
+	slog.Debug("loaded office closures", "days", len(days))
+	return nil
+}
+
+// runClosures fetches the -closures calendars every interval until ctx is
+// done, so changes to them show up without a restart
+func (s *Server) runClosures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runJob("closures", func() {
+			if err := s.loadClosures(ctx); err != nil {
+				slog.Error("could not reload office closures", "err", err)
+			}
+		})
+	}
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "closures",
+		Label: "Reload the office closures",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if len(s.cfg.closureSources) == 0 {
+				return "", fmt.Errorf("no closure calendars, see -closures")
+			}
+			if err := s.loadClosures(r.Context()); err != nil {
+				return "", err
+			}
+			upcoming := closures.Upcoming(s.cfg.now().In(s.cfg.location))
+			if len(upcoming) == 0 {
+				return "No office closures in the coming year", nil
+			}
+			return fmt.Sprintf("%d days closed in the coming year, the next on %s: %s",
+				len(upcoming), upcoming[0], closures.Reason(upcoming[0])), nil
+		},
+	})
+}
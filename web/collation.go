@@ -0,0 +1,20 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/datacamp/lunchweb/order"
+	"golang.org/x/text/language"
+)
+
+var flagLocale = flag.String("locale", "en", "language whose alphabetical order is used to sort names, e.g. de, sv or pl")
+
+func setupCollation() error {
+	tag, err := language.Parse(*flagLocale)
+	if err != nil {
+		return fmt.Errorf("invalid -locale %q: %v", *flagLocale, err)
+	}
+	order.SetLanguage(tag)
+	return nil
+}
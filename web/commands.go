@@ -0,0 +1,277 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// command is a lunchweb subcommand. Every command accepts the global flags
+// on top of its own.
+type command struct {
+	name  string
+	short string
+	// flags registers flags only this command understands
+	flags func(fs *flag.FlagSet)
+	run   func(s *Server, ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands; the first one is the default
+var commands = []*command{
+	{name: "serve", short: "run the web server (default)", run: (*Server).serve},
+	{name: "summary", short: "print today's orders", flags: summaryFlags, run: (*Server).runSummary},
+	{name: "send", short: "send today's orders through the notifiers", flags: sendFlags, run: (*Server).runSend},
+	{name: "validate", short: "check the sheet for structural problems", run: (*Server).runValidate},
+	{name: "purge", short: "delete archived data older than -retain", run: (*Server).runPurge},
+	{name: "render", short: "write today's page as static HTML", flags: renderFlags, run: (*Server).runRender},
+	{name: "archive", short: "generate a static site of the archived days", flags: siteFlags, run: (*Server).runSite},
+}
+
+// Main runs the command named by the first argument, serve by default, and
+// exits when it fails
+func Main() {
+	args := os.Args[1:]
+	cmd := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd = findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+			usage()
+			os.Exit(2)
+		}
+		args = args[1:]
+	}
+
+	fs := newFlagSet(cmd)
+	if err := setFlagsFromEnv(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if *flagVersion {
+		fmt.Println(currentBuild())
+		return
+	}
+
+	s, err := setup()
+	if err != nil {
+		fatal("setup failed", err)
+	}
+	if err := cmd.run(s, context.Background(), fs.Args()); err != nil {
+		slog.Error(cmd.name+" failed", "err", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode lets scripts tell a missing row for today (3) apart from other
+// failures (1)
+func exitCode(err error) int {
+	if errors.Is(err, sheet.ErrNoRow) {
+		return 3
+	}
+	return 1
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// newFlagSet returns the flags for cmd: the global ones, which keep writing
+// to the package level flag variables, plus the command's own
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet("lunchweb "+cmd.name, flag.ExitOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lunchweb %s [flags]\n\n%s.\n\nFlags:\n", cmd.name, cmd.short)
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nEvery flag can also be set through the environment, e.g. -log-level as %s.\n", envName("log-level"))
+		fmt.Fprintln(fs.Output())
+		usage()
+	}
+	return fs
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: lunchweb [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun lunchweb <command> -h for the flags of a command.\n")
+}
+
+var flagFormat *string
+
+func summaryFlags(fs *flag.FlagSet) {
+	flagFormat = fs.String("format", "text", "output format: text, json or markdown")
+}
+
+// runSummary prints today's orders
+func (s *Server) runSummary(ctx context.Context, args []string) error {
+	oo, err := s.todaysOrders(ctx, slog.Default())
+	if err != nil {
+		return err
+	}
+	today := s.cfg.now().Format(timeLayout)
+
+	switch *flagFormat {
+	case "text":
+		fmt.Print(s.cfg.summary(oo))
+	case "json":
+		type jsonItem struct {
+			Name  string `json:"name"`
+			Order string `json:"order"`
+		}
+		items := []jsonItem{}
+		for _, li := range oo.LineItems() {
+			items = append(items, jsonItem{li.Name, li.Order})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"date":      today,
+			"orders":    items,
+			"count":     len(items),
+			"max_count": oo.MaxCount(),
+			"percent":   oo.OrderPercent(),
+		})
+	case "markdown":
+		fmt.Printf("## %s (%s)\n\n", s.cfg.subject, today)
+		for _, li := range oo.LineItems() {
+			fmt.Printf("- **%s**: %s\n", li.Name, li.Order)
+		}
+		fmt.Printf("\n%d out of %d ordered something\n", len(oo.LineItems()), oo.MaxCount())
+	default:
+		return fmt.Errorf("unknown format %q", *flagFormat)
+	}
+	return nil
+}
+
+var (
+	flagDryRun *bool
+	flagSendTo stringList
+)
+
+func sendFlags(fs *flag.FlagSet) {
+	flagDryRun = fs.Bool("dry-run", false, "print the message instead of sending it")
+	fs.Var(&flagSendTo, "to", "email recipient overriding -email and the vendor's email (repeatable)")
+}
+
+// ordersMessage is the notification for today's orders
+func (s *Server) ordersMessage(ctx context.Context, oo *order.Overview) notify.Message {
+	now := s.cfg.now()
+	// sending the summary settles whose turn it is to pick up, a dry run
+	// doesn't
+	dryRun := flagDryRun != nil && *flagDryRun
+	// orders waiting for approval don't go to the restaurant
+	vendor, approvalItems, err := s.vendorOrders(now.Format(timeLayout), oo)
+	if err != nil {
+		slog.Error("could not read approvals", "err", err)
+	}
+	footer := sharedText(order.SharedItems(vendor.LineItems())) +
+		allergyText(s.allergyWarnings(ctx, slog.Default(), vendor)) +
+		approvalText(approvalItems) +
+		s.budgetText(now, vendor) +
+		s.payeeText(ctx, vendor) +
+		fmt.Sprintf("\n%d out of %d ordered something.\n%sSheet: %s\n", len(oo.LineItems()), oo.MaxCount(), s.pickupText(oo, !dryRun), s.cfg.sheetURL)
+	msg := notify.Message{
+		Subject: s.cfg.mailSubjectOn(now),
+		Text:    s.cfg.summary(vendor) + footer,
+		Chat:    vendor.ChatSummary(s.cfg.summarySort) + footer,
+		To:      flagSendTo,
+	}
+	// without -to the email goes where the mailto link on the page points
+	if len(msg.To) == 0 {
+		rcpt := s.cfg.orderRecipients(now)
+		msg.To, msg.Cc, msg.Bcc = rcpt.To, rcpt.Cc, rcpt.Bcc
+	}
+	return msg
+}
+
+// runSend pushes today's orders through the notifiers once, meant to be run
+// from cron by offices that don't keep lunchweb serve running
+func (s *Server) runSend(ctx context.Context, args []string) error {
+	oo, err := s.todaysOrders(ctx, slog.Default())
+	if err != nil {
+		return err
+	}
+	msg := s.ordersMessage(ctx, oo)
+	if *flagDryRun {
+		fmt.Printf("To: %s\n", strings.Join(msg.To, ", "))
+		if len(msg.Cc) > 0 {
+			fmt.Printf("Cc: %s\n", strings.Join(msg.Cc, ", "))
+		}
+		if len(msg.Bcc) > 0 {
+			fmt.Printf("Bcc: %s\n", strings.Join(msg.Bcc, ", "))
+		}
+		fmt.Printf("Subject: %s\n\n%s", msg.Subject, msg.Text)
+		return nil
+	}
+	return s.notifyAndPublish(ctx, nil, msg)
+}
+
+// runValidate reports structural problems in the sheet
+func (s *Server) runValidate(ctx context.Context, args []string) error {
+	rows, err := s.validationRows(ctx)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, p := range validateSheet(s.cfg.layout, rows) {
+		problems = append(problems, p.String())
+	}
+	problems = append(problems, monitor.check(s.cfg, rows, s.cfg.now())...)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+	fmt.Println("no problems found")
+	return nil
+}
+
+// validationRows reads the sheet leniently: ragged rows are one of the
+// things validate reports
+func (s *Server) validationRows(ctx context.Context) ([][]string, error) {
+	if *flagDemo {
+		return s.demoRows(ctx)
+	}
+	rows, err := s.client.Fetch(ctx)
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, fmt.Errorf("the sheet is not valid CSV: %w", err)
+	}
+	return rows, err
+}
+
+// runPurge applies -retain to the archive
+func (s *Server) runPurge(ctx context.Context, args []string) error {
+	purged, err := s.purgeExpired()
+	if err != nil {
+		return err
+	}
+	s.audit.Record(nil, "purge", fmt.Sprintf("purged %d archived days", purged))
+	fmt.Printf("purged %d archived days\n", purged)
+	return nil
+}
@@ -0,0 +1,315 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// Comment is a remark on the day's orders, e.g. "getting extra ketchup"
+type Comment struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+	// About is the name of the order commented on, empty for all of them
+	About string `json:"about,omitempty"`
+	Text  string `json:"text"`
+}
+
+// dayComments is what was said about one day's orders
+type dayComments struct {
+	Comments []Comment `json:"comments"`
+	// Reactions lists who reacted with which emoji, per name of the order
+	Reactions map[string]map[string][]string `json:"reactions,omitempty"`
+}
+
+// reactionEmojis are the reactions offered next to every order
+var reactionEmojis = []string{"👍", "😋", "🤔", "😂"}
+
+const (
+	maxCommentLength = 200
+	maxDayComments   = 500
+)
+
+// commentStore keeps one JSON file per day under <data-dir>/comments
+type commentStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newCommentStore(dataDir string) *commentStore {
+	return &commentStore{dir: filepath.Join(dataDir, "comments")}
+}
+
+// Load returns the comments on a date (2006-01-02)
+func (c *commentStore) Load(date string) (*dayComments, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load(date)
+}
+
+func (c *commentStore) load(date string) (*dayComments, error) {
+	day := &dayComments{}
+	b, err := os.ReadFile(filepath.Join(c.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return day, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, day); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return day, nil
+}
+
+// Update changes the comments on a date with change and stores them
+func (c *commentStore) Update(date string, change func(day *dayComments) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	day, err := c.load(date)
+	if err != nil {
+		return err
+	}
+	if err := change(day); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(day, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.dir, date+".json"), b)
+}
+
+// Purge deletes the comments on every day before cutoff (2006-01-02)
+func (c *commentStore) Purge(cutoff string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, e := range entries {
+		date, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// React adds the reaction of author to the order of about, or takes it back
+// when author already reacted like that
+func (d *dayComments) React(about, emoji, author string) {
+	if d.Reactions == nil {
+		d.Reactions = make(map[string]map[string][]string)
+	}
+	if d.Reactions[about] == nil {
+		d.Reactions[about] = make(map[string][]string)
+	}
+	who := d.Reactions[about][emoji]
+	if i := slices.Index(who, author); i >= 0 {
+		d.Reactions[about][emoji] = slices.Delete(who, i, i+1)
+		return
+	}
+	d.Reactions[about][emoji] = append(who, author)
+}
+
+// commentsView shows a day's comments to Me
+type commentsView struct {
+	Day *dayComments
+	Me  string
+}
+
+// reactionCount is how many reacted with Emoji, and whether Me did
+type reactionCount struct {
+	Emoji string
+	Count int
+	Mine  bool
+}
+
+// On returns the comments on the order of name, or on all orders for ""
+func (v commentsView) On(name string) []Comment {
+	var on []Comment
+	for _, c := range v.Day.Comments {
+		if c.About == name {
+			on = append(on, c)
+		}
+	}
+	return on
+}
+
+// Reactions returns the count of every reaction to the order of name
+func (v commentsView) Reactions(name string) []reactionCount {
+	var counts []reactionCount
+	for _, emoji := range reactionEmojis {
+		who := v.Day.Reactions[name][emoji]
+		counts = append(counts, reactionCount{emoji, len(who), v.Me != "" && slices.Contains(who, v.Me)})
+	}
+	return counts
+}
+
+// commentAuthor returns the name to comment as: the visitor's sheet name, or
+// else the logged-in user
+func (s *Server) commentAuthor(r *http.Request, names []string) string {
+	if name := s.claimedName(r, names); name != "" {
+		return name
+	}
+	if id := identityFromRequest(r); id != nil {
+		return id.String()
+	}
+	return ""
+}
+
+// handleComment adds a comment on today's orders, today as on the page it
+// was posted from (see commentDay)
+func (s *Server) handleComment(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	t, err := s.commentDay(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oo, err := s.ordersOn(r.Context(), requestLogger(r), t)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	author := s.commentAuthor(r, oo.Names)
+	if author == "" {
+		http.Error(w, "pick who you are before commenting", http.StatusForbidden)
+		return
+	}
+	about := strings.TrimSpace(r.FormValue("about"))
+	text := strings.TrimSpace(r.FormValue("text"))
+	if !orderedBy(oo.LineItems(), about) {
+		http.Error(w, "nobody called "+about+" ordered today", http.StatusBadRequest)
+		return
+	}
+	if text == "" || utf8.RuneCountInString(text) > maxCommentLength {
+		http.Error(w, fmt.Sprintf("comments have 1 to %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+
+	err = s.comments.Update(t.Format(timeLayout), func(day *dayComments) error {
+		if len(day.Comments) >= maxDayComments {
+			return fmt.Errorf("too many comments today")
+		}
+		day.Comments = append(day.Comments, Comment{Time: s.cfg.now(), Author: author, About: about, Text: text})
+		return nil
+	})
+	if err != nil {
+		requestLogger(r).Error("could not store comment", "err", err)
+		http.Error(w, "could not store comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	requestLogger(r).Debug("comment added", "author", author, "about", about)
+	commented(w, r)
+}
+
+// handleReaction adds or takes back a reaction to someone's order today, like
+// handleComment
+func (s *Server) handleReaction(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	t, err := s.commentDay(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oo, err := s.ordersOn(r.Context(), requestLogger(r), t)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	author := s.commentAuthor(r, oo.Names)
+	if author == "" {
+		http.Error(w, "pick who you are before reacting", http.StatusForbidden)
+		return
+	}
+	about := r.FormValue("about")
+	emoji := r.FormValue("emoji")
+	if about == "" || !orderedBy(oo.LineItems(), about) || !slices.Contains(reactionEmojis, emoji) {
+		http.Error(w, "invalid reaction", http.StatusBadRequest)
+		return
+	}
+
+	err = s.comments.Update(t.Format(timeLayout), func(day *dayComments) error {
+		day.React(about, emoji, author)
+		return nil
+	})
+	if err != nil {
+		requestLogger(r).Error("could not store reaction", "err", err)
+		http.Error(w, "could not store reaction", http.StatusInternalServerError)
+		return
+	}
+	commented(w, r)
+}
+
+// commentDay returns the day a comment or reaction is about: the posted
+// "date" of the page it was made on, which may be in another ?tz= than the
+// office's so a day off, or else today
+func (s *Server) commentDay(r *http.Request) (time.Time, error) {
+	now := s.cfg.now()
+	date := r.FormValue("date")
+	if date == "" {
+		return now, nil
+	}
+	for _, days := range []int{0, -1, 1} {
+		if t := now.AddDate(0, 0, days); t.Format(timeLayout) == date {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can only comment on today's orders, not those of %q", date)
+}
+
+// orderedBy reports whether one of items is the order of name, which may be
+// empty for all of them
+func orderedBy(items []*order.LineItem, name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, li := range items {
+		if li.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// commented sends the browser back to the orders, or tells htmx to reload
+// them in place
+func commented(w http.ResponseWriter, r *http.Request) {
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "commented")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the smallest response worth compressing
+const minCompressSize = 1024
+
+// cacheAndCompress buffers successful GET responses to give them an ETag,
+// answers 304 when the client already has them, and compresses them with
+// Brotli or gzip for clients that accept it. Pages are marked no-cache, so
+// browsers revalidate each time but only download what changed: the kiosk
+// refreshing every few seconds mostly gets 304s. HEAD requests pass
+// through: handlers answer them without a body, like the monitoring checks
+// on the index, which an ETag would describe wrongly.
+func cacheAndCompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bw := &bufferedWriter{ResponseWriter: w, buf: new(bytes.Buffer)}
+		next.ServeHTTP(bw, r)
+		if bw.passthrough {
+			return
+		}
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+		body := bw.buf.Bytes()
+		h := w.Header()
+		if h.Get("Content-Type") == "" && len(body) > 0 {
+			h.Set("Content-Type", http.DetectContentType(body))
+		}
+
+		encoding := ""
+		if len(body) >= minCompressSize && compressible(h.Get("Content-Type")) && h.Get("Content-Encoding") == "" {
+			encoding = contentEncoding(r)
+		}
+		h.Add("Vary", "Accept-Encoding")
+
+		if bw.status == http.StatusOK && h.Get("ETag") == "" {
+			sum := sha256.Sum256(body)
+			etag := hex.EncodeToString(sum[:8])
+			if encoding != "" {
+				etag += "-" + encoding
+			}
+			h.Set("ETag", `"`+etag+`"`)
+			if h.Get("Cache-Control") == "" {
+				h.Set("Cache-Control", "no-cache")
+			}
+			if etagMatches(r.Header.Get("If-None-Match"), h.Get("ETag")) {
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		if encoding != "" {
+			var zbuf bytes.Buffer
+			var zw io.WriteCloser
+			if encoding == "br" {
+				zw = brotli.NewWriterLevel(&zbuf, brotli.DefaultCompression)
+			} else {
+				zw = gzip.NewWriter(&zbuf)
+			}
+			zw.Write(body)
+			zw.Close()
+			body = zbuf.Bytes()
+			h.Set("Content-Encoding", encoding)
+		}
+		h.Del("Content-Length")
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+// bufferedWriter holds back the response until the handler is done, except
+// for errors and event streams, which pass straight through
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         *bytes.Buffer
+	passthrough bool
+}
+
+// adopt takes buf, a whole response body held back by timeoutHandler, as its
+// own, so it isn't copied into a second buffer. It reports false when the
+// response passes through, and buf needs writing after all.
+func (b *bufferedWriter) adopt(buf *bytes.Buffer) bool {
+	if b.passthrough || b.buf.Len() > 0 {
+		return false
+	}
+	b.buf = buf
+	return true
+}
+
+func (b *bufferedWriter) WriteHeader(code int) {
+	if b.status != 0 || b.passthrough {
+		return
+	}
+	if code != http.StatusOK || strings.HasPrefix(b.Header().Get("Content-Type"), "text/event-stream") {
+		b.passthrough = true
+		b.ResponseWriter.WriteHeader(code)
+		return
+	}
+	b.status = code
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 && !b.passthrough {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.passthrough {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// Flush switches to passing the response through, as the handler wants the
+// client to see what it wrote so far
+func (b *bufferedWriter) Flush() {
+	if !b.passthrough {
+		b.passthrough = true
+		if b.status != 0 {
+			b.Header().Del("Content-Length")
+			b.ResponseWriter.WriteHeader(b.status)
+		}
+		b.ResponseWriter.Write(b.buf.Bytes())
+		b.buf.Reset()
+	}
+	http.NewResponseController(b.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (b *bufferedWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// compressible reports whether responses of contentType shrink with gzip
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "image/svg+xml"
+}
+
+// contentEncoding returns the encoding to compress the response to r with:
+// br when the client accepts it, as it's smaller, then gzip, or "" for none
+func contentEncoding(r *http.Request) string {
+	accepted := make(map[string]bool)
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.ReplaceAll(params, " ", "") != "q=0" {
+			accepted[strings.ToLower(strings.TrimSpace(enc))] = true
+		}
+	}
+	for _, enc := range []string{"br", "gzip"} {
+		if accepted[enc] {
+			return enc
+		}
+	}
+	return ""
+}
+
+// etagMatches checks an If-None-Match header against etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package web
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/payment"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// config is what the server reads from the flags while running. loadConfig
+// builds it once and nothing changes it afterwards, so handlers and
+// background jobs share it without locking; a new configuration means a new
+// config.
+type config struct {
+	// location is -tz, the office's time zone
+	location *time.Location
+	layout   sheet.Layout
+	sheetURL string
+	subject  string
+	// summaryLine and summarySort are -summary-format and -summary-sort
+	summaryLine *template.Template
+	summarySort string
+	email       []string
+	emailCC     []string
+	emailBCC    []string
+	digestTo    []string
+	vendors     []*Vendor
+	allergies   map[string][]string
+	// addresses are everyone's email addresses by sheet name, -addresses
+	addresses map[string][]string
+	// phones are everyone's phone numbers by sheet name, -phones
+	phones      map[string][]string
+	admins      []string
+	trustProxy  bool
+	leaderboard bool
+	// logout is whether people can log out, with -oidc-issuer
+	logout bool
+
+	csp            string
+	frameOptions   string
+	referrerPolicy string
+
+	maintenanceMessage string
+	todayRowBy         timeOfDay
+	archiveFinal       timeOfDay
+	retain             retention
+
+	// deadline is -deadline, vendors may have their own; reminder is
+	// -reminder
+	deadline timeOfDay
+	reminder time.Duration
+
+	// approvalMax and approvalBlock are the rules of orders that wait for
+	// one of approvers, -approval-max, -approval-block and -approver
+	approvalMax   order.Money
+	approvalBlock []string
+	approvers     []string
+
+	// budgetDay and budgetMonth are -budget-day and -budget-month, 0 for
+	// none; budgetWarn is -budget-warn
+	budgetDay   order.Money
+	budgetMonth order.Money
+	budgetWarn  int
+
+	// expenseTemplate, costCenter, vat and expenseType make up the expense
+	// export, from -expense-template, -cost-center, -vat in hundredths of a
+	// percent and -expense-type
+	expenseTemplate *expenseTemplate
+	costCenter      string
+	vat             int
+	expenseType     string
+
+	// paymentProviders is -payment-provider by language, "" for everyone
+	// else, and payee is -payee
+	paymentProviders map[string]payment.Provider
+	payee            string
+
+	// inboundEmailToken, mailgunSigningKey, twilioAuthToken and
+	// slackSigningSecret are the secrets the hooks of those services check,
+	// "" when a hook is off
+	inboundEmailToken  string
+	mailgunSigningKey  string
+	twilioAuthToken    string
+	slackSigningSecret string
+
+	// ttsURL is -tts-url and announceWebhook -announce-webhook, "" for none
+	ttsURL          string
+	announceWebhook string
+
+	// calendarInvite is -calendar-invite, with the -delivery-time people are
+	// invited to by -smtp-from
+	calendarInvite bool
+	deliveryTime   timeOfDay
+	smtpFrom       string
+
+	// standingTime is -standing-time, pickupVolunteers -pickup-volunteer and
+	// closureSources the -closures calendars
+	standingTime     timeOfDay
+	pickupVolunteers []string
+	closureSources   []string
+
+	// mqttTopic and mqttDiscovery are -mqtt-topic and -mqtt-discovery
+	mqttTopic     string
+	mqttDiscovery string
+}
+
+// loadConfig checks the flags and returns the configuration they describe
+func loadConfig() (*config, error) {
+	if *flagHeader < 0 {
+		return nil, fmt.Errorf("-header must be 0 or more, got %d", *flagHeader)
+	}
+	if err := checkRowFlags(); err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(*flagTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("could not load time zone (build with -tags tzdata to embed the zone database): %v", err)
+	}
+	line, err := summaryTemplate()
+	if err != nil {
+		return nil, err
+	}
+	c := &config{
+		location:           loc,
+		layout:             sheetLayout(),
+		sheetURL:           *flagSheetURL,
+		subject:            *flagSubject,
+		summaryLine:        line,
+		summarySort:        *flagSummarySort,
+		email:              splitAddresses(flagEmail),
+		emailCC:            splitAddresses(flagEmailCC),
+		emailBCC:           splitAddresses(flagEmailBCC),
+		digestTo:           append([]string(nil), flagDigestTo...),
+		admins:             append([]string(nil), flagAdmins...),
+		trustProxy:         *flagTrustProxy,
+		leaderboard:        *flagLeaderboard,
+		logout:             *flagOIDCIssuer != "",
+		csp:                *flagCSP,
+		frameOptions:       *flagFrameOptions,
+		referrerPolicy:     *flagReferrerPolicy,
+		maintenanceMessage: *flagMaintenanceMessage,
+		todayRowBy:         flagTodayRowBy,
+		archiveFinal:       flagArchiveFinal,
+		deadline:           flagDeadline,
+		reminder:           *flagReminder,
+		retain:             flagRetain,
+		approvalBlock:      append([]string(nil), flagApprovalBlock...),
+		approvers:          append([]string(nil), flagApprovers...),
+		budgetWarn:         *flagBudgetWarn,
+		costCenter:         *flagCostCenter,
+		expenseType:        *flagExpenseType,
+		inboundEmailToken:  *flagInboundEmailToken,
+		mailgunSigningKey:  *flagMailgunSigningKey,
+		twilioAuthToken:    *flagTwilioAuthToken,
+		slackSigningSecret: *flagSlackSigningSecret,
+		ttsURL:             *flagTTSURL,
+		announceWebhook:    *flagAnnounceWebhook,
+		calendarInvite:     *flagCalendarInvite,
+		deliveryTime:       flagDeliveryTime,
+		smtpFrom:           *flagSMTPFrom,
+		standingTime:       flagStandingTime,
+		pickupVolunteers:   append([]string(nil), flagPickupVolunteers...),
+		closureSources:     append([]string(nil), flagClosures...),
+		mqttTopic:          *flagMQTTTopic,
+		mqttDiscovery:      *flagMQTTDiscovery,
+	}
+	if *flagVendors != "" {
+		c.vendors, err = loadVendors(*flagVendors)
+		if err != nil {
+			return nil, fmt.Errorf("could not load vendors: %v", err)
+		}
+	}
+	if *flagAllergies != "" {
+		c.allergies, err = loadAllergies(*flagAllergies)
+		if err != nil {
+			return nil, fmt.Errorf("could not load allergies: %v", err)
+		}
+	}
+	if *flagAddresses != "" {
+		c.addresses, err = loadAddresses(*flagAddresses)
+		if err != nil {
+			return nil, fmt.Errorf("could not load addresses: %v", err)
+		}
+	}
+	if *flagPhones != "" {
+		c.phones, err = loadPhones(*flagPhones)
+		if err != nil {
+			return nil, fmt.Errorf("could not load phone numbers: %v", err)
+		}
+	}
+	if *flagApprovalMax != "" {
+		max, ok := order.ParseAmount(*flagApprovalMax)
+		if !ok || max <= 0 {
+			return nil, fmt.Errorf("invalid -approval-max %q, expected an amount like 15.00", *flagApprovalMax)
+		}
+		c.approvalMax = max
+	}
+	if c.approvalMax > 0 || len(c.approvalBlock) > 0 {
+		if len(c.approvers) == 0 && len(c.admins) == 0 {
+			return nil, fmt.Errorf("-approval-max and -approval-block require -approver or -admin to approve orders")
+		}
+	}
+	for _, budget := range []struct {
+		name  string
+		value string
+		cap   *order.Money
+	}{
+		{"-budget-day", *flagBudgetDay, &c.budgetDay},
+		{"-budget-month", *flagBudgetMonth, &c.budgetMonth},
+	} {
+		if budget.value == "" {
+			continue
+		}
+		amount, ok := order.ParseAmount(budget.value)
+		if !ok || amount <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected an amount like 150.00", budget.name, budget.value)
+		}
+		*budget.cap = amount
+	}
+	if c.budgetWarn <= 0 || c.budgetWarn > 100 {
+		return nil, fmt.Errorf("-budget-warn must be a percentage from 1 to 100, got %d", c.budgetWarn)
+	}
+	c.expenseTemplate, err = loadExpenseTemplate(*flagExpenseTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("could not load -expense-template: %v", err)
+	}
+	if c.vat, err = parseVAT(*flagVAT); err != nil {
+		return nil, err
+	}
+	if c.paymentProviders, err = paymentProviders(); err != nil {
+		return nil, err
+	}
+	c.payee = *flagPayee
+	if !c.paysIntoOwnAccount() && c.payee == "" {
+		return nil, fmt.Errorf("-payment-provider %s requires -payee, who gets the payments", *flagPaymentProvider)
+	}
+	if c.reminder < 0 {
+		return nil, fmt.Errorf("-reminder must not be negative, got %s", c.reminder)
+	}
+	if c.reminder > 0 && !c.hasDeadline() {
+		return nil, fmt.Errorf("-reminder requires -deadline or a vendor with a deadline")
+	}
+	if c.announceWebhook != "" && c.reminder == 0 {
+		return nil, fmt.Errorf("-announce-webhook requires -reminder")
+	}
+	if c.calendarInvite {
+		switch {
+		case !c.deliveryTime.IsSet():
+			return nil, fmt.Errorf("-calendar-invite requires -delivery-time")
+		case !c.hasDeadline():
+			return nil, fmt.Errorf("-calendar-invite requires -deadline or a vendor with a deadline")
+		case *flagSMTPAddr == "":
+			return nil, fmt.Errorf("-calendar-invite requires -smtp-addr")
+		case len(c.addresses) == 0:
+			return nil, fmt.Errorf("-calendar-invite requires -addresses to invite people")
+		}
+	}
+	return c, nil
+}
+
+// now returns the current time in -tz according to appClock
+func (c *config) now() time.Time {
+	return appClock.Now().In(c.location)
+}
+
+// summary returns one line per order, formatted with -summary-format and
+// sorted by -summary-sort
+func (c *config) summary(oo *order.Overview) string {
+	return oo.Summary(c.summaryLine, c.summarySort)
+}
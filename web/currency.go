@@ -0,0 +1,64 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagCurrency = flag.String("currency", "EUR", `currency of the prices: an ISO code like EUR, USD, CHF or SEK, optionally followed by symbol=, decimal=, (or .), round=0.05 and before or after for the symbol's place, e.g. "SEK round=1.00"`)
+
+func setupCurrency() error {
+	c, err := parseCurrency(*flagCurrency)
+	if err != nil {
+		return fmt.Errorf("invalid -currency %q: %v", *flagCurrency, err)
+	}
+	order.SetCurrency(c)
+	return nil
+}
+
+// parseCurrency reads a -currency value
+func parseCurrency(value string) (order.Currency, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return order.Currency{}, fmt.Errorf("empty")
+	}
+	c, ok := order.LookupCurrency(fields[0])
+	if !ok {
+		if strings.Contains(fields[0], "=") {
+			return order.Currency{}, fmt.Errorf("start with the currency's code")
+		}
+		// unknown, written like its code unless symbol= says otherwise
+		c = order.Currency{Code: strings.ToUpper(fields[0]), Symbol: strings.ToUpper(fields[0]), Decimal: "."}
+	}
+	for _, f := range fields[1:] {
+		key, val, _ := strings.Cut(f, "=")
+		switch key {
+		case "symbol":
+			if val == "" {
+				return c, fmt.Errorf("empty symbol")
+			}
+			c.Symbol = val
+		case "decimal":
+			if val != "." && val != "," {
+				return c, fmt.Errorf("decimal must be . or ,")
+			}
+			c.Decimal = val
+		case "round":
+			step, ok := order.ParseAmount(val)
+			if !ok || step <= 0 {
+				return c, fmt.Errorf("round must be an amount like 0.05")
+			}
+			c.Rounding = step
+		case "before":
+			c.After = false
+		case "after":
+			c.After = true
+		default:
+			return c, fmt.Errorf("unknown option %q, expected symbol=, decimal=, round=, before or after", f)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+var flagDeadline timeOfDay
+var flagReminder = flag.Duration("reminder", 0, "notify this long before the order deadline, e.g. 30m (0 for no reminder)")
+
+func init() {
+	flag.Var(&flagDeadline, "deadline", `time of day orders must be in, e.g. 11:30; a vendor's "deadline" takes precedence on its days (default: none)`)
+}
+
+// deadlineOn returns when orders close on the day of t: the day's vendor's
+// deadline, else -deadline. ok is false when neither is set.
+func (c *config) deadlineOn(t time.Time) (deadline time.Time, ok bool) {
+	day := t.In(c.location)
+	at := c.deadline
+	if v := c.vendorFor(day); v != nil && v.deadline.IsSet() {
+		at = v.deadline
+	}
+	if !at.IsSet() {
+		return time.Time{}, false
+	}
+	return at.On(day), true
+}
+
+// deadlineView is the deadline shown on the page
+type deadlineView struct {
+	// At is the deadline in the viewer's time zone, e.g. "10:00"
+	At     string
+	Vendor string
+	// Left is how long until the deadline, "" once it passed
+	Left string
+	// Seconds left let the page count down between refreshes
+	Seconds int64
+}
+
+// deadlineFor returns the deadline of the day of t as shown in t's time
+// zone, or nil when there is none or it isn't an ordering day
+func (c *config) deadlineFor(t time.Time) *deadlineView {
+	if !c.isOrderingDay(t.In(c.location)) {
+		return nil
+	}
+	deadline, ok := c.deadlineOn(t)
+	if !ok {
+		return nil
+	}
+	v := &deadlineView{
+		At:     deadline.In(t.Location()).Format("15:04"),
+		Vendor: c.vendorName(t.In(c.location)),
+	}
+	if left := deadline.Sub(t); left > 0 {
+		v.Left = formatLeft(left)
+		v.Seconds = int64(left / time.Second)
+	}
+	return v
+}
+
+// formatLeft writes a time until a deadline in minutes, e.g. "1h 05m" or
+// "12m"; less than a minute counts as one
+func formatLeft(d time.Duration) string {
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// nextDeadline returns the first time after t that is the duration before
+// the deadline of an ordering day within the next week, and false when there
+// is none
+func (c *config) nextDeadline(t time.Time, before time.Duration) (time.Time, bool) {
+	t = t.In(c.location)
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		if !c.isOrderingDay(day) {
+			continue
+		}
+		deadline, ok := c.deadlineOn(day)
+		if !ok {
+			continue
+		}
+		if at := deadline.Add(-before); at.After(t) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// reminderMessage is the notification sent -reminder before the deadline
+func (s *Server) reminderMessage(ctx context.Context, deadline time.Time) (notify.Message, error) {
+	oo, err := s.ordersOn(ctx, slog.Default(), deadline)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	closes := "Lunch orders close"
+	if vendor := s.cfg.vendorName(deadline); vendor != "" {
+		closes = fmt.Sprintf("Lunch orders for %s close", vendor)
+	}
+	estimate := ""
+	if f, err := s.forecastOn(deadline, oo); err != nil {
+		slog.Error("could not forecast orders", "err", err)
+	} else if f != nil {
+		estimate = f.String() + "\n"
+	}
+	text := fmt.Sprintf("%s at %s, in %s.\n%d out of %d ordered something so far.\n%sSheet: %s\n",
+		closes, deadline.Format("15:04"), formatLeft(deadline.Sub(s.cfg.now())),
+		len(oo.LineItems()), oo.MaxCount(), estimate, s.cfg.sheetURL)
+	return notify.Message{
+		Subject: fmt.Sprintf("%s at %s", closes, deadline.Format("15:04")),
+		Text:    text,
+	}, nil
+}
+
+// runReminders notifies -reminder before every deadline until ctx is done
+func (s *Server) runReminders(ctx context.Context) {
+	s.runBeforeDeadlines(ctx, "reminder", s.cfg.reminder, func(ctx context.Context, deadline time.Time) {
+		msg, err := s.reminderMessage(ctx, deadline)
+		if err == nil {
+			err = s.notifyAll(ctx, nil, msg)
+		}
+		if err != nil {
+			slog.Error("could not send order reminder", "err", err)
+		}
+		if s.cfg.announceWebhook != "" {
+			if err := s.announce(ctx, deadline.Add(-s.cfg.reminder)); err != nil {
+				slog.Error("could not announce order reminder", "err", err)
+			}
+		}
+	})
+}
+
+// runBeforeDeadlines calls job the duration before every deadline until ctx
+// is done. Unlike runDaily the time changes with the day's vendor.
+func (s *Server) runBeforeDeadlines(ctx context.Context, name string, before time.Duration, job func(ctx context.Context, deadline time.Time)) {
+	for {
+		next, ok := s.cfg.nextDeadline(s.cfg.now(), before)
+		if !ok {
+			// no deadline in the coming week, e.g. every vendor has none
+			next = s.cfg.now().AddDate(0, 0, 1)
+		}
+		slog.Debug("scheduled job", "job", name, "next", next)
+
+		timer := time.NewTimer(next.Sub(s.cfg.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !ok {
+			continue
+		}
+
+		slog.Info("running scheduled job", "job", name)
+		runJob(name, func() { job(ctx, next.Add(before)) })
+	}
+}
+
+// hasDeadline reports whether orders close at some time on any day
+func (c *config) hasDeadline() bool {
+	if c.deadline.IsSet() {
+		return true
+	}
+	for _, v := range c.vendors {
+		if v.deadline.IsSet() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+// The states of a day's delivery, in order
+const (
+	deliveryCollecting = "collecting"
+	deliveryOrdered    = "ordered"
+	deliveryOnTheWay   = "out for delivery"
+	deliveryArrived    = "arrived"
+)
+
+var deliveryStates = []string{deliveryCollecting, deliveryOrdered, deliveryOnTheWay, deliveryArrived}
+
+// deliveryNews is what the notifiers say when a delivery reaches a state
+var deliveryNews = map[string]string{
+	deliveryOrdered:  "Lunch is ordered 📞",
+	deliveryOnTheWay: "Lunch is on its way 🛵",
+	deliveryArrived:  "Food has arrived 🍴",
+}
+
+// deliveryChange is a delivery reaching a state
+type deliveryChange struct {
+	State string    `json:"state"`
+	By    string    `json:"by"`
+	Time  time.Time `json:"time"`
+}
+
+// deliveryStore keeps how far each day's delivery got, as the list of its
+// changes, in one JSON file per day under <data-dir>/delivery
+type deliveryStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newDeliveryStore(dataDir string) *deliveryStore {
+	return &deliveryStore{dir: filepath.Join(dataDir, "delivery")}
+}
+
+// Load returns the changes of the delivery on a date (2006-01-02), the
+// latest last; none while orders are being collected
+func (d *deliveryStore) Load(date string) ([]deliveryChange, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.load(date)
+}
+
+func (d *deliveryStore) load(date string) ([]deliveryChange, error) {
+	var changes []deliveryChange
+	b, err := os.ReadFile(filepath.Join(d.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &changes); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return changes, nil
+}
+
+// Add records the delivery on a date reaching a state
+func (d *deliveryStore) Add(date string, change deliveryChange) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changes, err := d.load(date)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(append(changes, change), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.dir, date+".json"), b)
+}
+
+// Purge deletes the deliveries of every day before cutoff (2006-01-02),
+// with their tracking
+func (d *deliveryStore) Purge(cutoff string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files, err := os.ReadDir(d.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, _, _ := strings.Cut(f.Name(), ".")
+		if !strings.HasSuffix(f.Name(), ".json") || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		if f.Name() == date+".json" {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// deliveryView is how far a day's delivery got
+type deliveryView struct {
+	State string
+	// Since is when it got there, zero while collecting
+	Since time.Time
+	By    string
+	// Tracking is the tracking page and ETA the estimated arrival, if known
+	Tracking string
+	ETA      *time.Time
+	Polled   *time.Time
+}
+
+// Steps returns the states with whether the delivery got there
+func (v *deliveryView) Steps() []deliveryStep {
+	reached := slices.Index(deliveryStates, v.State)
+	steps := make([]deliveryStep, len(deliveryStates))
+	for i, state := range deliveryStates {
+		steps[i] = deliveryStep{State: state, Done: i < reached, Current: i == reached}
+	}
+	return steps
+}
+
+// Next returns the state after the current one, "" once it arrived
+func (v *deliveryView) Next() string {
+	i := slices.Index(deliveryStates, v.State)
+	if i+1 < len(deliveryStates) {
+		return deliveryStates[i+1]
+	}
+	return ""
+}
+
+// deliveryStep is a state on the way as shown on the page
+type deliveryStep struct {
+	State   string
+	Done    bool
+	Current bool
+}
+
+// deliveryOn returns how far the delivery on a date (2006-01-02) got
+func (s *Server) deliveryOn(date string) (*deliveryView, error) {
+	changes, err := s.deliveries.Load(date)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := s.deliveries.Tracking(date)
+	if err != nil {
+		return nil, err
+	}
+	view := &deliveryView{State: deliveryCollecting, Tracking: tr.URL, ETA: tr.ETA, Polled: tr.Polled}
+	if len(changes) > 0 {
+		last := changes[len(changes)-1]
+		view.State, view.Since, view.By = last.State, last.Time, last.By
+	}
+	return view, nil
+}
+
+// handleDelivery moves today's delivery to the posted state, usually the
+// next one, and tells the notifiers when it moved on
+func (s *Server) handleDelivery(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	state := r.FormValue("state")
+	if !slices.Contains(deliveryStates, state) {
+		http.Error(w, fmt.Sprintf("unknown delivery state %q", state), http.StatusBadRequest)
+		return
+	}
+	now := s.cfg.now()
+	date := now.Format(timeLayout)
+	current, err := s.deliveryOn(date)
+	if err != nil {
+		requestLogger(r).Error("could not read delivery state", "err", err)
+		http.Error(w, "could not read the delivery state", http.StatusInternalServerError)
+		return
+	}
+	change := deliveryChange{State: state, By: identityFromRequest(r).String(), Time: now}
+	if err := s.deliveries.Add(date, change); err != nil {
+		requestLogger(r).Error("could not store delivery state", "err", err)
+		http.Error(w, "could not store the delivery state", http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r, "delivery", state)
+	msg := "Delivery: " + state
+	// going back fixes a mistake, only news goes out
+	forward := slices.Index(deliveryStates, state) > slices.Index(deliveryStates, current.State)
+	if news, ok := deliveryNews[state]; ok && forward {
+		subject := news
+		if vendor := s.cfg.vendorName(now); vendor != "" {
+			subject = fmt.Sprintf("%s (%s)", news, vendor)
+		}
+		if err := s.notifyAll(r.Context(), r, notify.Message{Subject: subject, Text: subject + "\n"}); err != nil {
+			msg += ", but notifying failed: " + err.Error()
+		}
+	}
+	http.Redirect(w, r, "/admin?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"flag"
+	"os"
+	"time"
+)
+
+var flagDemo = flag.Bool("demo", false, "serve made up sample orders instead of a real sheet, to try lunchweb out")
+
+// demoCSV holds the sample people and a rotation of weekday orders
+//
+//go:embed demo.csv
+var demoCSV []byte
+
+// demoWeeks is how much history the demo sheet has
+const demoWeeks = 8
+
+// demoRows builds a sheet with the sample orders on every weekday from
+// demoWeeks ago until next week, so there always is a row for today and
+// enough history for the statistics
+func (s *Server) demoRows(ctx context.Context) ([][]string, error) {
+	sample, err := csv.NewReader(bytes.NewReader(demoCSV)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	names, orders := sample[0], sample[1:]
+
+	rows := make([][]string, s.cfg.layout.Header)
+	for i := range rows {
+		rows[i] = make([]string, len(names)+1)
+	}
+	// with -header 0 there is no room above the names for a title
+	if len(rows) > 0 {
+		rows[0][0] = "LunchWeb demo sheet"
+	}
+	rows = append(rows, append([]string{"Date"}, names...))
+
+	today := startOfDay(s.cfg.now())
+	day := today.AddDate(0, 0, -7*demoWeeks)
+	for i := 0; !day.After(today.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		row := []string{day.Format(timeLayout)}
+		if day.After(today) {
+			// nobody ordered for next week yet
+			row = append(row, make([]string, len(names))...)
+		} else {
+			row = append(row, orders[i%len(orders)]...)
+			i++
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// setupDemo points the sheet at the sample data. Without an explicit
+// -data-dir the demo archives into a temporary directory to keep it out of
+// real data.
+func (s *Server) setupDemo() error {
+	s.sheet.source = s.demoRows
+	if *flagDataDir == "data" {
+		dir, err := os.MkdirTemp("", "lunchweb-demo-")
+		if err != nil {
+			return err
+		}
+		*flagDataDir = dir
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var flagDev = flag.Bool("dev", false, "read the templates and static files from -dev-dir on every request and turn off caching, to work on the pages without rebuilding")
+var flagDevDir = flag.String("dev-dir", "web", "directory holding templates/ and assets/ for -dev, the web package of a checkout")
+
+// devMode makes pages read the templates again on every request
+var devMode bool
+
+// setupDev switches to the templates and assets on disk for -dev
+func setupDev() error {
+	if !*flagDev {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(*flagDevDir, "templates")); err != nil {
+		return fmt.Errorf("-dev reads the templates from -dev-dir: %v", err)
+	}
+	webFiles = os.DirFS(*flagDevDir)
+	devMode = true
+	slog.Warn("development mode, reading templates and assets from disk", "dir", *flagDevDir)
+	return nil
+}
+
+// noStore keeps browsers from caching anything in -dev, so a reload always
+// shows the files as they are on disk
+func noStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+// buildDigest summarizes the orders between from and to (2006-01-02)
+func (s *Server) buildDigest(from, to string) (notify.Message, error) {
+	snapshots, err := s.archive.Range(from, to)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	stats := computeStats(snapshots, 5)
+	report := s.buildMonthlyReport("", snapshots)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Lunch digest for %s to %s\n\n", from, to)
+	if stats.Days == 0 {
+		b.WriteString("Nobody ordered lunch this week.\n")
+	} else {
+		fmt.Fprintf(&b, "%d days, %d orders, average participation %.0f%%.\n",
+			stats.Days, stats.TotalOrders, stats.AverageParticipation)
+
+		b.WriteString("\nMost ordered:\n")
+		for _, item := range stats.TopItems {
+			fmt.Fprintf(&b, "- %s (%dx)\n", item.Item, item.Count)
+		}
+
+		fmt.Fprintf(&b, "\nTotal spend: %s", report.Total)
+		if report.Unpriced > 0 {
+			fmt.Fprintf(&b, " (%d orders without a price)", report.Unpriced)
+		}
+		b.WriteString("\n\nSpend per person:\n")
+		for _, p := range report.People {
+			fmt.Fprintf(&b, "- %s: %s\n", p.Name, p.Total)
+		}
+	}
+
+	return notify.Message{
+		Subject: fmt.Sprintf("Lunch digest %s - %s", from, to),
+		Text:    b.String(),
+		To:      s.cfg.digestTo,
+	}, nil
+}
+
+// sendDigest sends the digest of the current week, Monday to today
+func (s *Server) sendDigest(ctx context.Context, r *http.Request) error {
+	today := s.cfg.now()
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	msg, err := s.buildDigest(monday.Format(timeLayout), today.Format(timeLayout))
+	if err != nil {
+		return err
+	}
+	return s.notifyAndPublish(ctx, r, msg)
+}
+
+// runDigest sends the digest every week on day at the time of day at
+func (s *Server) runDigest(ctx context.Context, at timeOfDay, day time.Weekday) {
+	s.runDaily(ctx, "digest", at, []time.Weekday{day}, func(ctx context.Context) {
+		if err := s.sendDigest(ctx, nil); err != nil {
+			slog.Error("could not send weekly digest", "err", err)
+		}
+	})
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "digest",
+		Label: "Send weekly digest now",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if err := s.sendDigest(r.Context(), r); err != nil {
+				return "", err
+			}
+			return "Weekly digest sent", nil
+		},
+	})
+}
@@ -0,0 +1,235 @@
+package web
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/directory"
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagDirectory = flag.String("directory", "", "take the people expected to order from the company directory instead of the sheet header: google=<group email>, azure=<tenant>/<group ID> or an ldap:// or ldaps:// URL")
+var flagDirectoryKey = flag.String("directory-key", "", "JSON key file of the Google service account for -directory google=...")
+var flagDirectoryAdmin = flag.String("directory-admin", "", "email address of the Google Workspace admin the service account acts as")
+var flagDirectoryClientID = flag.String("directory-client-id", "", "application (client) ID of the app registration for -directory azure=...")
+var flagDirectoryClientSecret = flag.String("directory-client-secret", "", "client secret of the app registration for -directory azure=...")
+var flagDirectoryBase = flag.String("directory-base", "", "DN to search under for -directory ldap://..., like ou=people,dc=example,dc=com")
+var flagDirectoryFilter = flag.String("directory-filter", "", "LDAP filter of the people for -directory ldap://..., like (memberOf=cn=lunch,ou=groups,dc=example,dc=com) (default: (objectClass=person))")
+var flagDirectoryBindDN = flag.String("directory-bind-dn", "", "DN to log in to the LDAP server as (default: anonymous)")
+var flagDirectoryPassword = flag.String("directory-password", "", "password of -directory-bind-dn")
+
+// directoryInterval is how often the people are fetched from -directory
+// again
+const directoryInterval = time.Hour
+
+// peopleDirectory lists the people expected to order, nil without
+// -directory
+var peopleDirectory directory.Source
+
+func setupDirectory() error {
+	peopleDirectory = nil
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch {
+	case *flagDirectory == "":
+	case strings.HasPrefix(*flagDirectory, "google="):
+		if *flagDirectoryKey == "" || *flagDirectoryAdmin == "" {
+			return fmt.Errorf("-directory google=... requires -directory-key and -directory-admin")
+		}
+		peopleDirectory = &directory.Google{
+			Group:  strings.TrimPrefix(*flagDirectory, "google="),
+			Key:    *flagDirectoryKey,
+			Admin:  *flagDirectoryAdmin,
+			Client: client,
+		}
+	case strings.HasPrefix(*flagDirectory, "azure="):
+		tenant, group, ok := strings.Cut(strings.TrimPrefix(*flagDirectory, "azure="), "/")
+		if !ok || tenant == "" || group == "" {
+			return fmt.Errorf("invalid -directory %q, expected azure=<tenant>/<group ID>", *flagDirectory)
+		}
+		if *flagDirectoryClientID == "" || *flagDirectoryClientSecret == "" {
+			return fmt.Errorf("-directory azure=... requires -directory-client-id and -directory-client-secret")
+		}
+		peopleDirectory = &directory.Azure{
+			Tenant:       tenant,
+			Group:        group,
+			ClientID:     *flagDirectoryClientID,
+			ClientSecret: *flagDirectoryClientSecret,
+			Client:       client,
+		}
+	case strings.HasPrefix(*flagDirectory, "ldap://") || strings.HasPrefix(*flagDirectory, "ldaps://"):
+		if *flagDirectoryBase == "" {
+			return fmt.Errorf("-directory ldap://... requires -directory-base")
+		}
+		peopleDirectory = &directory.LDAP{
+			URL:      *flagDirectory,
+			Base:     *flagDirectoryBase,
+			Filter:   *flagDirectoryFilter,
+			BindDN:   *flagDirectoryBindDN,
+			Password: *flagDirectoryPassword,
+		}
+	default:
+		return fmt.Errorf("invalid -directory %q, expected google=<group>, azure=<tenant>/<group ID> or an ldap:// URL", *flagDirectory)
+	}
+	return nil
+}
+
+// peopleSet holds the people from -directory, empty until they were fetched
+type peopleSet struct {
+	mu      sync.RWMutex
+	members []directory.Member
+	updated time.Time
+}
+
+var people = &peopleSet{}
+
+// Members returns the people and when they were fetched
+func (p *peopleSet) Members() ([]directory.Member, time.Time) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.members, p.updated
+}
+
+// loadPeople fetches the people from -directory into people. When that
+// fails, the people fetched before stay.
+func (s *Server) loadPeople(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	members, err := peopleDirectory.Members(ctx)
+	if err != nil {
+		return fmt.Errorf("could not fetch people from %s: %v", peopleDirectory.Name(), err)
+	}
+	if len(members) == 0 {
+		// more likely a wrong group or filter than nobody left in the office
+		return fmt.Errorf("%s lists nobody, keeping the people from before", peopleDirectory.Name())
+	}
+	people.mu.Lock()
+	people.members, people.updated = members, s.cfg.now()
+	people.mu.Unlock()
+	slog.Debug("fetched people from the directory", "directory", peopleDirectory.Name(), "people", len(members))
+	return nil
+}
+
+// runPeople fetches the people from -directory every interval until ctx is
+// done, so new hires and leavers show up without a restart
+func (s *Server) runPeople(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runJob("people", func() {
+			if err := s.loadPeople(ctx); err != nil {
+				slog.Error("could not reload people", "err", err)
+			}
+		})
+	}
+}
+
+// directoryColumn returns the sheet name in names of member: the same name,
+// a name with member's email address in -addresses, or their first name when
+// nobody else in the directory has it. It returns "" for a new hire without
+// a column.
+func (c *config) directoryColumn(names []string, member directory.Member, members []directory.Member) string {
+	if name := matchName(names, member.Name); name != "" {
+		return name
+	}
+	if member.Email != "" {
+		if name := matchName(names, c.nameOf(member.Email)); name != "" {
+			return name
+		}
+	}
+	first := strings.Fields(member.Name)
+	if len(first) < 2 {
+		return ""
+	}
+	for _, other := range members {
+		if other != member && len(strings.Fields(other.Name)) > 0 && order.NameKey(strings.Fields(other.Name)[0]) == order.NameKey(first[0]) {
+			return ""
+		}
+	}
+	return matchName(names, first[0])
+}
+
+// withDirectory makes the people from -directory the people expected to
+// order: it adds a column without an order for everyone the sheet doesn't
+// have yet and leaves out the columns of people no longer in the directory,
+// unless they ordered. Without -directory, or before the people were
+// fetched, names and cells stay as in the sheet.
+func (c *config) withDirectory(names, cells []string) ([]string, []string) {
+	members, _ := people.Members()
+	if len(members) == 0 {
+		return names, cells
+	}
+	persons := make([]string, len(names))
+	for i, column := range names {
+		persons[i], _ = order.SplitColumnName(order.NormalizeName(column))
+	}
+	expected := make(map[string]bool)
+	var missing []string
+	for _, m := range members {
+		if name := c.directoryColumn(persons, m, members); name != "" {
+			expected[order.NameKey(name)] = true
+		} else if m.Name != "" {
+			missing = append(missing, m.Name)
+		}
+	}
+
+	var keptNames, keptCells []string
+	for i, column := range names {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if !expected[order.NameKey(persons[i])] && strings.TrimSpace(cell) == "" {
+			continue
+		}
+		keptNames = append(keptNames, column)
+		keptCells = append(keptCells, cell)
+	}
+	for _, name := range missing {
+		keptNames = append(keptNames, name)
+		keptCells = append(keptCells, "")
+	}
+	return keptNames, keptCells
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "directory",
+		Label: "Fetch the people from the directory",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if peopleDirectory == nil {
+				return "", fmt.Errorf("no directory, see -directory")
+			}
+			if err := s.loadPeople(r.Context()); err != nil {
+				return "", err
+			}
+			members, _ := people.Members()
+			msg := fmt.Sprintf("%d people in %s", len(members), peopleDirectory.Name())
+			names, _, err := s.sheetRow(r.Context(), requestLogger(r), s.cfg.now())
+			if err != nil {
+				return msg, nil
+			}
+			persons := order.New(names, nil).Names
+			var added []string
+			for _, m := range members {
+				if s.cfg.directoryColumn(persons, m, members) == "" {
+					added = append(added, m.Name)
+				}
+			}
+			if len(added) > 0 {
+				msg += ", not in the sheet: " + strings.Join(added, ", ")
+			}
+			return msg, nil
+		},
+	})
+}
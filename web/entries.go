@@ -0,0 +1,332 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// orderEntry is an order someone placed without the sheet, e.g. by email.
+// It replaces what their columns say until the sheet changes: an edit in
+// the sheet afterwards is the newer wish.
+type orderEntry struct {
+	// Order is "" to take the order back
+	Order string `json:"order"`
+	// Was is the person's order in the sheet when the entry came in
+	Was string `json:"was"`
+	// Via is how it came in, e.g. "email from joe@example.com"
+	Via  string    `json:"via"`
+	Time time.Time `json:"time"`
+}
+
+// equal reports whether e and o are the same entry
+func (e orderEntry) equal(o orderEntry) bool {
+	return e.Order == o.Order && e.Was == o.Was && e.Via == o.Via && e.Time.Equal(o.Time)
+}
+
+// entryStore keeps the entries of each day by name in one JSON file per
+// day under <data-dir>/orders
+type entryStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newEntryStore(dataDir string) *entryStore {
+	return &entryStore{dir: filepath.Join(dataDir, "orders")}
+}
+
+// Load returns the entries on a date (2006-01-02) by name
+func (e *entryStore) Load(date string) (map[string]orderEntry, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.load(date)
+}
+
+func (e *entryStore) load(date string) (map[string]orderEntry, error) {
+	day := make(map[string]orderEntry)
+	b, err := os.ReadFile(filepath.Join(e.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return day, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &day); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return day, nil
+}
+
+// errEntryChanged means an entry changed between reading and replacing it
+var errEntryChanged = errors.New("the order changed in the meantime")
+
+// SetIf stores the entry of name on a date, replacing an earlier one, but
+// only while their entry is still seen, nil for none, and returns
+// errEntryChanged otherwise
+func (e *entryStore) SetIf(date, name string, seen *orderEntry, entry orderEntry) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	day, err := e.load(date)
+	if err != nil {
+		return err
+	}
+	if current, ok := day[name]; ok != (seen != nil) || ok && !current.equal(*seen) {
+		return errEntryChanged
+	}
+	day[name] = entry
+	return e.save(date, day)
+}
+
+func (e *entryStore) save(date string, day map[string]orderEntry) error {
+	b, err := json.MarshalIndent(day, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(e.dir, date+".json"), b)
+}
+
+// Purge deletes the entries of every day before cutoff (2006-01-02)
+func (e *entryStore) Purge(cutoff string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	files, err := os.ReadDir(e.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(e.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Overlay returns names and cells, the header and the row of a date in the
+// sheet, with the entries of that date in place of the sheet's orders. An
+// entry goes in the person's first column and empties the others; people
+// without a column get one at the end.
+func (e *entryStore) Overlay(date string, names, cells []string) ([]string, []string, error) {
+	day, err := e.Load(date)
+	if err != nil {
+		return names, cells, err
+	}
+	names, cells = overlay(day, names, cells)
+	return names, cells, nil
+}
+
+// overlay puts the entries of a day in place of the sheet's orders, as
+// Overlay does
+func overlay(day map[string]orderEntry, names, cells []string) ([]string, []string) {
+	if len(day) == 0 {
+		return names, cells
+	}
+	sheetOrders := order.New(names, cells)
+	names = append([]string(nil), names...)
+	cells = append([]string(nil), cells...)
+	for len(cells) < len(names) {
+		cells = append(cells, "")
+	}
+	// sorted, so people without a column are added in the same order
+	for _, name := range slices.Sorted(maps.Keys(day)) {
+		entry := day[name]
+		if sheetOrders.OrderOf(name) != entry.Was {
+			// changed in the sheet since
+			continue
+		}
+		key := order.NameKey(name)
+		found := false
+		for i, column := range names {
+			person, _ := order.SplitColumnName(order.NormalizeName(column))
+			if order.NameKey(person) != key {
+				continue
+			}
+			if found {
+				cells[i] = ""
+			} else {
+				cells[i] = entry.Order
+				found = true
+			}
+		}
+		if !found && entry.Order != "" {
+			names = append(names, name)
+			cells = append(cells, entry.Order)
+		}
+	}
+	return names, cells
+}
+
+// entryNotes returns how the orders in oo that came in outside the sheet
+// did by name, shown next to them
+func (s *Server) entryNotes(date string, oo *order.Overview) map[string]string {
+	day, err := s.entries.Load(date)
+	if err != nil || len(day) == 0 {
+		return nil
+	}
+	notes := make(map[string]string)
+	for name, entry := range day {
+		for _, li := range oo.LineItems() {
+			if order.NameKey(li.Name) == order.NameKey(name) && entry.Order == li.Order {
+				notes[li.Name] = fmt.Sprintf("%s at %s", entry.Via, entry.Time.Format("15:04"))
+			}
+		}
+	}
+	return notes
+}
+
+// errOrdersFrozen means orders can't be placed in maintenance mode
+var errOrdersFrozen = errors.New("orders are frozen in maintenance mode")
+
+// orderAttempts is how often storing an order is tried while other orders
+// for the same person come in
+const orderAttempts = 3
+
+// orderConflict means someone's order changed after they last saw it, e.g.
+// by email while they had the Slack form open, or while theirs was being
+// placed
+type orderConflict struct {
+	Name string
+	// Order is their order now
+	Order string
+}
+
+func (c *orderConflict) Error() string {
+	if c.Order == "" {
+		return fmt.Sprintf("the order of %s was taken back in the meantime", c.Name)
+	}
+	return fmt.Sprintf("the order of %s changed to %q in the meantime", c.Name, c.Order)
+}
+
+// notRecorded is what to tell someone whose order item wasn't placed
+// because of the conflict
+func (c *orderConflict) notRecorded(item string) string {
+	undone := fmt.Sprintf("record %q", item)
+	if item == "" {
+		undone = "take your order back"
+	}
+	return fmt.Sprintf("Sorry, %v, so I didn't %s. Order again if you still want to change it.\n", c, undone)
+}
+
+// placeOrder records item as the order of name today, placed outside the
+// sheet via e.g. "email from joe@example.com", and returns what to answer
+// them, also when the order can't be taken, e.g. without a row for today.
+// After the deadline it goes on the late list. An error means something
+// went wrong and it's worth trying again later, except for an
+// *orderConflict, when another order for them came in at the same time.
+func (s *Server) placeOrder(r *http.Request, name, item, via string) (string, error) {
+	return s.placeOrderOver(r, name, item, via, nil)
+}
+
+// placeOrderOver is placeOrder for someone who changed their order from
+// seen, nil when unknown. When it isn't their order anymore, nothing is
+// placed and an *orderConflict says what it is now.
+//
+// Orders placed outside the sheet are kept next to it, in <data-dir>/orders,
+// and never written into the sheet: lunchweb only reads the sheet, writing
+// it back is out of scope. An order placed while the sheet's cell changes
+// loses to the sheet, like any change in the sheet afterwards. Storing it is
+// tried again up to orderAttempts times while other orders for them come
+// in, as long as they don't change what their order is.
+func (s *Server) placeOrderOver(r *http.Request, name, item, via string, seen *string) (string, error) {
+	if inMaintenance() {
+		return "", errOrdersFrozen
+	}
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	if reason := closures.Reason(now.Format(timeLayout)); reason != "" {
+		return fmt.Sprintf("Sorry, the office is closed today (%s), I didn't record %q.\n", reason, item), nil
+	}
+	deadline, hasDeadline := s.cfg.deadlineOn(now)
+	if hasDeadline && now.After(deadline) {
+		return s.placeLateOrder(r, name, item, via, deadline)
+	}
+	date := now.Format(timeLayout)
+	names, cells, err := s.sheetRow(r.Context(), logger, now)
+	if errors.Is(err, sheet.ErrNoRow) {
+		return "Sorry, the sheet has no row for today, so there is nothing to order.\n", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	inSheet := order.New(names, cells).OrderOf(name)
+	entry := orderEntry{Order: item, Was: inSheet, Via: via, Time: now}
+	var (
+		current string
+		prev    orderEntry
+		placed  bool
+	)
+	for attempt := 0; ; attempt++ {
+		var day map[string]orderEntry
+		day, err = s.entries.Load(date)
+		if err != nil {
+			return "", fmt.Errorf("could not read the orders: %v", err)
+		}
+		was := current
+		current = order.New(overlay(day, names, cells)).OrderOf(name)
+		if seen != nil && current != *seen {
+			return "", &orderConflict{Name: name, Order: current}
+		}
+		// another order for them between reading and storing theirs isn't
+		// overwritten unseen
+		if attempt > 0 && (current != was || attempt == orderAttempts) {
+			logger.Info("order changed while placing another", "name", name, "via", via, "attempts", attempt)
+			return "", &orderConflict{Name: name, Order: current}
+		}
+		prev, placed = day[name]
+		var last *orderEntry
+		if placed {
+			last = &prev
+		}
+		err = s.entries.SetIf(date, name, last, entry)
+		if !errors.Is(err, errEntryChanged) {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not store the order: %v", err)
+	}
+	s.audit.Record(r, "order/"+strings.ToLower(strings.Fields(via)[0]), fmt.Sprintf("%s: %q", name, item))
+	logger.Info("order placed outside the sheet", "name", name, "order", item, "via", via)
+
+	var reply string
+	if item == "" {
+		reply = fmt.Sprintf("Got it %s, you don't order anything today.\n", name)
+	} else {
+		reply = fmt.Sprintf("Got it %s, your order today is: %s\n", name, item)
+	}
+	if current != "" && current != item {
+		if placed && prev.Was == inSheet && prev.Order == current {
+			reply += fmt.Sprintf("That replaces %q (%s, %s).\n", current, prev.Via, prev.Time.Format("15:04"))
+		} else {
+			reply += fmt.Sprintf("That replaces %q (in the sheet).\n", current)
+		}
+	}
+	if hasDeadline {
+		reply += fmt.Sprintf("You can change it until %s. ", deadline.Format("15:04"))
+	}
+	return reply + "A change in the sheet afterwards takes precedence.\n", nil
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// errorPage explains on a page why the orders can't be shown
+type errorPage struct {
+	Status   int
+	Title    string
+	Guidance string
+	Detail   string
+	// Retry is the URL to try again, if that could help
+	Retry string
+}
+
+// renderSheetError shows what went wrong reading the orders for the
+// request, with what to do about it
+func (s *Server) renderSheetError(w http.ResponseWriter, r *http.Request, err error) {
+	page := errorPage{
+		Status:   http.StatusInternalServerError,
+		Title:    "Something went wrong",
+		Guidance: "Try again in a moment. If this keeps happening, tell whoever runs LunchWeb.",
+		Detail:   err.Error(),
+		Retry:    r.URL.RequestURI(),
+	}
+	switch {
+	case isTimeout(err):
+		page.Status = http.StatusGatewayTimeout
+		page.Title = "The order sheet took too long to answer"
+		page.Guidance = "Google Sheets is slow right now. Try again in a moment."
+	case errors.Is(err, errSheetUnreachable):
+		page.Status = http.StatusBadGateway
+		page.Title = "The order sheet can't be reached"
+		page.Guidance = "Check that the sheet is still published to the web as CSV and that its URL hasn't changed. Until then you can fill in your order in the sheet directly."
+	case errors.Is(err, sheet.ErrNoRow):
+		page.Status = http.StatusNotFound
+		page.Title = "There is no row for today in the order sheet"
+		page.Guidance = "Add a row with today's date in the first column (YYYY-MM-DD), then try again."
+	case errors.Is(err, sheet.ErrMalformed):
+		page.Status = http.StatusBadGateway
+		page.Title = "The order sheet doesn't look right"
+		page.Guidance = "Someone may have moved the header row or the date column. Run lunchweb validate to find the cells to fix."
+	}
+
+	requestLogger(r).Warn("showing error page", "status", page.Status, "err", err)
+	s.renderErrorPage(w, r, page)
+}
+
+// renderErrorPage writes page with its status code
+func (s *Server) renderErrorPage(w http.ResponseWriter, r *http.Request, page errorPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(page.Status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	t, err := pages()
+	if err != nil {
+		requestLogger(r).Error("could not parse templates", "err", err)
+		return
+	}
+	if err := t.ExecuteTemplate(w, "error", map[string]interface{}{
+		"Error":    page,
+		"SheetURL": s.cfg.sheetURL,
+	}); err != nil {
+		requestLogger(r).Error("could not render template", "template", "error", "err", err)
+	}
+}
+
+// notFound answers paths nothing is served at, such as /favicon.ico
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	s.renderErrorPage(w, r, errorPage{
+		Status:   http.StatusNotFound,
+		Title:    "Page not found",
+		Guidance: "There is nothing at " + r.URL.Path + ".",
+	})
+}
+
+// allowMethods answers other methods than the given ones with 405. Handlers
+// for GET answer HEAD too, net/http leaves out the body.
+func (s *Server) allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	for _, m := range methods {
+		if m == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+			break
+		}
+	}
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				h(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		s.renderErrorPage(w, r, errorPage{
+			Status:   http.StatusMethodNotAllowed,
+			Title:    "Method not allowed",
+			Guidance: r.Method + " is not supported here, use " + allow + ".",
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var flagETAInterval = flag.Duration("eta-interval", time.Minute, "how often to poll the eta_url of the day's vendor while lunch is on its way")
+
+// trackingSuffix ends the name of a day's tracking, after the date:
+// 2006-01-02.tracking.json next to the delivery steps in 2006-01-02.json
+const trackingSuffix = ".tracking.json"
+
+// tracking is where a day's delivery is as far as we know
+type tracking struct {
+	// URL is the courier's or vendor's tracking page, set on the admin page
+	URL string `json:"url,omitempty"`
+	// ETA is the estimated arrival, entered on the admin page or polled
+	// from the vendor's eta_url
+	ETA *time.Time `json:"eta,omitempty"`
+	// Polled is when the eta_url last gave the ETA, nil when it was entered;
+	// an entered ETA isn't polled over
+	Polled *time.Time `json:"polled,omitempty"`
+	By     string     `json:"by,omitempty"`
+}
+
+// Tracking returns the tracking of the delivery on a date (2006-01-02),
+// empty when there is none
+func (d *deliveryStore) Tracking(date string) (*tracking, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.tracking(date)
+}
+
+func (d *deliveryStore) tracking(date string) (*tracking, error) {
+	var tr tracking
+	b, err := os.ReadFile(filepath.Join(d.dir, date+trackingSuffix))
+	if os.IsNotExist(err) {
+		return &tr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tr); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return &tr, nil
+}
+
+// UpdateTracking changes the tracking of a date with change and stores it
+func (d *deliveryStore) UpdateTracking(date string, change func(tr *tracking) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tr, err := d.tracking(date)
+	if err != nil {
+		return err
+	}
+	if err := change(tr); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(tr, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.dir, date+trackingSuffix), b)
+}
+
+// etaRequest is what an eta_url template is filled in with
+type etaRequest struct {
+	Date     string
+	Tracking string
+}
+
+// parseETAURL parses the eta_url of a vendor, which has to make an http(s)
+// URL
+func parseETAURL(text string) (*template.Template, error) {
+	t, err := template.New("eta_url").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, etaRequest{Date: "2006-01-02", Tracking: "https://track.example.com/1"}); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(b.String()); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, errors.New("must be an http(s) URL")
+	}
+	return t, nil
+}
+
+// parseETA reads an arrival from an eta_url response at now: a time of day
+// like "12:25", RFC 3339, or a number of minutes from now
+func parseETA(value interface{}, now time.Time) (time.Time, error) {
+	switch v := value.(type) {
+	case float64:
+		return now.Add(time.Duration(v * float64(time.Minute))).Truncate(time.Minute), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.In(now.Location()), nil
+		}
+		var clock timeOfDay
+		if err := clock.Set(v); err == nil && clock.IsSet() {
+			return clock.On(now), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected ETA %v", value)
+}
+
+// jsonField returns the field at a dotted path like "delivery.eta" in a
+// decoded JSON object
+func jsonField(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+var etaClient = &http.Client{Timeout: 15 * time.Second}
+
+// fetchETA asks the eta_url of v for the arrival of the delivery on the day
+// of now
+func fetchETA(ctx context.Context, v *Vendor, tr *tracking, now time.Time) (time.Time, error) {
+	var u bytes.Buffer
+	if err := v.etaURL.Execute(&u, etaRequest{Date: now.Format(timeLayout), Tracking: tr.URL}); err != nil {
+		return time.Time{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := etaClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return time.Time{}, fmt.Errorf("invalid response: %v", err)
+	}
+	field := v.ETAField
+	if field == "" {
+		field = "eta"
+	}
+	value, ok := jsonField(body, field)
+	if !ok {
+		return time.Time{}, fmt.Errorf("no %q in the response", field)
+	}
+	return parseETA(value, now)
+}
+
+// pollETA updates today's ETA from the vendor's eta_url while lunch is
+// ordered or out for delivery, unless one was entered on the admin page
+func (s *Server) pollETA(ctx context.Context) error {
+	now := s.cfg.now()
+	v := s.cfg.vendorFor(now)
+	if v == nil || v.etaURL == nil {
+		return nil
+	}
+	date := now.Format(timeLayout)
+	delivery, err := s.deliveryOn(date)
+	if err != nil {
+		return err
+	}
+	if delivery.State != deliveryOrdered && delivery.State != deliveryOnTheWay {
+		return nil
+	}
+	if delivery.ETA != nil && delivery.Polled == nil {
+		return nil
+	}
+	tr, err := s.deliveries.Tracking(date)
+	if err != nil {
+		return err
+	}
+	eta, err := fetchETA(ctx, v, tr, now)
+	if err != nil {
+		return fmt.Errorf("eta_url of %s: %v", v.Name, err)
+	}
+	return s.deliveries.UpdateTracking(date, func(tr *tracking) error {
+		if tr.ETA != nil && tr.Polled == nil {
+			return nil
+		}
+		tr.ETA, tr.Polled = &eta, &now
+		return nil
+	})
+}
+
+// runETA polls the day's ETA every interval until ctx is done
+func (s *Server) runETA(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runJob("eta", func() {
+			if err := s.pollETA(ctx); err != nil {
+				slog.Error("could not poll ETA", "err", err)
+			}
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollsETA reports whether any vendor has an eta_url
+func (c *config) pollsETA() bool {
+	for _, v := range c.vendors {
+		if v.etaURL != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTracking sets today's tracking URL and ETA from the admin page; an
+// empty ETA goes back to polling the vendor's eta_url
+func (s *Server) handleTracking(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	now := s.cfg.now()
+	link := strings.TrimSpace(r.FormValue("url"))
+	if u, err := url.Parse(link); link != "" && (err != nil || (u.Scheme != "https" && u.Scheme != "http")) {
+		http.Error(w, "the tracking URL must be an http(s) URL", http.StatusBadRequest)
+		return
+	}
+	var eta *time.Time
+	var clock timeOfDay
+	if err := clock.Set(strings.TrimSpace(r.FormValue("eta"))); err != nil {
+		http.Error(w, "invalid ETA: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if clock.IsSet() {
+		t := clock.On(now)
+		eta = &t
+	}
+	err := s.deliveries.UpdateTracking(now.Format(timeLayout), func(tr *tracking) error {
+		tr.URL, tr.By = link, identityFromRequest(r).String()
+		if eta != nil || tr.Polled == nil {
+			tr.ETA, tr.Polled = eta, nil
+		}
+		return nil
+	})
+	if err != nil {
+		requestLogger(r).Error("could not store tracking", "err", err)
+		http.Error(w, "could not store the tracking", http.StatusInternalServerError)
+		return
+	}
+	detail := link
+	if eta != nil {
+		detail = strings.TrimSpace(fmt.Sprintf("%s ETA %s", link, eta.Format("15:04")))
+	}
+	s.audit.Record(r, "tracking", detail)
+	http.Redirect(w, r, "/admin?msg="+url.QueryEscape("Tracking saved"), http.StatusSeeOther)
+}
@@ -0,0 +1,212 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagExpenseTemplate = flag.String("expense-template", "concur", "columns of the expense export of the monthly reports: concur, generic, or a CSV file with a header line and a line of templates, e.g. {{.Date}},{{.Total.Decimal}}")
+var flagCostCenter = flag.String("cost-center", "", "cost center of the lunch expenses in the expense export")
+var flagVAT = flag.String("vat", "", "VAT rate in percent included in the prices, e.g. 9, split off in the expense export")
+var flagExpenseType = flag.String("expense-type", "Meals", "expense type of the lunch expenses in the expense export")
+
+// expensePresets are the -expense-template columns known by name
+var expensePresets = map[string]string{
+	"concur": "Transaction Date,Expense Type,Vendor,Amount,Currency,Tax Amount,Cost Center,Attendees,Attendee Count,Comment\n" +
+		`"{{.Time.Format ""01/02/2006""}}",{{.Type}},{{.Vendor}},{{.Total.Decimal}},{{.Currency}},{{.VAT.Decimal}},{{.CostCenter}},{{.AttendeeList}},{{.Count}},{{.Comment}}` + "\n",
+	"generic": "date,vendor,net,vat,vat_rate,total,currency,cost_center,attendees,attendee_emails,comment\n" +
+		"{{.Date}},{{.Vendor}},{{.Net.Decimal}},{{.VAT.Decimal}},{{.VATRate}},{{.Total.Decimal}},{{.Currency}},{{.CostCenter}},{{.AttendeeList}},{{.EmailList}},{{.Comment}}\n",
+}
+
+// expenseTemplate is the header of the expense export and a template for
+// each cell of its rows
+type expenseTemplate struct {
+	Header []string
+	Cells  []*template.Template
+}
+
+// loadExpenseTemplate reads -expense-template, a preset name or a file
+func loadExpenseTemplate(source string) (*expenseTemplate, error) {
+	text, ok := expensePresets[source]
+	if !ok {
+		b, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: expected a header line: %v", source, err)
+	}
+	row, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: expected a line of templates after the header: %v", source, err)
+	}
+	t := &expenseTemplate{Header: header}
+	for i, cell := range row {
+		tmpl, err := template.New(header[i]).Parse(cell)
+		if err != nil {
+			return nil, fmt.Errorf("%s: column %q: %v", source, header[i], err)
+		}
+		t.Cells = append(t.Cells, tmpl)
+	}
+	sample := &expenseRow{Date: "2006-01-02", Attendees: []string{"Joe"}, Emails: []string{""}, Count: 1}
+	if _, err := t.row(sample); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	return t, nil
+}
+
+func (t *expenseTemplate) row(e *expenseRow) ([]string, error) {
+	cells := make([]string, len(t.Cells))
+	var b bytes.Buffer
+	for i, tmpl := range t.Cells {
+		b.Reset()
+		if err := tmpl.Execute(&b, e); err != nil {
+			return nil, err
+		}
+		cells[i] = b.String()
+	}
+	return cells, nil
+}
+
+// parseVAT reads -vat, a percentage like 9 or 5.5, as hundredths of a
+// percent
+func parseVAT(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || rate < 0 || rate >= 100 {
+		return 0, fmt.Errorf("invalid -vat %q, expected a percentage like 9", value)
+	}
+	return int(math.Round(rate * 100)), nil
+}
+
+// expenseRow is the lunch of one archived day as an expense
+type expenseRow struct {
+	Date   string
+	Vendor string
+	// Total includes VAT; it is the invoice's when one was entered, or else
+	// what the priced orders add up to
+	Total order.Money
+	Net   order.Money
+	VAT   order.Money
+	// VATRate is -vat, e.g. "9"
+	VATRate    string
+	Currency   string
+	CostCenter string
+	Type       string
+	// Attendees are the people who ordered and Emails their addresses, ""
+	// where unknown
+	Attendees []string
+	Emails    []string
+	Count     int
+	Comment   string
+}
+
+// Time returns the day, e.g. for {{.Time.Format "01/02/2006"}}
+func (e *expenseRow) Time() time.Time {
+	t, _ := time.Parse(timeLayout, e.Date)
+	return t
+}
+
+// AttendeeList returns the attendees separated by semicolons
+func (e *expenseRow) AttendeeList() string {
+	return strings.Join(e.Attendees, "; ")
+}
+
+// EmailList returns the known addresses of the attendees separated by
+// semicolons
+func (e *expenseRow) EmailList() string {
+	var known []string
+	for _, email := range e.Emails {
+		if email != "" {
+			known = append(known, email)
+		}
+	}
+	return strings.Join(known, "; ")
+}
+
+// expenseRows turns the snapshots of a month into one expense per day with
+// orders
+func (s *Server) expenseRows(snapshots []*Snapshot) ([]*expenseRow, error) {
+	var rows []*expenseRow
+	for _, snap := range snapshots {
+		oo := snap.Overview()
+		items := oo.LineItems()
+		if len(items) == 0 {
+			continue
+		}
+		total, unpriced := spend(oo)
+		inv, err := s.archive.Invoice(snap.Date)
+		if err != nil {
+			return nil, err
+		}
+		e := &expenseRow{
+			Date:       snap.Date,
+			Vendor:     s.cfg.vendorName(snap.Time()),
+			Total:      total,
+			Currency:   order.CurrentCurrency().Code,
+			CostCenter: s.cfg.costCenter,
+			Type:       s.cfg.expenseType,
+			Count:      len(items),
+			Comment:    fmt.Sprintf("Lunch, %d people", len(items)),
+		}
+		if inv != nil {
+			e.Total = inv.Total
+			e.Comment += ", as invoiced"
+		} else if unpriced > 0 {
+			e.Comment += fmt.Sprintf(", %d orders without a price", unpriced)
+		}
+		for _, li := range items {
+			e.Attendees = append(e.Attendees, li.Name)
+			e.Emails = append(e.Emails, s.cfg.emailOf(li.Name))
+		}
+		e.Net, e.VAT = e.Total, 0
+		if s.cfg.vat > 0 {
+			e.Net = order.Money(math.Round(float64(e.Total) * 10000 / float64(10000+s.cfg.vat)))
+			e.VAT = e.Total - e.Net
+			e.VATRate = strconv.FormatFloat(float64(s.cfg.vat)/100, 'f', -1, 64)
+		}
+		rows = append(rows, e)
+	}
+	return rows, nil
+}
+
+// writeExpenseCSV exports the expenses of a month in -expense-template
+func (s *Server) writeExpenseCSV(w http.ResponseWriter, month string, rows []*expenseRow) {
+	var b bytes.Buffer
+	cw := csv.NewWriter(&b)
+	cw.Write(s.cfg.expenseTemplate.Header)
+	for _, e := range rows {
+		cells, err := s.cfg.expenseTemplate.row(e)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid -expense-template: %v", err), http.StatusInternalServerError)
+			return
+		}
+		// attendees are names typed in the sheet, see csvCell
+		for i := range cells {
+			cells[i] = csvCell(cells[i])
+		}
+		cw.Write(cells)
+	}
+	cw.Flush()
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="lunch-expenses-`+month+`.csv"`)
+	io.Copy(w, &b)
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// envPrefix is prepended to a flag's name to get its environment variable,
+// e.g. -csvurl is LUNCHWEB_CSVURL and -log-level is LUNCHWEB_LOG_LEVEL
+const envPrefix = "LUNCHWEB_"
+
+// envName returns the environment variable for the flag name
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setFlagsFromEnv sets the flags of fs from their environment variables.
+// Call it before fs.Parse so the command line wins. Repeatable flags take a
+// comma separated list, which the flag on the command line replaces.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		values := []string{value}
+		list, isList := f.Value.(*stringList)
+		if isList {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if e := fs.Set(f.Name, strings.TrimSpace(v)); e != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), e)
+				return
+			}
+		}
+		if isList {
+			listsFromEnv[list] = true
+		}
+	})
+	return err
+}
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+// listsFromEnv are the stringList flags set from the environment, until the
+// command line sets them instead
+var listsFromEnv = make(map[*stringList]bool)
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	if listsFromEnv[s] {
+		delete(listsFromEnv, s)
+		*s = nil
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+// timeOfDay is a flag holding a wall clock time like "14:30", stored as the
+// duration since midnight. The zero value means unset.
+type timeOfDay time.Duration
+
+func (t *timeOfDay) String() string {
+	if *t == 0 {
+		return ""
+	}
+	d := time.Duration(*t)
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func (t *timeOfDay) Set(value string) error {
+	if value == "" {
+		*t = 0
+		return nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return fmt.Errorf("expected a time like 14:30")
+	}
+	*t = timeOfDay(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	return nil
+}
+
+// IsSet reports whether a time was given
+func (t timeOfDay) IsSet() bool {
+	return t != 0
+}
+
+// On returns the time of day on the date of day
+func (t timeOfDay) On(day time.Time) time.Time {
+	return startOfDay(day).Add(time.Duration(t))
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+const (
+	// forecastWeeks is how many past weeks the forecast looks at
+	forecastWeeks = 8
+	// minForecastDays is how many archived days the forecast needs to mean
+	// something
+	minForecastDays = 2
+)
+
+// forecast estimates how many will have ordered by the deadline
+type forecast struct {
+	Expected int
+	Ordered  int
+	// Days are the archived days on the same weekday it goes by
+	Days    int
+	Weekday time.Weekday
+}
+
+func (f *forecast) String() string {
+	return fmt.Sprintf("Expect ~%d orders today, going by the last %d %ss.", f.Expected, f.Days, f.Weekday)
+}
+
+// forecastOn estimates how many will order on the day of t, of which oo
+// are the orders so far: everyone who ordered, plus for everyone else how
+// often they ordered on the same weekday in the past weeks. It returns nil
+// when the archive has too few of those days.
+func (s *Server) forecastOn(t time.Time, oo *order.Overview) (*forecast, error) {
+	var history []*order.Overview
+	for w := 1; w <= forecastWeeks; w++ {
+		snap, err := s.archive.Load(t.AddDate(0, 0, -7*w).Format(timeLayout))
+		if err != nil {
+			return nil, err
+		}
+		if snap != nil {
+			history = append(history, snap.Overview())
+		}
+	}
+	if len(history) < minForecastDays {
+		return nil, nil
+	}
+
+	f := &forecast{Ordered: len(oo.LineItems()), Days: len(history), Weekday: t.Weekday()}
+	expected := float64(f.Ordered)
+	for _, name := range oo.Names {
+		if name == "" || oo.OrderOf(name) != "" {
+			continue
+		}
+		ordered := 0
+		for _, day := range history {
+			if day.OrderOf(name) != "" {
+				ordered++
+			}
+		}
+		expected += float64(ordered) / float64(len(history))
+	}
+	f.Expected = int(math.Round(expected))
+	return f, nil
+}
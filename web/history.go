@@ -0,0 +1,94 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// historyResult is an archived order matching a search
+type historyResult struct {
+	Date   string `json:"date"`
+	Vendor string `json:"vendor,omitempty"`
+	Name   string `json:"name"`
+	Order  string `json:"order"`
+}
+
+const maxHistoryResults = 500
+
+// searchHistory finds archived orders whose text or vendor contains q and
+// whose person contains person, newest first. Matching ignores case.
+func (s *Server) searchHistory(snapshots []*Snapshot, q, person string) []historyResult {
+	q, person = strings.ToLower(q), strings.ToLower(person)
+	var results []historyResult
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snap := snapshots[i]
+		vendor := s.cfg.vendorName(snap.Time())
+		for _, li := range snap.Overview().LineItems() {
+			if person != "" && !strings.Contains(strings.ToLower(li.Name), person) {
+				continue
+			}
+			if q != "" && !strings.Contains(strings.ToLower(li.Order), q) && !strings.Contains(strings.ToLower(vendor), q) {
+				continue
+			}
+			results = append(results, historyResult{Date: snap.Date, Vendor: vendor, Name: li.Name, Order: li.Order})
+			if len(results) == maxHistoryResults {
+				return results
+			}
+		}
+	}
+	return results
+}
+
+// handleHistory searches the archive. It answers JSON for ?format=json or
+// when the client asks for it.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, err := s.parseDateRange(r, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snapshots, err := s.archive.Range(from, to)
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+
+	q := strings.TrimSpace(r.FormValue("q"))
+	person := strings.TrimSpace(r.FormValue("person"))
+	results := s.searchHistory(snapshots, q, person)
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if results == nil {
+			results = []historyResult{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"q":       q,
+			"person":  person,
+			"from":    from,
+			"to":      to,
+			"results": results,
+		})
+		return
+	}
+	render(w, r, "history", map[string]interface{}{
+		"Q":       q,
+		"Person":  person,
+		"From":    from,
+		"To":      to,
+		"Results": results,
+		"Limited": len(results) == maxHistoryResults,
+	})
+}
+
+// wantsJSON reports whether the client asked for JSON with ?format=json or
+// an Accept header
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
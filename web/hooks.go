@@ -0,0 +1,58 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// withHooks serves the routes under /hooks/, which other services like a
+// mail service call, and everything else with next. Services can't log in,
+// so hooks skip the authentication in next and check a secret of their own.
+// Shortlinks skip it too, as they only redirect.
+func (s *Server) withHooks(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", next)
+	mux.HandleFunc("/s/", s.allowMethods(s.handleShortlink, http.MethodGet))
+	if s.cfg.inboundEmailToken != "" || s.cfg.mailgunSigningKey != "" {
+		// the sender address is only worth something when the request
+		// comes from the mail service
+		email := s.handleInboundEmail
+		if s.cfg.mailgunSigningKey != "" {
+			email = requireMailgunSignature(s.cfg.mailgunSigningKey, email)
+		}
+		if s.cfg.inboundEmailToken != "" {
+			email = requireHookToken(s.cfg.inboundEmailToken, email)
+		}
+		mux.HandleFunc("/hooks/email", s.allowMethods(email, http.MethodPost))
+	}
+	if s.cfg.twilioAuthToken != "" {
+		// Twilio signs its requests instead
+		mux.HandleFunc("/hooks/sms", s.allowMethods(s.handleSMS, http.MethodPost))
+	}
+	if s.slackClient != nil {
+		// and Slack too
+		mux.HandleFunc("/hooks/slack", s.allowMethods(s.handleSlack, http.MethodPost))
+	}
+	if s.teamsClient != nil {
+		// and the Bot Framework sends a token
+		mux.HandleFunc("/hooks/teams", s.allowMethods(s.handleTeams, http.MethodPost))
+	}
+	return mux
+}
+
+// requireHookToken rejects requests without token, given as ?token= or as
+// the password of basic auth, whichever the service supports
+func requireHookToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given := r.URL.Query().Get("token")
+		if _, password, ok := r.BasicAuth(); ok {
+			given = password
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			requestLogger(r).Warn("hook called without its token", "path", r.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
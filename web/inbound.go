@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+var (
+	flagInboundEmailToken = flag.String("inbound-email-token", "", "take orders from emails a mail service posts to /hooks/email?token=<this token> (default: off)")
+	flagMailgunSigningKey = flag.String("mailgun-signing-key", "", "take orders from emails Mailgun posts to /hooks/email, checking Mailgun's signature with this webhook signing key (default: off)")
+)
+
+// mailgunMaxAge is how old a Mailgun signature may be, so recorded requests
+// can't be replayed
+const mailgunMaxAge = 5 * time.Minute
+
+// maxInboundEmailSize bounds a posted email, attachments included
+const maxInboundEmailSize = 10 << 20
+
+// orderLineRe finds "order: club sandwich" in the subject or a line of the
+// body
+var orderLineRe = regexp.MustCompile(`(?i)^\s*order\s*:\s*(.*?)\s*$`)
+
+// cancelWords take an order back, as in "order: none"
+var cancelWords = map[string]bool{"none": true, "nothing": true, "cancel": true, "-": true, "no lunch": true}
+
+// inboundEmail is the part of a posted email lunchweb reads
+type inboundEmail struct {
+	From    string
+	Subject string
+	Text    string
+}
+
+// readInboundEmail reads the email the way the common mail services post
+// it: Postmark's JSON, or the form fields of Mailgun ("sender",
+// "body-plain") and SendGrid ("from", "text")
+func readInboundEmail(w http.ResponseWriter, r *http.Request) (*inboundEmail, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundEmailSize)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var postmark struct {
+			From     string
+			FromFull struct{ Email string }
+			Subject  string
+			TextBody string
+			// StrippedTextReply leaves out the quoted email replied to
+			StrippedTextReply string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&postmark); err != nil {
+			return nil, err
+		}
+		email := &inboundEmail{From: postmark.FromFull.Email, Subject: postmark.Subject, Text: postmark.TextBody}
+		if email.From == "" {
+			email.From = postmark.From
+		}
+		if postmark.StrippedTextReply != "" {
+			email.Text = postmark.StrippedTextReply
+		}
+		return email, nil
+	}
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, err
+	}
+	email := &inboundEmail{Subject: r.FormValue("subject")}
+	for _, field := range []string{"sender", "from"} {
+		if email.From = r.FormValue(field); email.From != "" {
+			break
+		}
+	}
+	for _, field := range []string{"stripped-text", "body-plain", "text"} {
+		if email.Text = r.FormValue(field); email.Text != "" {
+			break
+		}
+	}
+	return email, nil
+}
+
+// verifyMailgun checks the signature Mailgun adds to the emails it posts:
+// the HMAC-SHA256 of the timestamp and token fields with the signing key,
+// made a moment before now
+func verifyMailgun(key string, r *http.Request, now time.Time) error {
+	timestamp, token := r.FormValue("timestamp"), r.FormValue("token")
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("no timestamp")
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > mailgunMaxAge || age < -mailgunMaxAge {
+		return fmt.Errorf("timestamp too far off: %s", age)
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + token))
+	if !hmac.Equal([]byte(r.FormValue("signature")), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// requireMailgunSignature rejects posted emails Mailgun didn't sign with key
+func requireMailgunSignature(key string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxInboundEmailSize)
+		if err := r.ParseMultipartForm(1 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "could not read the email", http.StatusBadRequest)
+			return
+		}
+		if err := verifyMailgun(key, r, time.Now()); err != nil {
+			requestLogger(r).Warn("email hook called without a valid Mailgun signature", "err", err)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// parseOrderEmail returns the order in the subject or body of an email,
+// and false when it has none. The order is "" for "order: none". Quoted
+// lines of an earlier email don't count.
+func parseOrderEmail(subject, text string) (string, bool) {
+	lines := []string{subject}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, ">") || line == "-- " {
+			break
+		}
+		lines = append(lines, line)
+	}
+	for _, line := range lines {
+		// "Re: order: ..." replying to a confirmation
+		line = strings.TrimSpace(line)
+		for strings.HasPrefix(strings.ToLower(line), "re:") {
+			line = strings.TrimSpace(line[3:])
+		}
+		if m := orderLineRe.FindStringSubmatch(line); m != nil {
+			if cancelWords[strings.ToLower(m[1])] {
+				return "", true
+			}
+			if m[1] != "" {
+				return m[1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// handleInboundEmail records the order in an email a mail service posts
+// as the order of the sender, who must be in -addresses. The sender gets
+// what was recorded back by email when an email notifier is set up.
+func (s *Server) handleInboundEmail(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	email, err := readInboundEmail(w, r)
+	if err != nil {
+		logger.Warn("could not read posted email", "err", err)
+		http.Error(w, "could not read the email", http.StatusBadRequest)
+		return
+	}
+	from, err := mail.ParseAddress(email.From)
+	if err != nil {
+		logger.Warn("posted email without sender", "from", email.From)
+		http.Error(w, "no sender", http.StatusBadRequest)
+		return
+	}
+	name := s.cfg.nameOf(from.Address)
+	if name == "" {
+		// answered with 200 so the mail service doesn't retry
+		logger.Warn("order email from unknown address", "from", from.Address)
+		fmt.Fprintf(w, "ignored: %s is not in -addresses\n", from.Address)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), identityKey, &Identity{Name: name, Email: from.Address}))
+
+	reply := notify.Message{Subject: email.Subject, To: []string{from.Address}}
+	if !strings.HasPrefix(strings.ToLower(reply.Subject), "re:") {
+		reply.Subject = "Re: " + reply.Subject
+	}
+	item, ok := parseOrderEmail(email.Subject, email.Text)
+	if !ok {
+		reply.Text = "Sorry, I couldn't find your order. Write it on a line like\n\norder: club sandwich\n\nor \"order: none\" to take it back.\n"
+	} else {
+		reply.Text, err = s.placeOrder(r, name, item, "email from "+from.Address)
+		var conflict *orderConflict
+		if errors.As(err, &conflict) {
+			reply.Text, err = conflict.notRecorded(item), nil
+		}
+		if err != nil {
+			// the mail service retries later
+			logger.Error("could not take order from email", "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	logger.Info("answered order email", "name", name, "order", item, "found", ok)
+	s.replyByEmail(r.Context(), reply)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, reply.Text)
+}
+
+// replyByEmail sends msg to its recipients through the email notifier, if
+// there is one; other notifiers would tell the whole office
+func (s *Server) replyByEmail(ctx context.Context, msg notify.Message) {
+	email := emailNotifier()
+	if email == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	if err := email.Notify(ctx, msg); err != nil {
+		slog.Error("could not send email reply", "to", msg.To, "err", err)
+	}
+}
@@ -0,0 +1,412 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// invoice is the restaurant's invoice of an archived day as entered by whoever
+// paid, with the result of reconciling it against the orders when it was
+// saved
+type invoice struct {
+	Total order.Money   `json:"total"`
+	Lines []invoiceLine `json:"lines,omitempty"`
+	// Computed is the total of the priced orders and Problems the number of
+	// items that didn't match, at the time
+	Computed  order.Money `json:"computed"`
+	Problems  int         `json:"problems"`
+	Unpriced  int         `json:"unpriced,omitempty"`
+	Entered   time.Time   `json:"entered"`
+	EnteredBy string      `json:"entered_by"`
+	// PaidBy is who paid the restaurant, by name in the sheet, for settling
+	// up; "" when unknown
+	PaidBy string `json:"paid_by,omitempty"`
+}
+
+// Difference returns how much more the invoice asks than the orders add up
+// to, negative when it's less
+func (inv *invoice) Difference() order.Money {
+	return inv.Total - inv.Computed
+}
+
+// Compared describes the invoice's total against the orders, e.g. "€2.50
+// more than the orders"
+func (inv *invoice) Compared() string {
+	switch diff := inv.Difference(); {
+	case diff > 0:
+		return diff.String() + " more than the orders"
+	case diff < 0:
+		return (-diff).String() + " less than the orders"
+	}
+	return "the same as the orders"
+}
+
+// Matches reports whether the invoice agrees with the orders
+func (inv *invoice) Matches() bool {
+	return inv.Difference() == 0 && inv.Problems == 0
+}
+
+// invoiceLine is a line of an invoice, like "2 × Margherita €19.00"
+type invoiceLine struct {
+	Item     string      `json:"item"`
+	Quantity int         `json:"quantity"`
+	Price    order.Money `json:"price"`
+	Priced   bool        `json:"priced"`
+}
+
+func (l invoiceLine) String() string {
+	text := l.Item
+	if l.Quantity != 1 {
+		text = fmt.Sprintf("%d × %s", l.Quantity, l.Item)
+	}
+	if l.Priced {
+		text += " " + l.Price.String()
+	}
+	return text
+}
+
+// quantityRe matches the quantity in front of an invoice line: "2 ", "2x ",
+// "2 × "
+var quantityRe = regexp.MustCompile(`^(\d+)\s*(?:[x×*]\s*)?\s(.+)$`)
+
+// parseInvoiceLines reads the lines of an invoice, one item per line with
+// its quantity in front and the line's total at the end
+func parseInvoiceLines(text string) ([]invoiceLine, error) {
+	var lines []invoiceLine
+	for i, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		line := invoiceLine{Quantity: 1}
+		if m := quantityRe.FindStringSubmatch(raw); m != nil {
+			line.Quantity, _ = strconv.Atoi(m[1])
+			raw = m[2]
+		}
+		if price, ok := order.ParsePrice(raw); ok {
+			line.Price, line.Priced = price, true
+			raw = order.WithoutPrice(raw)
+		} else if fields := strings.Fields(raw); len(fields) > 1 {
+			// a plain amount at the end, as invoices print them
+			if price, ok := order.ParseAmount(fields[len(fields)-1]); ok {
+				line.Price, line.Priced = price, true
+				raw = strings.Join(fields[:len(fields)-1], " ")
+			}
+		}
+		line.Item = strings.TrimSpace(raw)
+		if line.Item == "" || line.Quantity <= 0 {
+			return nil, fmt.Errorf("line %d: expected an item like \"2 × Margherita 19.00\", got %q", i+1, raw)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// itemCheck compares an item as ordered with the invoice
+type itemCheck struct {
+	Item     string
+	Ordered  int
+	Invoiced int
+	// OrderedPrice and InvoicedPrice are the totals of the item, as far as
+	// priced
+	OrderedPrice  order.Money
+	InvoicedPrice order.Money
+	Problem       string
+}
+
+// reconcile compares the orders of a day with the lines of inv and returns
+// the items, those with a problem first
+func reconcile(oo *order.Overview, inv *invoice) []*itemCheck {
+	var checks []*itemCheck
+	byKey := make(map[string]*itemCheck)
+	orderedPriced := make(map[string]bool)
+	add := func(item string, count int, price order.Money, priced bool) {
+		key := order.ItemKey(item)
+		c := byKey[key]
+		if c == nil {
+			c = &itemCheck{Item: item}
+			byKey[key] = c
+			checks = append(checks, c)
+		}
+		c.Ordered += count
+		if priced {
+			c.OrderedPrice += price
+			orderedPriced[key] = true
+		}
+	}
+	items := oo.LineItems()
+	for _, li := range items {
+		texts := []string{li.Order}
+		if len(li.Parts) > 0 {
+			texts = texts[:0]
+			for _, p := range li.Parts {
+				texts = append(texts, p.Order)
+			}
+		}
+		for _, text := range texts {
+			if _, _, shared := order.ParseShare(text); shared {
+				continue
+			}
+			price, priced := order.ParsePrice(text)
+			add(order.WithoutPrice(text), 1, price, priced)
+		}
+	}
+	for _, s := range order.SharedItems(items) {
+		add(s.Item, s.Wholes(), s.Price*order.Money(s.Wholes()), s.Priced)
+	}
+
+	invoicedPriced := make(map[*itemCheck]bool)
+	for _, line := range inv.Lines {
+		c := matchInvoiceLine(byKey, line.Item)
+		if c == nil {
+			c = &itemCheck{Item: line.Item}
+			byKey[order.ItemKey(line.Item)] = c
+			checks = append(checks, c)
+		}
+		c.Invoiced += line.Quantity
+		if line.Priced {
+			c.InvoicedPrice += line.Price
+			invoicedPriced[c] = true
+		}
+	}
+
+	for _, c := range checks {
+		switch {
+		case c.Invoiced == 0:
+			c.Problem = "not on the invoice"
+		case c.Ordered == 0:
+			c.Problem = "not ordered"
+		case c.Ordered != c.Invoiced:
+			c.Problem = fmt.Sprintf("%d ordered, %d invoiced", c.Ordered, c.Invoiced)
+		case orderedPriced[order.ItemKey(c.Item)] && invoicedPriced[c] && c.OrderedPrice != c.InvoicedPrice:
+			c.Problem = fmt.Sprintf("%s ordered, %s invoiced", c.OrderedPrice, c.InvoicedPrice)
+		}
+	}
+	sort.SliceStable(checks, func(i, j int) bool {
+		return checks[i].Problem != "" && checks[j].Problem == ""
+	})
+	return checks
+}
+
+// matchInvoiceLine finds the ordered item an invoice line is for: the same
+// item, or else one whose name contains the other's, like "Margherita" and
+// "Pizza Margherita"
+func matchInvoiceLine(byKey map[string]*itemCheck, item string) *itemCheck {
+	key := order.ItemKey(item)
+	if c := byKey[key]; c != nil {
+		return c
+	}
+	var found *itemCheck
+	for k, c := range byKey {
+		if c.Ordered > 0 && (strings.Contains(k, key) || strings.Contains(key, k)) {
+			if found != nil {
+				// ambiguous, better shown as a problem than matched wrong
+				return nil
+			}
+			found = c
+		}
+	}
+	return found
+}
+
+// invoiceSuffix ends the name of a day's invoice in the archive, after the
+// date: 2006-01-02.invoice.json next to 2006-01-02.json
+const invoiceSuffix = ".invoice.json"
+
+// SaveInvoice stores the invoice of a date (2006-01-02), replacing an
+// earlier one
+func (a *archiveStore) SaveInvoice(date string, inv *invoice) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b, err := json.MarshalIndent(inv, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(a.dir, date+invoiceSuffix), b)
+}
+
+// Invoice returns the invoice of a date, nil when none was entered
+func (a *archiveStore) Invoice(date string) (*invoice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b, err := os.ReadFile(filepath.Join(a.dir, date+invoiceSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var inv invoice
+	if err := json.Unmarshal(b, &inv); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return &inv, nil
+}
+
+// removeInvoice deletes the invoice of a date, if any; a.mu must be held
+func (a *archiveStore) removeInvoice(date string) error {
+	err := os.Remove(filepath.Join(a.dir, date+invoiceSuffix))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// handleInvoice reconciles the invoice of an archived day at
+// /invoices/2006-01-02: whoever paid enters its total, and its lines to
+// compare item by item, with a POST
+func (s *Server) handleInvoice(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	date := strings.TrimPrefix(r.URL.Path, "/invoices/")
+	if _, err := time.Parse(timeLayout, date); err != nil {
+		s.notFound(w, r)
+		return
+	}
+	snap, err := s.archive.Load(date)
+	if err != nil {
+		logger.Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+	if snap == nil {
+		s.notFound(w, r)
+		return
+	}
+	oo := snap.Overview()
+
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		if s.commentAuthor(r, snap.Names) == "" {
+			http.Error(w, "pick who you are, or log in, before entering an invoice", http.StatusForbidden)
+			return
+		}
+		inv, err := s.parseInvoice(r, oo)
+		if err == nil {
+			inv.PaidBy = r.FormValue("paid_by")
+			if inv.PaidBy != "" && !slices.Contains(oo.Names, inv.PaidBy) {
+				err = fmt.Errorf("%s isn't in the sheet on %s", inv.PaidBy, date)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.archive.SaveInvoice(date, inv); err != nil {
+			logger.Error("could not store invoice", "err", err)
+			http.Error(w, "could not store the invoice", http.StatusInternalServerError)
+			return
+		}
+		s.audit.Record(r, "invoice", fmt.Sprintf("%s: %s paid by %q, %d items differ", date, inv.Total, inv.PaidBy, inv.Problems))
+		http.Redirect(w, r, "/invoices/"+date, http.StatusSeeOther)
+		return
+	}
+
+	inv, err := s.archive.Invoice(date)
+	if err != nil {
+		logger.Error("could not read invoice", "err", err)
+		http.Error(w, "could not read invoice", http.StatusInternalServerError)
+		return
+	}
+	computed, unpriced := spend(oo)
+	var names []string
+	for _, name := range oo.Names {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	payer := s.claimedName(r, oo.Names)
+	if inv != nil && inv.PaidBy != "" {
+		payer = inv.PaidBy
+	}
+	data := map[string]interface{}{
+		"Date":     date,
+		"Vendor":   s.cfg.vendorName(snap.Time()),
+		"Computed": computed,
+		"Unpriced": unpriced,
+		"Invoice":  inv,
+		"Names":    names,
+		"Payer":    payer,
+	}
+	if inv != nil {
+		var lines []string
+		for _, line := range inv.Lines {
+			lines = append(lines, line.String())
+		}
+		data["Lines"] = strings.Join(lines, "\n")
+		if len(inv.Lines) > 0 {
+			data["Checks"] = reconcile(oo, inv)
+		}
+	}
+	render(w, r, "invoice", data)
+}
+
+// parseInvoice reads the invoice posted for the orders oo and reconciles it
+func (s *Server) parseInvoice(r *http.Request, oo *order.Overview) (*invoice, error) {
+	total, ok := order.ParseAmount(strings.TrimSpace(r.FormValue("total")))
+	if !ok || total < 0 {
+		return nil, errors.New("enter the invoice's total, like 84.50")
+	}
+	lines, err := parseInvoiceLines(r.FormValue("lines"))
+	if err != nil {
+		return nil, err
+	}
+	inv := &invoice{
+		Total:     total,
+		Lines:     lines,
+		Entered:   s.cfg.now(),
+		EnteredBy: identityFromRequest(r).String(),
+	}
+	inv.Computed, inv.Unpriced = spend(oo)
+	if len(lines) > 0 {
+		for _, c := range reconcile(oo, inv) {
+			if c.Problem != "" {
+				inv.Problems++
+			}
+		}
+	}
+	return inv, nil
+}
+
+// invoiceSummary adds up the invoices entered in a month
+type invoiceSummary struct {
+	Days       int
+	Total      order.Money
+	Difference order.Money
+	// Mismatched is how many of the invoices don't agree with the orders
+	Mismatched int
+}
+
+// sumInvoices adds up the invoices of days, nil when none was entered
+func sumInvoices(days []receiptDay) *invoiceSummary {
+	var sum invoiceSummary
+	for _, day := range days {
+		if day.Invoice == nil {
+			continue
+		}
+		sum.Days++
+		sum.Total += day.Invoice.Total
+		sum.Difference += day.Invoice.Difference()
+		if !day.Invoice.Matches() {
+			sum.Mismatched++
+		}
+	}
+	if sum.Days == 0 {
+		return nil
+	}
+	return &sum
+}
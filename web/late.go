@@ -0,0 +1,288 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// lateOrder is an order that came in after the deadline. It waits on the
+// late list, left out of the order email, until an admin who can still
+// reach the restaurant promotes it to a regular order.
+type lateOrder struct {
+	Order string    `json:"order"`
+	Via   string    `json:"via"`
+	Time  time.Time `json:"time"`
+}
+
+// lateStore keeps the late orders of each day by name in one JSON file per
+// day under <data-dir>/late
+type lateStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newLateStore(dataDir string) *lateStore {
+	return &lateStore{dir: filepath.Join(dataDir, "late")}
+}
+
+// Load returns the late orders on a date (2006-01-02) by name
+func (l *lateStore) Load(date string) (map[string]lateOrder, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load(date)
+}
+
+func (l *lateStore) load(date string) (map[string]lateOrder, error) {
+	day := make(map[string]lateOrder)
+	b, err := os.ReadFile(filepath.Join(l.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return day, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &day); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return day, nil
+}
+
+// Update changes the late orders on a date with change and stores them
+func (l *lateStore) Update(date string, change func(day map[string]lateOrder) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day, err := l.load(date)
+	if err != nil {
+		return err
+	}
+	if err := change(day); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(day, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(l.dir, date+".json"), b)
+}
+
+// Purge deletes the late orders of every day before cutoff (2006-01-02)
+func (l *lateStore) Purge(cutoff string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// placeLateOrder puts item on today's late list as the order of name, or
+// takes their late order back for "", and returns what to answer them
+func (s *Server) placeLateOrder(r *http.Request, name, item, via string, deadline time.Time) (string, error) {
+	now := s.cfg.now()
+	err := s.lateOrders.Update(now.Format(timeLayout), func(day map[string]lateOrder) error {
+		if item == "" {
+			delete(day, name)
+		} else {
+			day[name] = lateOrder{Order: item, Via: via, Time: now}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not store the late order: %v", err)
+	}
+	s.audit.Record(r, "late/"+strings.ToLower(strings.Fields(via)[0]), fmt.Sprintf("%s: %q", name, item))
+	requestLogger(r).Info("late order", "name", name, "order", item, "via", via)
+	if item == "" {
+		return fmt.Sprintf("Got it %s, you have no late order today.\n", name), nil
+	}
+	return fmt.Sprintf("Sorry %s, orders closed at %s today. I put %q on the late list: if the restaurant still takes it, it gets added and you'll find it on the page.\n",
+		name, deadline.Format("15:04"), item), nil
+}
+
+// lateItem is a late order as shown on the page
+type lateItem struct {
+	Name string
+	lateOrder
+}
+
+// lateView is today's late list on the page
+type lateView struct {
+	Items []lateItem
+	// Me can add a late order, once orders closed
+	Me string
+	// Admin can promote late orders
+	Admin bool
+}
+
+// lateOn returns the late list of a date for the page, nil when orders are
+// still open and nobody ordered late
+func (s *Server) lateOn(r *http.Request, date string, closed bool, names []string) (*lateView, error) {
+	day, err := s.lateOrders.Load(date)
+	if err != nil {
+		return nil, err
+	}
+	if !closed && len(day) == 0 {
+		return nil, nil
+	}
+	view := &lateView{Admin: s.isAdmin(r)}
+	if closed {
+		view.Me = s.claimedName(r, names)
+	}
+	late := slices.Collect(maps.Keys(day))
+	sort.Slice(late, func(i, j int) bool { return order.LessName(late[i], late[j]) })
+	for _, name := range late {
+		view.Items = append(view.Items, lateItem{name, day[name]})
+	}
+	return view, nil
+}
+
+// handleLate puts the order of the visitor on today's late list
+func (s *Server) handleLate(w http.ResponseWriter, r *http.Request) {
+	if inMaintenance() {
+		http.Error(w, errOrdersFrozen.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	now := s.cfg.now()
+	deadline, ok := s.cfg.deadlineOn(now)
+	if !ok || now.Before(deadline) {
+		http.Error(w, "orders are still open, write yours in the sheet", http.StatusBadRequest)
+		return
+	}
+	oo, err := s.todaysOrders(r.Context(), requestLogger(r))
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	name := s.claimedName(r, oo.Names)
+	if name == "" {
+		http.Error(w, "pick who you are before ordering late", http.StatusForbidden)
+		return
+	}
+	item := strings.TrimSpace(r.FormValue("order"))
+	if utf8.RuneCountInString(item) > maxCommentLength {
+		http.Error(w, fmt.Sprintf("orders have up to %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.placeLateOrder(r, name, item, "the page", deadline); err != nil {
+		requestLogger(r).Error("could not store late order", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lateChanged(w, r)
+}
+
+// handlePromote makes a late order a regular one, as when the restaurant
+// still took it, or drops it
+func (s *Server) handlePromote(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	date := now.Format(timeLayout)
+	name := r.FormValue("name")
+	drop := r.FormValue("do") == "drop"
+
+	var late lateOrder
+	err := s.lateOrders.Update(date, func(day map[string]lateOrder) error {
+		var ok bool
+		if late, ok = day[name]; !ok {
+			return fmt.Errorf("%s has no late order today", name)
+		}
+		delete(day, name)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if drop {
+		s.audit.Record(r, "late/drop", fmt.Sprintf("%s: %q", name, late.Order))
+		lateChanged(w, r)
+		return
+	}
+
+	err = s.promote(r, name, late)
+	if err != nil {
+		logger.Error("could not promote late order", "name", name, "err", err)
+		// back on the list, so it isn't lost
+		if err := s.lateOrders.Update(date, func(day map[string]lateOrder) error {
+			day[name] = late
+			return nil
+		}); err != nil {
+			logger.Error("could not restore late order", "name", name, "err", err)
+		}
+		http.Error(w, "could not promote the late order: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r, "late/promote", fmt.Sprintf("%s: %q", name, late.Order))
+	lateChanged(w, r)
+}
+
+// promote records late as today's order of name, like one placed in time
+func (s *Server) promote(r *http.Request, name string, late lateOrder) error {
+	now := s.cfg.now()
+	names, cells, err := s.sheetRow(r.Context(), requestLogger(r), now)
+	if err != nil {
+		return err
+	}
+	date := now.Format(timeLayout)
+	day, err := s.entries.Load(date)
+	if err != nil {
+		return err
+	}
+	prev, placed := day[name]
+	var last *orderEntry
+	if placed {
+		last = &prev
+	}
+	entry := orderEntry{
+		Order: late.Order,
+		Was:   order.New(names, cells).OrderOf(name),
+		Via:   fmt.Sprintf("late %s, added by %s", late.Via, identityFromRequest(r).String()),
+		Time:  now,
+	}
+	return s.entries.SetIf(date, name, last, entry)
+}
+
+func lateChanged(w http.ResponseWriter, r *http.Request) {
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "late")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// leaderboardEntry is one person's participation
+type leaderboardEntry struct {
+	Name string
+	// Orders counts the days this person ordered
+	Orders int
+	// Days counts the days this person was on the sheet
+	Days int
+	// Streak is the number of consecutive days, up to the latest archived
+	// day, this person ordered
+	Streak int
+}
+
+// Rate returns the percentage of days this person ordered
+func (e leaderboardEntry) Rate() float64 {
+	if e.Days == 0 {
+		return 0
+	}
+	return 100 * float64(e.Orders) / float64(e.Days)
+}
+
+// computeLeaderboard ranks everyone on the sheet by how often they ordered,
+// counting "joe" and "Joe " as Joe, under the name of their latest day.
+// snapshots must be sorted by date.
+func computeLeaderboard(snapshots []*Snapshot) []leaderboardEntry {
+	entries := make(map[string]*leaderboardEntry)
+	// streakOpen tracks who hasn't missed a day yet, walking back in time
+	streakOpen := make(map[string]bool)
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		oo := snapshots[i].Overview()
+		ordered := make(map[string]bool)
+		for _, li := range oo.LineItems() {
+			ordered[order.NameKey(li.Name)] = true
+		}
+
+		counted := make(map[string]bool)
+		for _, name := range oo.Names {
+			key := order.NameKey(name)
+			if key == "" || counted[key] {
+				continue
+			}
+			counted[key] = true
+			e, ok := entries[key]
+			if !ok {
+				e = &leaderboardEntry{Name: order.NormalizeName(name)}
+				entries[key] = e
+				streakOpen[key] = i == len(snapshots)-1
+			}
+			e.Days++
+			if ordered[key] {
+				e.Orders++
+				if streakOpen[key] {
+					e.Streak++
+				}
+			} else {
+				streakOpen[key] = false
+			}
+		}
+	}
+
+	board := make([]leaderboardEntry, 0, len(entries))
+	for _, e := range entries {
+		board = append(board, *e)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		a, b := board[i], board[j]
+		if a.Orders != b.Orders {
+			return a.Orders > b.Orders
+		}
+		return order.LessName(a.Name, b.Name)
+	})
+	return board
+}
+
+// topStreaks returns the n longest running streaks
+func topStreaks(board []leaderboardEntry, n int) []leaderboardEntry {
+	var streaks []leaderboardEntry
+	for _, e := range board {
+		if e.Streak > 1 {
+			streaks = append(streaks, e)
+		}
+	}
+	sort.SliceStable(streaks, func(i, j int) bool { return streaks[i].Streak > streaks[j].Streak })
+	if len(streaks) > n {
+		streaks = streaks[:n]
+	}
+	return streaks
+}
+
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	from, to, err := s.parseDateRange(r, 90)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snapshots, err := s.archive.Range(from, to)
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+
+	board := computeLeaderboard(snapshots)
+	render(w, r, "leaderboard", map[string]interface{}{
+		"From":    from,
+		"To":      to,
+		"Board":   board,
+		"Streaks": topStreaks(board, 5),
+	})
+}
+
+// indexLeaderboard returns the top of the last 30 days' leaderboard for the
+// index page, or nil when -leaderboard is off
+func (s *Server) indexLeaderboard(r *http.Request) []leaderboardEntry {
+	if !s.cfg.leaderboard {
+		return nil
+	}
+	snapshots, err := s.archive.Range(s.cfg.now().AddDate(0, 0, -29).Format(timeLayout), "")
+	if err != nil {
+		requestLogger(r).Warn("could not read archive for leaderboard", "err", err)
+		return nil
+	}
+	board := computeLeaderboard(snapshots)
+	if len(board) > 3 {
+		board = board[:3]
+	}
+	return board
+}
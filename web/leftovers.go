@@ -0,0 +1,294 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// leftoverTTL is how long leftovers stay on the page
+const leftoverTTL = time.Hour
+
+// maxLeftovers bounds how many of a leftover can be up for grabs
+const maxLeftovers = 20
+
+// leftover is extra food up for grabs after delivery, like "2 extra fries"
+type leftover struct {
+	ID    int       `json:"id"`
+	What  string    `json:"what"`
+	Count int       `json:"count"`
+	By    string    `json:"by"`
+	Time  time.Time `json:"time"`
+	// Claims are the names of who took one, in order
+	Claims []string `json:"claims,omitempty"`
+}
+
+// Left returns how many are still up for grabs
+func (l *leftover) Left() int {
+	return l.Count - len(l.Claims)
+}
+
+// ClaimedBy reports whether name took one
+func (l *leftover) ClaimedBy(name string) bool {
+	return slices.Contains(l.Claims, name)
+}
+
+// leftoverStore keeps the leftovers of each day in one JSON file per day
+// under <data-dir>/leftovers
+type leftoverStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newLeftoverStore(dataDir string) *leftoverStore {
+	return &leftoverStore{dir: filepath.Join(dataDir, "leftovers")}
+}
+
+// Load returns the leftovers posted on a date (2006-01-02)
+func (l *leftoverStore) Load(date string) ([]leftover, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load(date)
+}
+
+func (l *leftoverStore) load(date string) ([]leftover, error) {
+	var day []leftover
+	b, err := os.ReadFile(filepath.Join(l.dir, date+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &day); err != nil {
+		return nil, fmt.Errorf("%s: %v", date, err)
+	}
+	return day, nil
+}
+
+// Update changes the leftovers of a date with change and stores them
+func (l *leftoverStore) Update(date string, change func(day []leftover) ([]leftover, error)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day, err := l.load(date)
+	if err != nil {
+		return err
+	}
+	if day, err = change(day); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(day, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(l.dir, date+".json"), b)
+}
+
+// Purge deletes the leftovers of every day before cutoff (2006-01-02)
+func (l *leftoverStore) Purge(cutoff string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		date, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// parseLeftover reads what is up for grabs, with how many in front as in
+// "2 extra fries"
+func parseLeftover(text string) (what string, count int, err error) {
+	what, count = strings.TrimSpace(text), 1
+	if m := quantityRe.FindStringSubmatch(what); m != nil {
+		count, _ = strconv.Atoi(m[1])
+		what = strings.TrimSpace(m[2])
+	}
+	switch {
+	case what == "":
+		return "", 0, errors.New("say what is up for grabs, like \"2 extra fries\"")
+	case utf8.RuneCountInString(what) > maxCommentLength:
+		return "", 0, fmt.Errorf("leftovers are described in up to %d characters", maxCommentLength)
+	case count < 1 || count > maxLeftovers:
+		return "", 0, fmt.Errorf("up to %d at once", maxLeftovers)
+	}
+	return what, count, nil
+}
+
+// leftoverView is the leftovers of the last hour on the page
+type leftoverView struct {
+	Items []leftover
+	// Me claims them; Poster can post leftovers
+	Me     string
+	Poster bool
+}
+
+// canPostLeftovers reports whether the visitor, me by name, paid for today's
+// lunch: -payee when it's set, or else anyone who picked their name. Admins
+// can too.
+func (s *Server) canPostLeftovers(r *http.Request, me string) bool {
+	if s.isAdmin(r) {
+		return true
+	}
+	return me != "" && (s.cfg.payee == "" || me == s.cfg.payee)
+}
+
+// leftoversOn returns the leftovers posted on the day of t in the last hour
+// for the page, nil when there are none and the visitor can't post any
+func (s *Server) leftoversOn(r *http.Request, t time.Time, names []string) (*leftoverView, error) {
+	day, err := s.leftovers.Load(t.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	me := s.claimedName(r, names)
+	view := &leftoverView{Me: me, Poster: s.canPostLeftovers(r, me)}
+	for _, l := range day {
+		if t.Sub(l.Time) < leftoverTTL {
+			view.Items = append(view.Items, l)
+		}
+	}
+	if len(view.Items) == 0 && !view.Poster {
+		return nil, nil
+	}
+	return view, nil
+}
+
+// handleLeftovers posts, claims and takes back today's leftovers
+func (s *Server) handleLeftovers(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	oo, err := s.todaysOrders(r.Context(), requestLogger(r))
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	me := s.claimedName(r, oo.Names)
+	now := s.cfg.now()
+	date := now.Format(timeLayout)
+	id, _ := strconv.Atoi(r.FormValue("id"))
+
+	var change func(day []leftover) ([]leftover, error)
+	var detail string
+	switch do := r.FormValue("do"); do {
+	case "post":
+		if !s.canPostLeftovers(r, me) {
+			http.Error(w, "only whoever paid posts leftovers", http.StatusForbidden)
+			return
+		}
+		what, count, err := parseLeftover(r.FormValue("what"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		by := me
+		if by == "" {
+			by = identityFromRequest(r).String()
+		}
+		detail = fmt.Sprintf("%d × %s", count, what)
+		change = func(day []leftover) ([]leftover, error) {
+			next := 1
+			for _, l := range day {
+				next = max(next, l.ID+1)
+			}
+			return append(day, leftover{ID: next, What: what, Count: count, By: by, Time: now}), nil
+		}
+	case "claim", "unclaim":
+		if me == "" {
+			http.Error(w, "pick who you are before claiming leftovers", http.StatusForbidden)
+			return
+		}
+		change = func(day []leftover) ([]leftover, error) {
+			i := slices.IndexFunc(day, func(l leftover) bool { return l.ID == id })
+			if i < 0 || now.Sub(day[i].Time) >= leftoverTTL {
+				return nil, errLeftoverGone
+			}
+			l := &day[i]
+			detail = fmt.Sprintf("%s: %s", me, l.What)
+			if do == "unclaim" {
+				j := slices.Index(l.Claims, me)
+				if j < 0 {
+					return nil, errLeftoverUnclaimed
+				}
+				l.Claims = slices.Delete(l.Claims, j, j+1)
+				return day, nil
+			}
+			if l.Left() <= 0 {
+				return nil, errLeftoverGone
+			}
+			l.Claims = append(l.Claims, me)
+			return day, nil
+		}
+	case "remove":
+		change = func(day []leftover) ([]leftover, error) {
+			i := slices.IndexFunc(day, func(l leftover) bool { return l.ID == id })
+			if i < 0 {
+				return nil, errLeftoverGone
+			}
+			if !s.isAdmin(r) && day[i].By != me {
+				return nil, errLeftoverNotYours
+			}
+			detail = day[i].What
+			return slices.Delete(day, i, i+1), nil
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q", do), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.leftovers.Update(date, change); err != nil {
+		switch err {
+		case errLeftoverGone:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errLeftoverNotYours:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errLeftoverUnclaimed:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			requestLogger(r).Error("could not store leftovers", "err", err)
+			http.Error(w, "could not store leftovers", http.StatusInternalServerError)
+		}
+		return
+	}
+	s.audit.Record(r, "leftover/"+r.FormValue("do"), detail)
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "leftover")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+var (
+	errLeftoverGone      = errors.New("that's gone already, reload the page")
+	errLeftoverNotYours  = errors.New("only whoever posted leftovers takes them back")
+	errLeftoverUnclaimed = errors.New("you didn't claim that")
+)
@@ -0,0 +1,320 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shortlink points a short /s/<token> URL, for SMS and posters, at the
+// sheet or a page of lunchweb
+type shortlink struct {
+	Token string `json:"token"`
+	// Target is the sheet's URL or a path of lunchweb, like
+	// /history?from=2026-10-16&to=2026-10-16
+	Target    string    `json:"target"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"created_by"`
+	// Expires is the last day (2006-01-02) the link works, "" for never
+	Expires string `json:"expires,omitempty"`
+	Hits    int    `json:"hits"`
+}
+
+// Expired reports whether the link no longer works at t
+func (l *shortlink) Expired(t time.Time) bool {
+	return l.Expires != "" && t.Format(timeLayout) > l.Expires
+}
+
+// linkStore keeps the shortlinks in <data-dir>/links.json
+type linkStore struct {
+	mu   sync.Mutex
+	path string
+	// hits are counted here by token, rather than writing the file on every
+	// hit, until Flush adds them to the links
+	hits map[string]int
+}
+
+// linkFlushInterval is how often the hits of the links are written down
+const linkFlushInterval = time.Minute
+
+func newLinkStore(dataDir string) *linkStore {
+	return &linkStore{path: filepath.Join(dataDir, "links.json"), hits: make(map[string]int)}
+}
+
+func (l *linkStore) load() (map[string]*shortlink, error) {
+	all := make(map[string]*shortlink)
+	b, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", l.path, err)
+	}
+	return all, nil
+}
+
+// save writes all, with the hits counted since the last time; l.mu must be
+// held
+func (l *linkStore) save(all map[string]*shortlink) error {
+	for token, n := range l.hits {
+		if link := all[token]; link != nil {
+			link.Hits += n
+		}
+	}
+	clear(l.hits)
+	b, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(l.path, b)
+}
+
+// List returns every link, the newest first
+func (l *linkStore) List() ([]*shortlink, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*shortlink, 0, len(all))
+	for token, link := range all {
+		link.Hits += l.hits[token]
+		list = append(list, link)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Add stores link under a new random token, which it sets
+func (l *linkStore) Add(link *shortlink) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return err
+	}
+	for link.Token == "" || all[link.Token] != nil {
+		if link.Token, err = linkToken(); err != nil {
+			return err
+		}
+	}
+	all[link.Token] = link
+	return l.save(all)
+}
+
+// Follow counts a hit of the link with token and returns it, nil for an
+// unknown token. The hit is only written down by the next Flush.
+func (l *linkStore) Follow(token string) (*shortlink, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	link := all[token]
+	if link == nil {
+		return nil, nil
+	}
+	l.hits[token]++
+	link.Hits += l.hits[token]
+	return link, nil
+}
+
+// Flush writes down the hits counted since the last time
+func (l *linkStore) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.hits) == 0 {
+		return nil
+	}
+	all, err := l.load()
+	if err != nil {
+		return err
+	}
+	return l.save(all)
+}
+
+// run flushes the hits every interval until ctx is done, and then once more
+func (l *linkStore) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := l.Flush(); err != nil {
+				slog.Error("could not store shortlink hits", "err", err)
+			}
+			return
+		case <-ticker.C:
+			if err := l.Flush(); err != nil {
+				slog.Error("could not store shortlink hits", "err", err)
+			}
+		}
+	}
+}
+
+// Remove deletes the link with token
+func (l *linkStore) Remove(token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return err
+	}
+	if all[token] == nil {
+		return fmt.Errorf("no link %q", token)
+	}
+	delete(all, token)
+	return l.save(all)
+}
+
+// linkAlphabet leaves out characters that are easily confused when typed
+// from a poster, like 0 and O or 1 and l
+const linkAlphabet = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+
+func linkToken() (string, error) {
+	var b strings.Builder
+	for i := 0; i < 6; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(linkAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(linkAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// handleShortlink redirects /s/<token> to the link's target. It's served
+// without login, like the sheet link on a poster; pages of lunchweb ask
+// for it after the redirect.
+func (s *Server) handleShortlink(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	link, err := s.links.Follow(token)
+	if err != nil {
+		requestLogger(r).Error("could not read shortlinks", "err", err)
+		http.Error(w, "could not read the link", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		s.notFound(w, r)
+		return
+	}
+	if link.Expired(s.cfg.now()) {
+		http.Error(w, "This link expired on "+link.Expires+".", http.StatusGone)
+		return
+	}
+	// a target may change, so browsers mustn't remember the redirect
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, link.Target, http.StatusFound)
+}
+
+// linkTarget returns where a link created on the admin page points: the
+// sheet, today's orders or the orders on a date
+func (s *Server) linkTarget(r *http.Request) (string, error) {
+	switch r.FormValue("target") {
+	case "sheet":
+		return s.cfg.sheetURL, nil
+	case "page":
+		date := r.FormValue("date")
+		if date == "" {
+			return "/", nil
+		}
+		if _, err := time.Parse(timeLayout, date); err != nil {
+			return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+		return "/history?" + url.Values{"from": {date}, "to": {date}}.Encode(), nil
+	case "path":
+		path := strings.TrimSpace(r.FormValue("path"))
+		// only lunchweb's own pages, or the link could lead anywhere: browsers
+		// read /\evil.com like //evil.com, another host
+		u, err := url.Parse(path)
+		if err != nil || u.Scheme != "" || u.Host != "" || strings.ContainsRune(path, '\\') ||
+			!strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/s/") {
+			return "", fmt.Errorf("invalid path %q, expected a page of lunchweb like /stats", path)
+		}
+		return path, nil
+	}
+	return "", errors.New("pick what the link points to")
+}
+
+// handleLinks lists the shortlinks on /admin/links, and creates and
+// removes them
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		var msg string
+		if token := r.FormValue("remove"); token != "" {
+			msg = "Removed /s/" + token
+			if err := s.links.Remove(token); err != nil {
+				msg = "Failed: " + err.Error()
+			}
+		} else {
+			msg = s.addLink(r)
+		}
+		s.audit.Record(r, "admin/links", msg)
+		http.Redirect(w, r, "/admin/links?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	list, err := s.links.List()
+	if err != nil {
+		logger.Error("could not read shortlinks", "err", err)
+		http.Error(w, "could not read shortlinks", http.StatusInternalServerError)
+		return
+	}
+	render(w, r, "links", map[string]interface{}{
+		"Message": r.URL.Query().Get("msg"),
+		"Links":   list,
+		"Base":    strings.TrimSuffix(s.appURL(r), "/") + "/s/",
+		"Now":     s.cfg.now(),
+		"Today":   s.cfg.now().Format(timeLayout),
+	})
+}
+
+// addLink creates the link posted on the admin page and returns the message
+// for the admin
+func (s *Server) addLink(r *http.Request) string {
+	target, err := s.linkTarget(r)
+	if err != nil {
+		return "Failed: " + err.Error()
+	}
+	link := &shortlink{
+		Target:    target,
+		Created:   s.cfg.now(),
+		CreatedBy: identityFromRequest(r).String(),
+		Expires:   r.FormValue("expires"),
+	}
+	if link.Expires != "" {
+		if _, err := time.Parse(timeLayout, link.Expires); err != nil {
+			return fmt.Sprintf("Failed: invalid expiry %q, expected YYYY-MM-DD", link.Expires)
+		}
+	}
+	if err := s.links.Add(link); err != nil {
+		requestLogger(r).Error("could not store shortlink", "err", err)
+		return "Failed: " + err.Error()
+	}
+	return fmt.Sprintf("Created %s/s/%s for %s", strings.TrimSuffix(s.appURL(r), "/"), link.Token, target)
+}
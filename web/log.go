@@ -0,0 +1,36 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// setupLogger installs the default slog logger according to -log-level and
+// -log-format
+func setupLogger() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*flagLogLevel)); err != nil {
+		return fmt.Errorf("unknown log level %q", *flagLogLevel)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch *flagLogFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", *flagLogFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs err at error level and exits the process
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
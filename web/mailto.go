@@ -0,0 +1,73 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mailSubjectOn is the subject for the order email of the day of t
+func (c *config) mailSubjectOn(t time.Time) string {
+	return fmt.Sprintf("%s (%s)", c.subject, t.Format(timeLayout))
+}
+
+// mailtoEscape encodes s for a mailto: URL. Spaces must be %20 there, mail
+// clients take "+" literally.
+func mailtoEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// recipients are the addresses an email goes to
+type recipients struct {
+	To  []string
+	Cc  []string
+	Bcc []string
+}
+
+// orderRecipients returns who gets the order email on day: the day's vendor
+// if it has an email address, otherwise -email, with -email-cc and
+// -email-bcc copied
+func (c *config) orderRecipients(day time.Time) recipients {
+	to := c.email
+	if v := c.vendorFor(day); v != nil && v.Email != "" {
+		to = splitAddresses([]string{v.Email})
+	}
+	return recipients{
+		To:  to,
+		Cc:  c.emailCC,
+		Bcc: c.emailBCC,
+	}
+}
+
+// splitAddresses accepts both repeated flags and comma separated lists
+func splitAddresses(list []string) []string {
+	var addrs []string
+	for _, l := range list {
+		for _, a := range strings.Split(l, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	return addrs
+}
+
+// mailtoURL returns a mailto: link with the recipients, subject and body
+// filled in. Line breaks in the body are encoded as CRLF as RFC 6068 asks.
+func mailtoURL(rcpt recipients, subject, body string) template.URL {
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	var to []string
+	for _, a := range rcpt.To {
+		to = append(to, url.PathEscape(a))
+	}
+	u := "mailto:" + strings.Join(to, ",") + "?subject=" + mailtoEscape(subject)
+	if len(rcpt.Cc) > 0 {
+		u += "&cc=" + mailtoEscape(strings.Join(rcpt.Cc, ","))
+	}
+	if len(rcpt.Bcc) > 0 {
+		u += "&bcc=" + mailtoEscape(strings.Join(rcpt.Bcc, ","))
+	}
+	return template.URL(u + "&body=" + mailtoEscape(body))
+}
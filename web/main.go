@@ -0,0 +1,534 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var defaultCSVURL = "https://docs.google.com/spreadsheets/d/e/2PACX-1vTE16CfbUQiYoq6lrYJ27UENAYJWQ2lPtkE4eHUMMGKHnfdZ5d-BwR0gD1eom3IwPuEtVOgG73Y-QKR/pub?gid=0&single=true&output=csv"
+
+var timeLayout = sheet.DateLayout
+
+var flagPort = flag.Int("port", 8081, "port to host on")
+var flagCSVURL = flag.String("csvurl", defaultCSVURL, "public URL of the google sheets CSV")
+var flagHeader = flag.Int("header", 3, "index of the header row with the column names")
+var flagTimezone = flag.String("tz", "Europe/Brussels", "timezone to use")
+var flagSubject = flag.String("subject", "Order", "the email subject")
+var flagSheetURL = flag.String("sheet-url", "https://example.com", "spreadsheet url")
+var flagLogLevel = flag.String("log-level", "info", "minimum log level (debug, info, warn, error)")
+var flagLogFormat = flag.String("log-format", "text", "log output format (text or json)")
+var flagCSP = flag.String("csp", defaultCSP, "Content-Security-Policy header value (empty to disable)")
+var flagFrameOptions = flag.String("frame-options", "DENY", "X-Frame-Options header value (empty to disable)")
+var flagReferrerPolicy = flag.String("referrer-policy", "same-origin", "Referrer-Policy header value (empty to disable)")
+var flagAuthUser = flag.String("auth-user", "", "require basic auth with this user name")
+var flagAuthPassword = flag.String("auth-password", "", "password for -auth-user")
+var flagHtpasswd = flag.String("htpasswd", "", "require basic auth against this htpasswd file (bcrypt or SHA1 hashes)")
+var flagOIDCIssuer = flag.String("oidc-issuer", "", "require login through this OpenID Connect issuer (e.g. https://accounts.google.com)")
+var flagOIDCClientID = flag.String("oidc-client-id", "", "OpenID Connect client id")
+var flagOIDCClientSecret = flag.String("oidc-client-secret", "", "OpenID Connect client secret")
+var flagOIDCRedirectURL = flag.String("oidc-redirect-url", "", "OpenID Connect redirect url, e.g. https://lunch.example.org/auth/callback")
+var flagOIDCAllowedDomains = flag.String("oidc-allowed-domains", "", "comma separated email domains allowed to log in (default: any)")
+var flagSessionKey = flag.String("session-key", "", "secret used to sign session cookies (default: random per process)")
+var flagTrustProxy = flag.Bool("trust-proxy", false, "take the client address from X-Forwarded-For and the scheme from X-Forwarded-Proto (enable behind a reverse proxy)")
+var flagMaintenance = flag.Bool("maintenance", false, "start in maintenance mode (write-back and notifications frozen)")
+var flagMaintenanceMessage = flag.String("maintenance-message", "The order sheet is being reorganized, orders shown here may be incomplete.", "banner shown in maintenance mode")
+var flagDataDir = flag.String("data-dir", "data", "directory for locally stored data such as the audit log and order archive")
+var flagDebugAddr = flag.String("debug-addr", "", "serve pprof and expvar without authentication on this loopback address, e.g. localhost:6060; on the main port /debug/ is for admins only")
+var flagSentryDSN = flag.String("sentry-dsn", "", "report errors to this Sentry DSN")
+var flagErrorWebhook = flag.String("error-webhook", "", "POST errors as JSON to this URL")
+var flagErrorThreshold = flag.Int("error-threshold", 3, "report sheet fetching after this many consecutive failures")
+var flagReadTimeout = flag.Duration("read-timeout", 10*time.Second, "maximum duration for reading a request")
+var flagWriteTimeout = flag.Duration("write-timeout", 30*time.Second, "maximum duration for writing a response")
+var flagIdleTimeout = flag.Duration("idle-timeout", 2*time.Minute, "how long to keep idle keep-alive connections open")
+var flagTLSCert = flag.String("tls-cert", "", "certificate file to serve HTTPS and HTTP/2 with, requires -tls-key")
+var flagTLSKey = flag.String("tls-key", "", "private key file for -tls-cert")
+var flagH2C = flag.Bool("h2c", false, "also accept HTTP/2 without TLS, for proxies that speak it to the backend")
+var flagHandlerTimeout = flag.Duration("handler-timeout", 20*time.Second, "answer 504 when handling a request takes longer, except on /debug/, admin actions, receipt uploads and announcements (0 to disable)")
+var flagFetchTimeout = flag.Duration("fetch-timeout", 15*time.Second, "maximum duration for fetching the sheet and for requests to the OpenID Connect provider")
+var flagArchiveInterval = flag.Duration("archive-interval", 10*time.Minute, "how often to snapshot the orders into the archive (0 to disable)")
+var flagLeaderboard = flag.Bool("leaderboard", false, "show the top of the participation leaderboard on the index page")
+var flagVendors = flag.String("vendors", "", "JSON file listing the vendors and the weekdays we order from them")
+var flagSMTPAddr = flag.String("smtp-addr", "", "send email notifications through this SMTP server (host:port)")
+var flagSMTPUser = flag.String("smtp-user", "", "SMTP user name")
+var flagSMTPPassword = flag.String("smtp-password", "", "SMTP password")
+var flagSMTPFrom = flag.String("smtp-from", "", "sender address of email notifications")
+var flagSlackWebhook = flag.String("slack-webhook", "", "post notifications to this Slack incoming webhook URL")
+var flagNotifyWebhook = flag.String("notify-webhook", "", "POST notifications as JSON to this URL")
+var flagDigestDay = flag.String("digest-day", "friday", "weekday to send the weekly digest on")
+var flagCheckInterval = flag.Duration("check-interval", 5*time.Minute, "how often to check the sheet for structural problems (0 to disable)")
+var flagCacheTTL = flag.Duration("cache-ttl", 30*time.Second, "how long to reuse a fetched copy of the sheet")
+var flagAllowCIDR stringList
+var flagAdmins stringList
+var flagArchiveFinal timeOfDay
+var flagNotifyEmail stringList
+var flagEmail stringList
+var flagEmailCC stringList
+var flagEmailBCC stringList
+var flagDigestTo stringList
+var flagDigestTime timeOfDay
+var flagRetain retention
+var flagTodayRowBy = timeOfDay(10 * time.Hour)
+
+func init() {
+	flag.Var(&flagAllowCIDR, "allow-cidr", "only allow clients from this address range, e.g. 10.0.0.0/8 (repeatable)")
+	flag.Var(&flagAdmins, "admin", "login or verified email allowed to use /admin (repeatable, requires authentication)")
+	flag.Var(&flagNotifyEmail, "notify-email", "recipient of email notifications (repeatable)")
+	flag.Var(&flagEmail, "email", "recipient of the order email (repeatable, a vendor's email takes precedence)")
+	flag.Var(&flagEmailCC, "email-cc", "CC recipient of the order email (repeatable)")
+	flag.Var(&flagEmailBCC, "email-bcc", "BCC recipient of the order email (repeatable)")
+	flag.Var(&flagDigestTo, "digest-to", "recipient of the weekly digest email (repeatable, default: -notify-email)")
+	flag.Var(&flagDigestTime, "digest-time", "time of day to send the weekly digest, e.g. 15:00 (default: no digest)")
+	flag.Var(&flagRetain, "retain", "delete archived personal data older than this, e.g. 90d, 8w, 13m or 2y (default: keep forever)")
+	flag.Var(&flagTodayRowBy, "today-row-by", "alert when today's row is missing at this time of day")
+	flag.Var(&flagArchiveFinal, "archive-final", "time of day after which today's archived orders are final, e.g. 14:00 (default: end of day)")
+}
+
+// setup prepares everything the commands share: logging, the configuration,
+// local stores, notifiers and the server reading the sheet
+func setup() (*Server, error) {
+	if err := setupLogger(); err != nil {
+		return nil, fmt.Errorf("invalid logging configuration: %v", err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := setupClock(cfg.location); err != nil {
+		return nil, err
+	}
+	if err := setupCollation(); err != nil {
+		return nil, err
+	}
+	if err := setupCurrency(); err != nil {
+		return nil, err
+	}
+	if err := setupDev(); err != nil {
+		return nil, err
+	}
+	if err := setupAssets(); err != nil {
+		return nil, fmt.Errorf("could not read assets: %v", err)
+	}
+	if *flagFakeNow != "" {
+		slog.Warn("using a fake clock", "now", cfg.now())
+	}
+
+	if err := setupReporters(); err != nil {
+		return nil, fmt.Errorf("invalid error reporting configuration: %v", err)
+	}
+
+	s := newServer(cfg)
+	if s.slackClient, err = newSlackClient(); err != nil {
+		return nil, err
+	}
+	if s.teamsClient, s.teamsVerifier, err = newTeamsClient(); err != nil {
+		return nil, err
+	}
+	if err := setupNotifiers(); err != nil {
+		return nil, fmt.Errorf("invalid notification configuration: %v", err)
+	}
+	if s.ocrReader, err = newOCRReader(); err != nil {
+		return nil, err
+	}
+	if s.publisher, err = newPublisher(); err != nil {
+		return nil, err
+	}
+	if s.mqttClient, err = newMQTTClient(); err != nil {
+		return nil, err
+	}
+	if err := setupClosures(); err != nil {
+		return nil, err
+	}
+	if err := setupDirectory(); err != nil {
+		return nil, err
+	}
+
+	if len(flagClosures) > 0 {
+		// without them orders are taken as usual, until the next try
+		if err := s.loadClosures(context.Background()); err != nil {
+			slog.Error("could not load office closures", "err", err)
+		}
+	}
+	if peopleDirectory != nil {
+		// until they are fetched, the sheet header has the people
+		if err := s.loadPeople(context.Background()); err != nil {
+			slog.Error("could not load people", "err", err)
+		}
+	}
+	if *flagDemo {
+		if err := s.setupDemo(); err != nil {
+			return nil, fmt.Errorf("could not set up demo mode: %v", err)
+		}
+		slog.Warn("demo mode, serving sample orders", "data_dir", *flagDataDir)
+	}
+	setMaintenance(*flagMaintenance)
+	s.audit = newAuditLog(*flagDataDir, cfg.location)
+	s.archive = newArchiveStore(*flagDataDir)
+	s.paymentLinks = newPaymentLinkStore(*flagDataDir)
+	s.comments = newCommentStore(*flagDataDir)
+	s.menus = newMenuStore(*flagDataDir)
+	s.entries = newEntryStore(*flagDataDir)
+	s.links = newLinkStore(*flagDataDir)
+	s.pickups = newPickupStore(*flagDataDir)
+	s.presets = newPresetStore(*flagDataDir)
+	s.lateOrders = newLateStore(*flagDataDir)
+	s.preferences = newPreferenceStore(*flagDataDir)
+	s.absences = newAbsenceStore(*flagDataDir)
+	s.approvals = newApprovalStore(*flagDataDir)
+	s.leftovers = newLeftoverStore(*flagDataDir)
+	s.deliveries = newDeliveryStore(*flagDataDir)
+	s.orderChanges = newChangeStore(*flagDataDir)
+	if s.nutritionFacts, err = newNutritionStore(*flagDataDir); err != nil {
+		return nil, err
+	}
+	if err := setupTranslate(*flagDataDir); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serve runs the web server and its background jobs
+func (s *Server) serve(ctx context.Context, args []string) error {
+	if err := parseTemplates(); err != nil {
+		return fmt.Errorf("could not parse templates: %v", err)
+	}
+
+	// setup authentication
+	setupSessionKey()
+	auth, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("invalid authentication configuration: %v", err)
+	}
+	if auth == nil && len(s.cfg.admins) > 0 {
+		slog.Warn("-admin has no effect without authentication")
+	}
+	if auth == nil && *flagPaymentProvider == "paypal" {
+		// anyone could point everyone's payments at their own PayPal.me
+		return fmt.Errorf("-payment-provider paypal requires authentication")
+	}
+
+	if *flagDebugAddr != "" {
+		if err := checkDebugAddr(*flagDebugAddr); err != nil {
+			return fmt.Errorf("invalid -debug-addr: %v", err)
+		}
+	}
+
+	// setup access control
+	allowedNets, err := parseCIDRs(flagAllowCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid -allow-cidr: %v", err)
+	}
+
+	// background jobs
+	digestDay, ok := parseWeekday(*flagDigestDay)
+	if !ok {
+		return fmt.Errorf("invalid -digest-day %q", *flagDigestDay)
+	}
+	if *flagArchiveInterval > 0 {
+		go s.runArchiver(ctx, *flagArchiveInterval)
+	}
+	if flagDigestTime.IsSet() {
+		go s.runDigest(ctx, flagDigestTime, digestDay)
+	}
+	if s.cfg.reminder > 0 {
+		go s.runReminders(ctx)
+	}
+	if s.mqttClient != nil {
+		go s.runMQTT(ctx, *flagMQTTInterval)
+	}
+	if s.cfg.pollsETA() {
+		go s.runETA(ctx, *flagETAInterval)
+	}
+	if len(flagClosures) > 0 {
+		go s.runClosures(ctx, closuresInterval)
+	}
+	if peopleDirectory != nil {
+		go s.runPeople(ctx, directoryInterval)
+	}
+	go s.links.run(ctx, linkFlushInterval)
+	go s.runAbsences(ctx, time.Hour)
+	// on a public site anyone could have set them for anyone
+	if auth != nil {
+		go s.runStandingOrders(ctx, time.Minute)
+	}
+	if s.cfg.hasDeadline() && len(s.reminderChannels()) > 0 {
+		go s.runPersonalReminders(ctx, time.Minute)
+	}
+	if s.cfg.calendarInvite {
+		go s.runInvitations(ctx)
+	}
+	if flagWikiTime.IsSet() {
+		go s.runWiki(ctx, flagWikiTime)
+	}
+	if *flagCheckInterval > 0 {
+		go s.runSheetMonitor(ctx, *flagCheckInterval)
+	}
+	if s.nutritionFacts != nil {
+		go s.nutritionFacts.run(ctx)
+	}
+	if translations != nil {
+		go translations.run(ctx)
+	}
+
+	if *flagDebugAddr != "" {
+		go func() {
+			slog.Info("starting debug server", "addr", *flagDebugAddr)
+			fatal("debug server stopped", http.ListenAndServe(*flagDebugAddr, debugHandler()))
+		}()
+	}
+
+	addr := fmt.Sprintf(":%d", *flagPort)
+	slog.Info("starting server", "addr", addr, "version", version)
+	handler := http.Handler(s.routes())
+	handler = requireAuth(auth, handler)
+	handler = s.withHooks(handler)
+	handler = timeoutHandler(*flagHandlerTimeout, handler)
+	handler = s.allowCIDRs(allowedNets, handler)
+	handler = s.securityHeaders(handler)
+	if devMode {
+		handler = noStore(handler)
+	} else {
+		handler = cacheAndCompress(handler)
+	}
+	handler = recoverPanics(handler)
+	handler = logRequests(handler)
+	if *flagHandlerTimeout >= *flagWriteTimeout {
+		slog.Warn("-handler-timeout should be shorter than -write-timeout")
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       *flagReadTimeout,
+		ReadHeaderTimeout: *flagReadTimeout,
+		WriteTimeout:      *flagWriteTimeout,
+		IdleTimeout:       *flagIdleTimeout,
+	}
+	if *flagH2C {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if *flagTLSCert != "" || *flagTLSKey != "" {
+		if *flagTLSCert == "" || *flagTLSKey == "" {
+			return fmt.Errorf("-tls-cert and -tls-key go together")
+		}
+		// net/http negotiates HTTP/2 over TLS by itself
+		return server.ListenAndServeTLS(*flagTLSCert, *flagTLSKey)
+	}
+	return server.ListenAndServe()
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	// "/" catches every path the mux doesn't know
+	if r.URL.Path != "/" {
+		s.notFound(w, r)
+		return
+	}
+	// monitoring checks, don't fetch the sheet for them
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return
+	}
+	logger := requestLogger(r).With("route", r.URL.Path)
+
+	loc, err := s.requestLocation(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t := s.cfg.now().In(loc)
+	lang := requestLanguage(w, r)
+
+	oo, err := s.ordersOn(r.Context(), logger, t)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	day, err := s.comments.Load(t.Format(timeLayout))
+	if err != nil {
+		logger.Error("could not read comments", "err", err)
+		day = &dayComments{}
+	}
+	menu, err := s.menus.View(s.cfg.vendorFor(t))
+	if err != nil {
+		logger.Error("could not read menu", "err", err)
+	}
+	// once orders closed, whose turn it is to pick up stays put
+	deadline, ok := s.cfg.deadlineOn(t)
+	pickup, err := s.pickupOn(t, oo, ok && !t.Before(deadline))
+	if err != nil {
+		logger.Error("could not read pickups", "err", err)
+	}
+	late, err := s.lateOn(r, t.Format(timeLayout), ok && !t.Before(deadline), oo.Names)
+	if err != nil {
+		logger.Error("could not read late orders", "err", err)
+	}
+	leftoverList, err := s.leftoversOn(r, t, oo.Names)
+	if err != nil {
+		logger.Error("could not read leftovers", "err", err)
+	}
+	// the steps of the delivery show once the order went out
+	delivery, err := s.deliveryOn(t.Format(timeLayout))
+	if err != nil {
+		logger.Error("could not read delivery state", "err", err)
+	}
+	if delivery != nil && delivery.State == deliveryCollecting {
+		delivery = nil
+	}
+	preorders, err := s.preordersOn(r, t, oo.Names)
+	if err != nil {
+		logger.Error("could not read pre-orders", "err", err)
+	}
+	away, err := s.awayOn(t.Format(timeLayout))
+	if err != nil {
+		logger.Error("could not read absences", "err", err)
+	}
+	// the estimate helps while orders are open, e.g. to reach a minimum order
+	var estimate *forecast
+	if !ok || t.Before(deadline) {
+		if estimate, err = s.forecastOn(t, oo); err != nil {
+			logger.Error("could not forecast orders", "err", err)
+		}
+	}
+	// what goes to the restaurant leaves out orders waiting for approval
+	vendor, approvalItems, err := s.vendorOrders(t.Format(timeLayout), oo)
+	if err != nil {
+		logger.Error("could not read approvals", "err", err)
+	}
+	var approvalList *approvalView
+	if len(approvalItems) > 0 {
+		approvalList = &approvalView{Items: approvalItems, Approver: s.isApprover(r)}
+	}
+	budget, err := s.budgetOn(t, vendor)
+	if err != nil {
+		logger.Error("could not add up the budget", "err", err)
+	}
+	text := s.cfg.summary(vendor)
+	share := s.cfg.shareText(t, vendor)
+	logger.Info("rendering orders",
+		"orders", len(oo.LineItems()),
+		"summary", text,
+	)
+
+	data := map[string]interface{}{
+		"Now":         s.dataTime(t).Format(time.RFC1123Z),
+		"Today":       t.Format("2006-01-02"),
+		"TimeZone":    loc.String(),
+		"OwnTimeZone": loc.String() != s.cfg.location.String(),
+		"Mailto":      mailtoURL(s.cfg.orderRecipients(t), s.cfg.mailSubjectOn(t), text),
+		"SheetURL":    s.cfg.sheetURL,
+		"Order":       oo,
+		"Me":          s.claimedName(r, oo.Names),
+		"LoggedIn":    identityFromRequest(r) != nil,
+		"LogOut":      s.cfg.logout,
+		"Away":        away,
+		"Comments":    commentsView{Day: day, Me: s.commentAuthor(r, oo.Names)},
+		"Maintenance": s.cfg.maintenanceBanner(),
+		"Stale":       s.staleBanner(loc),
+		"Leaderboard": s.indexLeaderboard(r),
+		"Self":        r.URL.RequestURI(),
+		"Kiosk":       r.URL.Query().Has("kiosk"),
+		"Share":       share,
+		"WhatsApp":    whatsappURL(share),
+		"Menu":        menu,
+		"Allergies":   s.allergyNotes(r, logger, oo),
+		"Nutrition":   s.nutritionNotes(oo),
+		"Lang":        lang,
+		"Languages":   languages(),
+		"Glosses":     glosses(oo, lang),
+		"Deadline":    s.cfg.deadlineFor(t),
+		"Entries":     s.entryNotes(t.Format(timeLayout), oo),
+		"Pickup":      pickup,
+		"Forecast":    estimate,
+		"Shared":      order.SharedItems(oo.LineItems()),
+		"Late":        late,
+		"Leftovers":   leftoverList,
+		"Delivery":    delivery,
+		"Preorders":   preorders,
+		"Approvals":   approvalList,
+		"Budget":      budget,
+		"Closed":      closures.Reason(t.In(s.cfg.location).Format(timeLayout)),
+	}
+	render(w, r, "index", data)
+}
+
+// dataTime is when the shown orders were read from the sheet, in the zone of
+// t. Unlike the current time it only changes with the data, so unchanged
+// pages keep their ETag.
+func (s *Server) dataTime(t time.Time) time.Time {
+	if fetched := s.sheet.Status().FetchedAt; !fetched.IsZero() {
+		return fetched.In(t.Location()).Truncate(time.Second)
+	}
+	return t
+}
+
+// staleBanner explains that the page shows old data when the sheet couldn't
+// be fetched
+func (s *Server) staleBanner(loc *time.Location) string {
+	st := s.sheet.Status()
+	if !st.Stale() {
+		return ""
+	}
+	return fmt.Sprintf("Showing data from %s, Google Sheets is currently unreachable.", st.FetchedAt.In(loc).Format("15:04"))
+}
+
+// todaysOrders fetches the sheet and returns the orders in today's row
+func (s *Server) todaysOrders(ctx context.Context, logger *slog.Logger) (*order.Overview, error) {
+	return s.ordersOn(ctx, logger, s.cfg.now())
+}
+
+// ordersOn fetches the sheet and returns the orders in the row for the day
+// of t, with those placed outside the sheet, e.g. by email, and everyone
+// from -directory who isn't away
+func (s *Server) ordersOn(ctx context.Context, logger *slog.Logger, t time.Time) (*order.Overview, error) {
+	names, cells, err := s.sheetRow(ctx, logger, t)
+	if err != nil {
+		return nil, err
+	}
+	if names, cells, err = s.entries.Overlay(t.Format(timeLayout), names, cells); err != nil {
+		logger.Error("could not read orders placed outside the sheet", "err", err)
+	}
+	names, cells = s.cfg.withDirectory(names, cells)
+	if names, cells, err = s.withoutAbsent(t.Format(timeLayout), names, cells); err != nil {
+		logger.Error("could not read absences", "err", err)
+	}
+	return order.New(names, cells), nil
+}
+
+// sheetRow fetches the sheet and returns the names in the header and the
+// orders in the row for the day of t as they are in the sheet
+func (s *Server) sheetRow(ctx context.Context, logger *slog.Logger, t time.Time) (names, cells []string, err error) {
+	rows, err := s.sheet.Rows(ctx, logger)
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, nil, fmt.Errorf("%w: %w", sheet.ErrMalformed, err)
+		}
+		return nil, nil, fmt.Errorf("%w: %w", errSheetUnreachable, err)
+	}
+
+	header, err := s.cfg.layout.HeaderRow(rows)
+	if err != nil {
+		logger.Error("unexpected sheet layout", "err", err)
+		return nil, nil, err
+	}
+	row, err := s.cfg.layout.FindRow(rows, t)
+	if err != nil {
+		logger.Warn("no row for today", "err", err)
+		return nil, nil, fmt.Errorf("error for today's row: %w", err)
+	}
+	return sheet.Cells(header), sheet.Cells(row), nil
+}
+
+// errSheetUnreachable means fetching the sheet failed
+var errSheetUnreachable = errors.New("could not fetch the sheet")
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// maintenance freezes everything that writes to the sheet or notifies people,
+// e.g. while the sheet owner is restructuring columns
+var maintenance atomic.Bool
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "maintenance",
+		Label: "Toggle maintenance mode",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			if setMaintenance(!inMaintenance()) {
+				return "Maintenance mode enabled", nil
+			}
+			return "Maintenance mode disabled", nil
+		},
+	})
+}
+
+// inMaintenance reports whether write-back and notifications are frozen
+func inMaintenance() bool {
+	return maintenance.Load()
+}
+
+// setMaintenance switches maintenance mode and returns the new state
+func setMaintenance(on bool) bool {
+	maintenance.Store(on)
+	return on
+}
+
+// maintenanceBanner returns the banner text to show, or "" outside of
+// maintenance mode
+func (c *config) maintenanceBanner() string {
+	if !inMaintenance() {
+		return ""
+	}
+	return c.maintenanceMessage
+}
@@ -0,0 +1,348 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// maxMenuSize limits menu uploads and downloads
+const maxMenuSize = 10 << 20
+
+// menuURLTTL is how long a menu fetched from a vendor's menu_url is kept
+// before fetching it again
+const menuURLTTL = time.Hour
+
+// menuTypes are the menu formats shown inline, by sniffed content type, with
+// the extension uploads are stored under
+var menuTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// Slug returns the vendor's name as used in URLs and file names
+func (v *Vendor) Slug() string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, v.Name), "-")
+}
+
+// fetchedMenu is a menu downloaded from a menu_url
+type fetchedMenu struct {
+	body        []byte
+	contentType string
+	fetched     time.Time
+}
+
+// menuStore keeps the menus uploaded through the admin page under
+// <data-dir>/menus, one file per vendor next to the items imported from it
+// as <slug>.json, and the ones fetched from a
+// vendor's menu_url in memory. fetchMu is separate so a slow download
+// doesn't hold up the pages.
+type menuStore struct {
+	mu     sync.Mutex
+	dir    string
+	client *http.Client
+
+	fetchMu sync.Mutex
+	fetched map[string]*fetchedMenu
+}
+
+func newMenuStore(dataDir string) *menuStore {
+	return &menuStore{
+		dir:     filepath.Join(dataDir, "menus"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+		fetched: make(map[string]*fetchedMenu),
+	}
+}
+
+// Upload returns the file name of the menu uploaded for v, or ""
+func (m *menuStore) Upload(v *Vendor) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.upload(v)
+}
+
+func (m *menuStore) upload(v *Vendor) (string, error) {
+	for _, ext := range menuTypes {
+		name := v.Slug() + ext
+		if _, err := os.Stat(filepath.Join(m.dir, name)); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Save stores the uploaded menu of v, replacing an earlier upload. It takes
+// precedence over the vendor's menu_url.
+func (m *menuStore) Save(v *Vendor, ext string, b []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, err := m.upload(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	name := v.Slug() + ext
+	if err := writeFileAtomic(filepath.Join(m.dir, name), b); err != nil {
+		return err
+	}
+	if old != "" && old != name {
+		return os.Remove(filepath.Join(m.dir, old))
+	}
+	return nil
+}
+
+// Remove deletes the uploaded menu of v, falling back to its menu_url
+func (m *menuStore) Remove(v *Vendor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, err := m.upload(v)
+	if err != nil || name == "" {
+		return err
+	}
+	return os.Remove(filepath.Join(m.dir, name))
+}
+
+// Items returns the menu items stored for v, nil when there are none
+func (m *menuStore) Items(v *Vendor) ([]order.MenuItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, err := os.ReadFile(filepath.Join(m.dir, v.Slug()+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []order.MenuItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("menu of %s: %v", v.Name, err)
+	}
+	return items, nil
+}
+
+// menuItems returns what v has on the menu: its "menu" in -vendors and the
+// items stored on the admin page. nil without a vendor.
+func (s *Server) menuItems(logger *slog.Logger, v *Vendor) []order.MenuItem {
+	if v == nil {
+		return nil
+	}
+	var items []order.MenuItem
+	for _, name := range v.Menu {
+		items = append(items, order.MenuItem{Name: name})
+	}
+	stored, err := s.menus.Items(v)
+	if err != nil {
+		logger.Error("could not read menu", "vendor", v.Name, "err", err)
+	}
+	return append(items, stored...)
+}
+
+// SaveItems replaces the menu items stored for v
+func (m *menuStore) SaveItems(v *Vendor, items []order.MenuItem) error {
+	b, err := json.MarshalIndent(items, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(m.dir, v.Slug()+".json"), b)
+}
+
+// menuView is how the index shows the menu of the day's vendor
+type menuView struct {
+	Vendor string
+	URL    string
+	PDF    bool
+}
+
+// View returns how to show the menu of v, or nil when it has none. It only
+// looks at names, so the page doesn't wait for a menu_url to download.
+func (m *menuStore) View(v *Vendor) (*menuView, error) {
+	if v == nil {
+		return nil, nil
+	}
+	name, err := m.Upload(v)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if v.MenuURL == "" {
+			return nil, nil
+		}
+		name = v.MenuURL
+		if u, err := url.Parse(v.MenuURL); err == nil {
+			name = u.Path
+		}
+	}
+	return &menuView{
+		Vendor: v.Name,
+		URL:    "/menus/" + v.Slug(),
+		PDF:    strings.EqualFold(path.Ext(name), ".pdf"),
+	}, nil
+}
+
+// fetch returns the menu at v's menu_url, downloading it when it wasn't
+// lately
+func (m *menuStore) fetch(v *Vendor) (*fetchedMenu, error) {
+	m.fetchMu.Lock()
+	defer m.fetchMu.Unlock()
+
+	if f, ok := m.fetched[v.MenuURL]; ok && appClock.Now().Sub(f.fetched) < menuURLTTL {
+		return f, nil
+	}
+	resp, err := m.client.Get(v.MenuURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", v.MenuURL, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxMenuSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxMenuSize {
+		return nil, fmt.Errorf("%s: larger than %d MB", v.MenuURL, maxMenuSize>>20)
+	}
+	contentType := http.DetectContentType(b)
+	if _, ok := menuTypes[contentType]; !ok {
+		return nil, fmt.Errorf("%s: not an image or PDF but %s", v.MenuURL, contentType)
+	}
+	f := &fetchedMenu{body: b, contentType: contentType, fetched: appClock.Now()}
+	m.fetched[v.MenuURL] = f
+	return f, nil
+}
+
+// vendorBySlug returns the vendor with the slug, or nil
+func (c *config) vendorBySlug(slug string) *Vendor {
+	for _, v := range c.vendors {
+		if v.Slug() == slug {
+			return v
+		}
+	}
+	return nil
+}
+
+// handleMenu serves the menu of a vendor at /menus/<slug>: the one uploaded
+// on the admin page, or else the one at its menu_url. The index embeds it,
+// so unlike other pages it may be framed by this site.
+func (s *Server) handleMenu(w http.ResponseWriter, r *http.Request) {
+	v := s.cfg.vendorBySlug(strings.TrimPrefix(r.URL.Path, "/menus/"))
+	if v == nil {
+		s.notFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'self'")
+	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+
+	name, err := s.menus.Upload(v)
+	if err != nil {
+		requestLogger(r).Error("could not read menu", "err", err)
+		http.Error(w, "could not read menu", http.StatusInternalServerError)
+		return
+	}
+	if name != "" {
+		http.ServeFile(w, r, filepath.Join(s.menus.dir, name))
+		return
+	}
+	if v.MenuURL == "" {
+		s.notFound(w, r)
+		return
+	}
+	f, err := s.menus.fetch(v)
+	if err != nil {
+		requestLogger(r).Warn("could not fetch menu", "vendor", v.Name, "err", err)
+		http.Error(w, "could not fetch the menu of "+v.Name, http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", f.contentType)
+	w.Write(f.body)
+}
+
+// handleMenuUpload stores the menu uploaded for a vendor on the admin page,
+// or with remove set goes back to the vendor's menu_url
+func (s *Server) handleMenuUpload(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxMenuSize+1<<20)
+	v := s.cfg.vendorBySlug(r.FormValue("vendor"))
+	if v == nil {
+		http.Error(w, "unknown vendor", http.StatusBadRequest)
+		return
+	}
+
+	var msg string
+	if r.FormValue("remove") != "" {
+		if err := s.menus.Remove(v); err != nil {
+			msg = "Failed: " + err.Error()
+		} else {
+			msg = "Removed the uploaded menu of " + v.Name
+		}
+	} else if b, contentType, err := readMenuFile(r, "menu"); err != nil {
+		msg = "Failed: " + err.Error()
+	} else if err := s.menus.Save(v, menuTypes[contentType], b); err != nil {
+		msg = "Failed: " + err.Error()
+	} else {
+		msg = "Uploaded the menu of " + v.Name
+	}
+	requestLogger(r).Info("menu upload", "vendor", v.Name, "user", identityFromRequest(r).String(), "result", msg)
+	s.audit.Record(r, "admin/menu", msg)
+	http.Redirect(w, r, "/admin?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+}
+
+// readMenuFile returns the image or PDF of a menu posted as field, and its
+// content type
+func readMenuFile(r *http.Request, field string) ([]byte, string, error) {
+	f, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, "", fmt.Errorf("choose an image or PDF of the menu, up to %d MB", maxMenuSize>>20)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxMenuSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(b) > maxMenuSize {
+		return nil, "", fmt.Errorf("menus are at most %d MB", maxMenuSize>>20)
+	}
+	contentType := http.DetectContentType(b)
+	if _, ok := menuTypes[contentType]; !ok {
+		return nil, "", fmt.Errorf("menus are JPEG, PNG, GIF, WebP or PDF")
+	}
+	return b, contentType, nil
+}
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	identityKey
+)
+
+// requestIDHeader is the header used to propagate and return request IDs
+const requestIDHeader = "X-Request-ID"
+
+// requestIDRe is what a request ID from a proxy may look like, like a UUID;
+// anything else, which would end up in the logs and responses as is, gets a
+// new ID
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// statusRecorder remembers the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// logRequests assigns every request an ID, or keeps a valid one from a
+// proxy, returns it in the X-Request-ID header and logs method, path, status
+// and latency once the request is done
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRe.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		requestLogger(r).Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"latency", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// recoverPanics turns a panicking handler into a 500 response and logs the
+// stack trace, so a malformed sheet can't take down the whole process
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// deliberate abort, let net/http handle it quietly
+				panic(err)
+			}
+			stack := string(debug.Stack())
+			if p, ok := err.(*handlerPanic); ok {
+				err, stack = p.value, string(p.stack)
+			}
+			requestLogger(r).Error("panic while handling request",
+				"path", r.URL.Path,
+				"panic", err,
+				"stack", stack,
+			)
+			reportError(r, "panic while handling request", fmt.Errorf("%v", err), map[string]string{"stack": stack})
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultCSP only allows resources from our own origin; the inline <style>
+// block in the templates needs 'unsafe-inline' for styles
+const defaultCSP = "default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'; form-action 'self'"
+
+// securityHeaders sets the CSP, frame, referrer and content-type options
+// headers on every response. Empty flag values leave a header out.
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if s.cfg.csp != "" {
+			h.Set("Content-Security-Policy", s.cfg.csp)
+		}
+		if s.cfg.frameOptions != "" {
+			h.Set("X-Frame-Options", s.cfg.frameOptions)
+		}
+		if s.cfg.referrerPolicy != "" {
+			h.Set("Referrer-Policy", s.cfg.referrerPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLogger returns the default logger annotated with the request ID
+func requestLogger(r *http.Request) *slog.Logger {
+	if r == nil {
+		return slog.Default()
+	}
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
+		return slog.With("request_id", id)
+	}
+	return slog.Default()
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
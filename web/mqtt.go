@@ -0,0 +1,181 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/mqtt"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var flagMQTT = flag.String("mqtt", "", "publish the lunch state to this MQTT broker, mqtt://[user:password@]host[:port] or mqtts:// for TLS")
+var flagMQTTTopic = flag.String("mqtt-topic", "lunchweb", "topic prefix of the lunch state, published as JSON to <prefix>/state")
+var flagMQTTInterval = flag.Duration("mqtt-interval", time.Minute, "how often to publish the lunch state to -mqtt")
+var flagMQTTDiscovery = flag.String("mqtt-discovery", "homeassistant", "Home Assistant MQTT discovery prefix to announce the lunch sensors on (empty to disable)")
+
+// newMQTTClient returns the client publishing to the -mqtt broker, nil
+// without -mqtt
+func newMQTTClient() (*mqtt.Client, error) {
+	if *flagMQTT == "" {
+		return nil, nil
+	}
+	if *flagMQTTInterval <= 0 {
+		return nil, fmt.Errorf("-mqtt-interval must be positive, got %s", *flagMQTTInterval)
+	}
+	if *flagMQTTTopic == "" || strings.ContainsAny(*flagMQTTTopic, "#+") {
+		return nil, fmt.Errorf("invalid -mqtt-topic %q", *flagMQTTTopic)
+	}
+	client, err := mqtt.New(*flagMQTT, "lunchweb")
+	if err != nil {
+		return nil, fmt.Errorf("invalid -mqtt: %v", err)
+	}
+	return client, nil
+}
+
+// lunchState is what smart home systems get to know about today's lunch
+type lunchState struct {
+	Ordered int    `json:"ordered"`
+	People  int    `json:"people"`
+	Vendor  string `json:"vendor,omitempty"`
+	// Open is whether orders are taken: there's a row for today and the
+	// deadline hasn't passed
+	Open     bool       `json:"open"`
+	Deadline *time.Time `json:"deadline"`
+	// MinutesLeft counts down to the deadline, nil without one
+	MinutesLeft *int `json:"minutes_left"`
+	// Delivery is how far the delivery got, like "out for delivery"
+	Delivery string `json:"delivery"`
+	// ETA is the estimated arrival, nil when unknown or arrived
+	ETA *time.Time `json:"eta"`
+}
+
+// lunchStateAt returns the state of the day's lunch at t
+func (s *Server) lunchStateAt(ctx context.Context, t time.Time) (*lunchState, error) {
+	state := &lunchState{Vendor: s.cfg.vendorName(t)}
+	if !s.cfg.isOrderingDay(t) {
+		return state, nil
+	}
+	oo, err := s.ordersOn(ctx, slog.Default(), t)
+	if errors.Is(err, sheet.ErrNoRow) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.Ordered, state.People, state.Open = len(oo.LineItems()), oo.MaxCount(), true
+	delivery, err := s.deliveryOn(t.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	state.Delivery = delivery.State
+	if delivery.State != deliveryArrived {
+		state.ETA = delivery.ETA
+	}
+	if deadline, ok := s.cfg.deadlineOn(t); ok {
+		left := int(deadline.Sub(t).Round(time.Minute) / time.Minute)
+		if left < 0 {
+			left = 0
+		}
+		state.Deadline, state.MinutesLeft, state.Open = &deadline, &left, t.Before(deadline)
+	}
+	return state, nil
+}
+
+// mqttDiscovery returns the Home Assistant discovery configs of the lunch
+// sensors, which read stateTopic
+func mqttDiscovery(prefix, stateTopic string) []mqtt.Message {
+	device := map[string]interface{}{"identifiers": []string{"lunchweb"}, "name": "Lunch", "manufacturer": "lunchweb"}
+	configs := []struct {
+		component, id string
+		config        map[string]interface{}
+	}{
+		{"sensor", "ordered", map[string]interface{}{
+			"name":                "Lunch orders",
+			"value_template":      "{{ value_json.ordered }}",
+			"unit_of_measurement": "orders",
+			"icon":                "mdi:food",
+			// the rest of the state, like the vendor, as attributes
+			"json_attributes_topic": stateTopic,
+		}},
+		{"sensor", "deadline", map[string]interface{}{
+			"name":           "Lunch order deadline",
+			"value_template": "{{ value_json.deadline }}",
+			"device_class":   "timestamp",
+		}},
+		{"sensor", "minutes_left", map[string]interface{}{
+			"name":                "Lunch order minutes left",
+			"value_template":      "{{ value_json.minutes_left }}",
+			"unit_of_measurement": "min",
+			"icon":                "mdi:timer-sand",
+		}},
+		{"sensor", "delivery", map[string]interface{}{
+			"name":           "Lunch delivery",
+			"value_template": "{{ value_json.delivery }}",
+			"icon":           "mdi:moped",
+		}},
+		{"binary_sensor", "open", map[string]interface{}{
+			"name":           "Lunch orders open",
+			"value_template": "{{ 'ON' if value_json.open else 'OFF' }}",
+		}},
+	}
+	var msgs []mqtt.Message
+	for _, c := range configs {
+		c.config["unique_id"] = "lunchweb_" + c.id
+		c.config["state_topic"] = stateTopic
+		c.config["device"] = device
+		payload, _ := json.Marshal(c.config)
+		msgs = append(msgs, mqtt.Message{
+			Topic:   fmt.Sprintf("%s/%s/lunchweb/%s/config", prefix, c.component, c.id),
+			Payload: payload,
+			Retain:  true,
+		})
+	}
+	return msgs
+}
+
+// publishState publishes the lunch state, and the discovery configs so Home
+// Assistant finds the sensors again after the broker lost them
+func (s *Server) publishState(ctx context.Context) error {
+	state, err := s.lunchStateAt(ctx, s.cfg.now())
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	stateTopic := s.cfg.mqttTopic + "/state"
+	var msgs []mqtt.Message
+	if s.cfg.mqttDiscovery != "" {
+		msgs = mqttDiscovery(s.cfg.mqttDiscovery, stateTopic)
+	}
+	msgs = append(msgs, mqtt.Message{Topic: stateTopic, Payload: payload, Retain: true})
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	return s.mqttClient.Publish(ctx, msgs...)
+}
+
+// runMQTT publishes the lunch state every interval until ctx is done
+func (s *Server) runMQTT(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runJob("mqtt", func() {
+			if err := s.publishState(ctx); err != nil {
+				slog.Error("could not publish lunch state to MQTT", "err", err)
+			}
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
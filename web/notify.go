@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+var flagNotifyFormat stringList
+
+func init() {
+	flag.Var(&flagNotifyFormat, "notify-format", "message format of a notifier, e.g. slack=chat for a bullet list with bold names and emoji instead of text (repeatable)")
+}
+
+// notifiers are the notifiers configured through the flags
+var notifiers []notify.Notifier
+
+func setupNotifiers() error {
+	notifiers = nil
+	if *flagSMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(*flagSMTPAddr); err != nil {
+			return fmt.Errorf("invalid -smtp-addr: %v", err)
+		}
+		if *flagSMTPFrom == "" {
+			return fmt.Errorf("-smtp-addr requires -smtp-from")
+		}
+		notifiers = append(notifiers, &notify.Email{
+			Addr:     *flagSMTPAddr,
+			User:     *flagSMTPUser,
+			Password: *flagSMTPPassword,
+			From:     *flagSMTPFrom,
+			To:       flagNotifyEmail,
+		})
+	}
+	if *flagSlackWebhook != "" {
+		notifiers = append(notifiers, &notify.Slack{URL: *flagSlackWebhook, Button: slackButton()})
+	}
+	if *flagNotifyWebhook != "" {
+		notifiers = append(notifiers, &notify.Webhook{URL: *flagNotifyWebhook})
+	}
+	return applyNotifyFormats()
+}
+
+// applyNotifyFormats sets up the notifiers named in -notify-format with
+// their format
+func applyNotifyFormats() error {
+	for _, f := range flagNotifyFormat {
+		name, format, _ := strings.Cut(f, "=")
+		if format != "text" && format != "chat" {
+			return fmt.Errorf("invalid -notify-format %q, expected e.g. slack=chat or slack=text", f)
+		}
+		found := false
+		for i, n := range notifiers {
+			if n.Name() != name {
+				continue
+			}
+			found = true
+			if format == "chat" {
+				notifiers[i] = notify.ChatFormat(n)
+			}
+		}
+		if !found {
+			return fmt.Errorf("-notify-format %q: no %s notifier is set up", f, name)
+		}
+	}
+	return nil
+}
+
+// emailNotifier returns the email notifier, nil without -smtp-addr
+func emailNotifier() notify.Notifier {
+	for _, n := range notifiers {
+		if n.Name() == "email" {
+			return n
+		}
+	}
+	return nil
+}
+
+const notifyTimeout = 30 * time.Second
+
+// notifyAll sends msg through every notifier and records the send in the
+// audit log. Nothing is sent in maintenance mode. r is the request that
+// triggered the notification, or nil for scheduled ones.
+func (s *Server) notifyAll(ctx context.Context, r *http.Request, msg notify.Message) error {
+	if inMaintenance() {
+		slog.Info("not sending notification in maintenance mode", "subject", msg.Subject)
+		return fmt.Errorf("notifications are frozen in maintenance mode")
+	}
+	if len(notifiers) == 0 {
+		return fmt.Errorf("no notifiers configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	var failed []string
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, msg); err != nil {
+			slog.Error("could not send notification", "notifier", n.Name(), "subject", msg.Subject, "err", err)
+			failed = append(failed, n.Name())
+			continue
+		}
+		slog.Info("sent notification", "notifier", n.Name(), "subject", msg.Subject)
+		s.audit.Record(r, "notify/"+n.Name(), msg.Subject)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("sending failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "test-notifiers",
+		Label: "Send test notification",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			msg := notify.Message{
+				Subject: "LunchWeb test notification",
+				Text:    fmt.Sprintf("This is a test sent by %s from the admin page.", identityFromRequest(r)),
+			}
+			if err := s.notifyAll(r.Context(), r, msg); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Test notification sent through %d notifiers", len(notifiers)), nil
+		},
+	})
+}
@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/nutrition"
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagNutrition = flag.String("nutrition", "", "estimate calories and macros of the orders: openfoodfacts, or a JSON file of dishes and their kcal, protein, carbs and fat")
+
+// nutritionLookupTimeout bounds one lookup in the background
+const nutritionLookupTimeout = 15 * time.Second
+
+// nutritionEntry is what a source said about one item; items it doesn't
+// know are remembered too, so they aren't looked up again and again
+type nutritionEntry struct {
+	Facts nutrition.Facts `json:"facts"`
+	Found bool            `json:"found"`
+}
+
+// nutritionStore caches the nutrients of items by order.ItemKey in
+// <data-dir>/nutrition.json. Pages only read the cache; items missing from
+// it are queued and looked up one by one in the background, so a slow API
+// never holds up a page.
+type nutritionStore struct {
+	source nutrition.Source
+	path   string
+
+	mu      sync.Mutex
+	entries map[string]nutritionEntry
+	pending map[string]bool
+	queue   chan string
+}
+
+// newNutritionStore returns the cache of -nutrition in dataDir, nil without
+// -nutrition
+func newNutritionStore(dataDir string) (*nutritionStore, error) {
+	var source nutrition.Source
+	switch *flagNutrition {
+	case "":
+		return nil, nil
+	case "openfoodfacts":
+		source = &nutrition.OpenFoodFacts{Interval: 6 * time.Second}
+	default:
+		table, err := nutrition.LoadTable(*flagNutrition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -nutrition: %v", err)
+		}
+		source = table
+	}
+
+	n := &nutritionStore{
+		source:  source,
+		path:    filepath.Join(dataDir, "nutrition.json"),
+		entries: make(map[string]nutritionEntry),
+		pending: make(map[string]bool),
+		queue:   make(chan string, 100),
+	}
+	b, err := os.ReadFile(n.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &n.entries); err != nil {
+			return nil, fmt.Errorf("%s: %v", n.path, err)
+		}
+	}
+	return n, nil
+}
+
+// Get returns the cached facts of item, queueing it for a lookup when it
+// isn't cached yet
+func (n *nutritionStore) Get(item string) (nutrition.Facts, bool) {
+	key := order.ItemKey(order.WithoutPrice(item))
+	if key == "" {
+		return nutrition.Facts{}, false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e, ok := n.entries[key]; ok {
+		return e.Facts, e.Found
+	}
+	if !n.pending[key] {
+		select {
+		case n.queue <- key:
+			n.pending[key] = true
+		default:
+			// full, the item comes up again on the next page
+		}
+	}
+	return nutrition.Facts{}, false
+}
+
+// Item returns the facts of an order, adding up its parts, e.g. food and
+// drink. ok is false when none of them is known.
+func (n *nutritionStore) Item(li *order.LineItem) (facts nutrition.Facts, ok bool) {
+	if len(li.Parts) == 0 {
+		return n.Get(li.Order)
+	}
+	for _, p := range li.Parts {
+		if f, found := n.Get(p.Order); found {
+			facts = facts.Add(f)
+			ok = true
+		}
+	}
+	return facts, ok
+}
+
+// run looks up the queued items until ctx is done
+func (n *nutritionStore) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-n.queue:
+			n.lookup(ctx, key)
+		}
+	}
+}
+
+func (n *nutritionStore) lookup(ctx context.Context, key string) {
+	ctx, cancel := context.WithTimeout(ctx, nutritionLookupTimeout)
+	defer cancel()
+	facts, found, err := n.source.Lookup(ctx, key)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.pending, key)
+	if err != nil {
+		// not cached, so the next page asks again
+		slog.Warn("could not look up nutrition", "source", n.source.Name(), "item", key, "err", err)
+		return
+	}
+	n.entries[key] = nutritionEntry{facts, found}
+	if err := n.save(); err != nil {
+		slog.Error("could not store nutrition", "err", err)
+	}
+}
+
+// save writes the cache; n.mu must be held
+func (n *nutritionStore) save() error {
+	b, err := json.MarshalIndent(n.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(n.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(n.path, b)
+}
+
+// nutritionNotes returns the estimate of every order by name, shown as a
+// tooltip next to it; nil without -nutrition
+func (s *Server) nutritionNotes(oo *order.Overview) map[string]string {
+	if s.nutritionFacts == nil {
+		return nil
+	}
+	notes := make(map[string]string)
+	for _, li := range oo.LineItems() {
+		if f, ok := s.nutritionFacts.Item(li); ok {
+			notes[li.Name] = f.String()
+		}
+	}
+	return notes
+}
+
+// nutritionSummary adds up the estimates of someone's orders
+type nutritionSummary struct {
+	Total   nutrition.Facts
+	Average nutrition.Facts
+	// Known is how many orders have an estimate
+	Known int
+	// Orders are the estimates in the order of personHistory.Orders, ""
+	// when unknown
+	Orders []string
+}
+
+// summarizeNutrition returns the estimates of h's orders, nil without
+// -nutrition
+func (s *Server) summarizeNutrition(h *personHistory) *nutritionSummary {
+	if s.nutritionFacts == nil {
+		return nil
+	}
+	sum := &nutritionSummary{}
+	for _, o := range h.Orders {
+		f, ok := s.nutritionFacts.Item(&order.LineItem{Order: o.Order, Parts: o.Parts})
+		if !ok {
+			sum.Orders = append(sum.Orders, "")
+			continue
+		}
+		sum.Total = sum.Total.Add(f)
+		sum.Known++
+		sum.Orders = append(sum.Orders, f.String())
+	}
+	sum.Average = sum.Total.Div(sum.Known)
+	return sum
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/datacamp/lunchweb/ocr"
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagOCR = flag.String("ocr", "", "recognize menu photos to import their items on the admin page: tesseract, tesseract:<languages> like tesseract:eng+deu, or the URL of an OCR service")
+
+// newOCRReader returns what recognizes the text on imported menus, nil
+// without -ocr
+func newOCRReader() (ocr.Reader, error) {
+	switch {
+	case *flagOCR == "":
+		return nil, nil
+	case *flagOCR == "tesseract" || strings.HasPrefix(*flagOCR, "tesseract:"):
+		_, languages, _ := strings.Cut(*flagOCR, ":")
+		return &ocr.Tesseract{Languages: languages}, nil
+	case strings.HasPrefix(*flagOCR, "https://") || strings.HasPrefix(*flagOCR, "http://"):
+		return &ocr.HTTP{URL: *flagOCR}, nil
+	}
+	return nil, fmt.Errorf("invalid -ocr %q, expected tesseract, tesseract:<languages> or a URL", *flagOCR)
+}
+
+// menuItemsText lists items one per line, as edited on the admin page
+func menuItemsText(items []order.MenuItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// handleMenuItems edits the items on a vendor's menu at /admin/menus/<slug>.
+// Posting a photo or PDF of the menu recognizes its items with -ocr for the
+// admin to check; posting the items stores them.
+func (s *Server) handleMenuItems(w http.ResponseWriter, r *http.Request) {
+	v := s.cfg.vendorBySlug(strings.TrimPrefix(r.URL.Path, "/admin/menus/"))
+	if v == nil {
+		s.notFound(w, r)
+		return
+	}
+	data := map[string]interface{}{
+		"Vendor":  v,
+		"OCR":     s.ocrReader != nil,
+		"Message": r.URL.Query().Get("msg"),
+		"Example": order.MenuItem{Name: "Margherita", Price: 850, Priced: true}.String(),
+	}
+
+	if r.Method != http.MethodPost {
+		items, err := s.menus.Items(v)
+		if err != nil {
+			requestLogger(r).Error("could not read menu", "vendor", v.Name, "err", err)
+			http.Error(w, "could not read menu", http.StatusInternalServerError)
+			return
+		}
+		data["Items"] = menuItemsText(items)
+		render(w, r, "menu", data)
+		return
+	}
+
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxMenuSize+1<<20)
+	if r.FormValue("import") != "" {
+		items, err := s.recognizeMenu(r)
+		if err != nil {
+			requestLogger(r).Warn("could not import menu", "vendor", v.Name, "err", err)
+			data["Message"] = "Failed: " + err.Error()
+		} else {
+			data["Message"] = fmt.Sprintf("Recognized %d items: check them and save", len(items))
+		}
+		data["Items"] = menuItemsText(items)
+		render(w, r, "menu", data)
+		return
+	}
+
+	items := order.ParseMenu(r.FormValue("items"))
+	msg := fmt.Sprintf("Saved %d items", len(items))
+	if err := s.menus.SaveItems(v, items); err != nil {
+		requestLogger(r).Error("could not store menu", "vendor", v.Name, "err", err)
+		msg = "Failed: " + err.Error()
+	}
+	s.audit.Record(r, "admin/menu-items", v.Name+": "+msg)
+	http.Redirect(w, r, "/admin/menus/"+v.Slug()+"?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+}
+
+// recognizeMenu reads the items on the posted photo of a menu. Lines without
+// a price are mostly headings and descriptions, so they are dropped when
+// other lines have one.
+func (s *Server) recognizeMenu(r *http.Request) ([]order.MenuItem, error) {
+	if s.ocrReader == nil {
+		return nil, fmt.Errorf("no text recognition configured, set -ocr")
+	}
+	b, contentType, err := readMenuFile(r, "photo")
+	if err != nil {
+		return nil, err
+	}
+	text, err := s.ocrReader.Text(r.Context(), b, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.ocrReader.Name(), err)
+	}
+
+	items := order.ParseMenu(text)
+	var priced []order.MenuItem
+	for _, item := range items {
+		if item.Priced {
+			priced = append(priced, item)
+		}
+	}
+	if len(priced) > 0 {
+		return priced, nil
+	}
+	return items, nil
+}
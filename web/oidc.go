@@ -0,0 +1,294 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie   = "lunchweb_session"
+	oidcStateCookie = "lunchweb_oidc"
+	logoutPath      = "/logout"
+	sessionDuration = 12 * time.Hour
+)
+
+// oidcAuth logs users in through an OpenID Connect provider (Google
+// Workspace, Azure AD, ...) using the authorization code flow
+type oidcAuth struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	callbackPath string
+
+	authEndpoint  string
+	tokenEndpoint string
+
+	// allowedDomains restricts logins to these email domains when set
+	allowedDomains []string
+
+	// client talks to the provider, bounded by -fetch-timeout
+	client *http.Client
+}
+
+type oidcSession struct {
+	Identity
+	Expires time.Time `json:"exp"`
+}
+
+type oidcState struct {
+	State  string `json:"state"`
+	Nonce  string `json:"nonce"`
+	Return string `json:"return"`
+}
+
+func newOIDCAuth(issuer, clientID, clientSecret, redirectURL string) (*oidcAuth, error) {
+	if clientID == "" || clientSecret == "" || redirectURL == "" {
+		return nil, fmt.Errorf("-oidc-issuer requires -oidc-client-id, -oidc-client-secret and -oidc-redirect-url")
+	}
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect url: %v", err)
+	}
+
+	// discover the provider's endpoints
+	client := &http.Client{Timeout: *flagFetchTimeout}
+	resp, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: unexpected status %s", resp.Status)
+	}
+	var config struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+
+	a := &oidcAuth{
+		issuer:        config.Issuer,
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		redirectURL:   redirectURL,
+		callbackPath:  u.Path,
+		authEndpoint:  config.AuthorizationEndpoint,
+		tokenEndpoint: config.TokenEndpoint,
+		client:        client,
+	}
+	for _, d := range strings.Split(*flagOIDCAllowedDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			a.allowedDomains = append(a.allowedDomains, strings.ToLower(d))
+		}
+	}
+	return a, nil
+}
+
+func (a *oidcAuth) Authenticate(w http.ResponseWriter, r *http.Request) *Identity {
+	switch r.URL.Path {
+	case a.callbackPath:
+		a.callback(w, r)
+		return nil
+	case logoutPath:
+		// a POST from our own pages, so no other site can log people out
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return nil
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return nil
+		}
+		clearCookie(w, sessionCookie)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return nil
+	}
+
+	var session oidcSession
+	if signedCookie(r, sessionCookie, &session) && time.Now().Before(session.Expires) {
+		return &session.Identity
+	}
+
+	// only send browsers navigating to a page through the login flow
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	state := oidcState{State: randomToken(), Nonce: randomToken(), Return: r.URL.RequestURI()}
+	if err := setSignedCookie(w, r, oidcStateCookie, state, 10*time.Minute); err != nil {
+		http.Error(w, "could not start login", http.StatusInternalServerError)
+		return nil
+	}
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.clientID},
+		"redirect_uri":  {a.redirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state.State},
+		"nonce":         {state.Nonce},
+	}
+	http.Redirect(w, r, a.authEndpoint+"?"+q.Encode(), http.StatusFound)
+	return nil
+}
+
+// callback finishes the login started by Authenticate
+func (a *oidcAuth) callback(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+
+	var state oidcState
+	if !signedCookie(r, oidcStateCookie, &state) || r.FormValue("state") != state.State {
+		http.Error(w, "invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+	clearCookie(w, oidcStateCookie)
+	if e := r.FormValue("error"); e != "" {
+		http.Error(w, fmt.Sprintf("login failed: %s", e), http.StatusForbidden)
+		return
+	}
+
+	id, err := a.exchange(r.FormValue("code"), state.Nonce)
+	if err != nil {
+		logger.Warn("oidc login failed", "err", err)
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+	session := oidcSession{Identity: *id, Expires: time.Now().Add(sessionDuration)}
+	if err := setSignedCookie(w, r, sessionCookie, session, sessionDuration); err != nil {
+		http.Error(w, "could not store session", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("user logged in", "user", id.String())
+
+	ret := state.Return
+	if !strings.HasPrefix(ret, "/") || strings.HasPrefix(ret, "//") {
+		ret = "/"
+	}
+	http.Redirect(w, r, ret, http.StatusFound)
+}
+
+// exchange trades an authorization code for the user's identity. The ID token
+// comes straight from the token endpoint over TLS, so as allowed by OIDC Core
+// 3.1.3.7 its signature isn't checked; the claims still are.
+func (a *oidcAuth) exchange(code, nonce string) (*Identity, error) {
+	resp, err := a.client.PostForm(a.tokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.redirectURL},
+		"client_id":     {a.clientID},
+		"client_secret": {a.clientSecret},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint: unexpected status %s", resp.Status)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token.IDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id token: %v", err)
+	}
+	var claims struct {
+		Issuer        string          `json:"iss"`
+		Audience      json.RawMessage `json:"aud"`
+		Expiry        int64           `json:"exp"`
+		Nonce         string          `json:"nonce"`
+		Subject       string          `json:"sub"`
+		Name          string          `json:"name"`
+		Email         string          `json:"email"`
+		EmailVerified *bool           `json:"email_verified"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed id token: %v", err)
+	}
+
+	if claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !audienceContains(claims.Audience, a.clientID) {
+		return nil, fmt.Errorf("token not issued for this client")
+	}
+	if time.Now().After(time.Unix(claims.Expiry, 0)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+	if len(a.allowedDomains) > 0 {
+		// the domain of an address the provider didn't verify says nothing
+		if claims.EmailVerified == nil || !*claims.EmailVerified {
+			return nil, fmt.Errorf("email %q not verified", claims.Email)
+		}
+		if !a.domainAllowed(claims.Email) {
+			return nil, fmt.Errorf("email %q not in an allowed domain", claims.Email)
+		}
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Name:          claims.Name,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+	}, nil
+}
+
+func (a *oidcAuth) domainAllowed(email string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+	for _, d := range a.allowedDomains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// audienceContains handles the aud claim being either a string or a list
+func audienceContains(raw json.RawMessage, clientID string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == clientID
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) != nil {
+		return false
+	}
+	for _, aud := range list {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,335 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/payment"
+)
+
+var flagPaymentProvider = flag.String("payment-provider", "template", "how to ask for payment: template (-payment-link), paypal (PayPal.me names set on /me), tikkie or payconiq (both pay into the account of -payment-key, for -payee)")
+var flagPaymentKey = flag.String("payment-key", "", "API key of the tikkie or payconiq -payment-provider")
+var flagPaymentAppToken = flag.String("payment-app-token", "", "app token of the tikkie -payment-provider")
+var flagPayee = flag.String("payee", "", "name in the sheet of who pays the restaurant, to add what everyone owes them with a payment link to the order message")
+var flagPaymentLinks stringList
+
+func init() {
+	flag.Var(&flagPaymentLinks, "payment-link", "URL template of a payment request for -payment-provider template, with .From, .To, .Email and .Account (of .To), .Amount and .Description, e.g. https://pay.example.com/?to={{.Email | urlquery}}&amount={{.Amount.Decimal}}; prefix a language, nl=https://…, for the people who picked it on /me (repeatable)")
+}
+
+// paymentLinkLangRe matches the language in front of a -payment-link
+var paymentLinkLangRe = regexp.MustCompile(`^([a-z]{2,3}(?:-[A-Za-z]{2,4})?)=(.+)$`)
+
+// paymentProviders returns the -payment-provider by language, "" for
+// everyone else; none without payment links
+func paymentProviders() (map[string]payment.Provider, error) {
+	providers := make(map[string]payment.Provider)
+	switch *flagPaymentProvider {
+	case "template":
+		for _, value := range flagPaymentLinks {
+			lang, text := "", value
+			if m := paymentLinkLangRe.FindStringSubmatch(value); m != nil {
+				lang, text = m[1], m[2]
+			}
+			t, err := payment.NewTemplate(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -payment-link %q: %v", value, err)
+			}
+			if _, ok := providers[lang]; ok {
+				return nil, fmt.Errorf("-payment-link given twice for language %q", lang)
+			}
+			providers[lang] = t
+		}
+		return providers, nil
+	case "paypal":
+		providers[""] = payment.PayPalMe{}
+	case "tikkie":
+		if *flagPaymentKey == "" || *flagPaymentAppToken == "" {
+			return nil, errors.New("-payment-provider tikkie requires -payment-key and -payment-app-token")
+		}
+		providers[""] = &payment.Tikkie{APIKey: *flagPaymentKey, AppToken: *flagPaymentAppToken, Client: &http.Client{Timeout: paymentTimeout}}
+	case "payconiq":
+		if *flagPaymentKey == "" {
+			return nil, errors.New("-payment-provider payconiq requires -payment-key")
+		}
+		providers[""] = &payment.Payconiq{APIKey: *flagPaymentKey, Client: &http.Client{Timeout: paymentTimeout}}
+	default:
+		return nil, fmt.Errorf("invalid -payment-provider %q, expected template, paypal, tikkie or payconiq", *flagPaymentProvider)
+	}
+	if len(flagPaymentLinks) > 0 {
+		return nil, fmt.Errorf("-payment-link requires -payment-provider template")
+	}
+	return providers, nil
+}
+
+// paysIntoOwnAccount reports whether the payment provider pays whoever the
+// request is to, rather than the account of its API key
+func (c *config) paysIntoOwnAccount() bool {
+	for _, p := range c.paymentProviders {
+		if createsRequests(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// paymentTimeout bounds a call to the API of a payment provider
+const paymentTimeout = 15 * time.Second
+
+// createsRequests reports whether p creates a payment request with the
+// provider for each link, rather than just filling in a URL
+func createsRequests(p payment.Provider) bool {
+	switch p.(type) {
+	case *payment.Tikkie, *payment.Payconiq:
+		return true
+	}
+	return false
+}
+
+// madeLink is a payment link made with the provider's API
+type madeLink struct {
+	Link string    `json:"link"`
+	Made time.Time `json:"made"`
+}
+
+// paymentLinkStore keeps the links providers like Tikkie created a payment
+// request for, so each request is made once, in <data-dir>/payment-links.json
+type paymentLinkStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newPaymentLinkStore(dataDir string) *paymentLinkStore {
+	return &paymentLinkStore{path: filepath.Join(dataDir, "payment-links.json")}
+}
+
+// paymentLinkKey identifies a request to a provider in the store
+func paymentLinkKey(p payment.Provider, req payment.Request) string {
+	return strings.Join([]string{p.Name(), req.From, req.To, req.Amount.String(), req.Description}, "|")
+}
+
+func (l *paymentLinkStore) load() (map[string]madeLink, error) {
+	links := make(map[string]madeLink)
+	b, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return links, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &links); err != nil {
+		return nil, fmt.Errorf("%s: %v", l.path, err)
+	}
+	return links, nil
+}
+
+// Get returns the link made for key, "" when none was
+func (l *paymentLinkStore) Get(key string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	links, err := l.load()
+	return links[key].Link, err
+}
+
+// Add stores the link made for key, unless one was made meanwhile, and
+// returns the one to use
+func (l *paymentLinkStore) Add(key string, link madeLink) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	links, err := l.load()
+	if err != nil {
+		return "", err
+	}
+	if earlier, ok := links[key]; ok {
+		return earlier.Link, nil
+	}
+	links[key] = link
+	b, err := json.MarshalIndent(links, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return "", err
+	}
+	return link.Link, writeFileAtomic(l.path, b)
+}
+
+// Purge deletes the links made before cutoff (2006-01-02)
+func (l *paymentLinkStore) Purge(cutoff string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	links, err := l.load()
+	if err != nil || len(links) == 0 {
+		return 0, err
+	}
+	purged := 0
+	for key, link := range links {
+		if link.Made.Format(timeLayout) < cutoff {
+			delete(links, key)
+			purged++
+		}
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	b, err := json.MarshalIndent(links, "", "\t")
+	if err != nil {
+		return 0, err
+	}
+	return purged, writeFileAtomic(l.path, b)
+}
+
+// paymentLink returns a link for from to pay amount to to, in the language
+// from picked on /me, "" when there is no way to. Providers like Tikkie
+// create a payment request for a link, which only happens when create is
+// set, by the jobs sending the order message and reminders or an admin;
+// otherwise only a link made before is returned.
+func (s *Server) paymentLink(ctx context.Context, prefs map[string]preference, from, to string, amount order.Money, description string, create bool) string {
+	if amount <= 0 || (!s.cfg.paysIntoOwnAccount() && to != s.cfg.payee) {
+		return ""
+	}
+	p, ok := s.cfg.paymentProviders[prefs[from].Language]
+	if !ok {
+		if p, ok = s.cfg.paymentProviders[""]; !ok {
+			return ""
+		}
+	}
+	req := payment.Request{
+		From:        from,
+		To:          to,
+		Account:     prefs[to].Account,
+		Email:       s.cfg.emailOf(to),
+		Amount:      payment.Amount{Cents: int64(amount.Round()), Currency: order.CurrentCurrency().Code},
+		Description: description,
+	}
+	if !createsRequests(p) {
+		link, err := p.Link(ctx, req)
+		if err != nil && !errors.Is(err, payment.ErrNoAccount) {
+			slog.Error("could not make payment link", "provider", p.Name(), "from", from, "to", to, "err", err)
+		}
+		return link
+	}
+
+	key := paymentLinkKey(p, req)
+	link, err := s.paymentLinks.Get(key)
+	if err != nil {
+		slog.Error("could not read payment links", "err", err)
+		return ""
+	}
+	if link != "" || !create {
+		return link
+	}
+	link, err = p.Link(ctx, req)
+	if err != nil {
+		slog.Error("could not make payment link", "provider", p.Name(), "from", from, "to", to, "err", err)
+		return ""
+	}
+	if link, err = s.paymentLinks.Add(key, madeLink{Link: link, Made: s.cfg.now()}); err != nil {
+		slog.Error("could not store payment link", "err", err)
+	}
+	return link
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "payment-links",
+		Label: "Create this month's payment requests",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			now := s.cfg.now()
+			month := now.Format("2006-01")
+			snapshots, err := s.archive.Range(month+"-01", now.Format(timeLayout))
+			if err != nil {
+				return "", err
+			}
+			st, err := s.settlementOf(r.Context(), month, snapshots, true)
+			if err != nil {
+				return "", err
+			}
+			made := 0
+			for _, tr := range st.Transfers {
+				if tr.Link != "" {
+					made++
+				}
+			}
+			return fmt.Sprintf("%d of %d transfers have a payment link", made, len(st.Transfers)), nil
+		},
+	})
+}
+
+// payeeText is the part of the order message on what everyone with a
+// priced order in vendor owes -payee, with payment links, "" without -payee
+func (s *Server) payeeText(ctx context.Context, vendor *order.Overview) string {
+	if s.cfg.payee == "" {
+		return ""
+	}
+	prefs, err := s.preferences.All()
+	if err != nil {
+		slog.Error("could not read preferences", "err", err)
+	}
+	description := "Lunch " + s.cfg.now().Format(timeLayout)
+	items := vendor.LineItems()
+	shared := order.SharedItems(items)
+	var b strings.Builder
+	for _, li := range items {
+		if li.Name == s.cfg.payee {
+			continue
+		}
+		price, ok := li.Price()
+		if !ok {
+			price, ok = order.SharedPrice(shared, li.Name)
+		}
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %s", li.Name, price)
+		if link := s.paymentLink(ctx, prefs, li.Name, s.cfg.payee, price, description, true); link != "" {
+			b.WriteString(" " + link)
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nTo pay %s:\n%s", s.cfg.payee, b.String())
+}
+
+// owedText is the part of name's reminder on what they still owe for the
+// month's lunches as settled on its report, "" when nothing
+func (s *Server) owedText(ctx context.Context, name string) string {
+	now := s.cfg.now()
+	month := now.Format("2006-01")
+	snapshots, err := s.archive.Range(month+"-01", now.Format(timeLayout))
+	if err != nil {
+		slog.Error("could not read archive", "err", err)
+		return ""
+	}
+	st, err := s.settlementOf(ctx, month, snapshots, true)
+	if err != nil {
+		slog.Error("could not settle up", "err", err)
+		return ""
+	}
+	var b strings.Builder
+	for _, tr := range st.Transfers {
+		if tr.From != name {
+			continue
+		}
+		fmt.Fprintf(&b, "You still owe %s %s for lunch this month.", tr.To, tr.Amount)
+		if tr.Link != "" {
+			b.WriteString(" Pay: " + tr.Link)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
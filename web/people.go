@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// personOrder is what someone ordered on a given day
+type personOrder struct {
+	Date  string
+	Order string
+	Parts []order.Part
+}
+
+// personHistory is everything one person ordered according to the archive
+type personHistory struct {
+	Name string
+	// Orders are sorted newest first
+	Orders    []personOrder
+	Favorites []itemCount
+}
+
+// Last returns the most recent order, or nil when there is none
+func (h *personHistory) Last() *personOrder {
+	if len(h.Orders) == 0 {
+		return nil
+	}
+	return &h.Orders[0]
+}
+
+// historyFor collects the orders of name from snapshots sorted by date.
+// Names are matched case-insensitively.
+func historyFor(name string, snapshots []*Snapshot, topN int) *personHistory {
+	h := &personHistory{Name: name}
+	counts := make(map[string]*itemCount)
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		for _, li := range s.Overview().LineItems() {
+			if !strings.EqualFold(strings.TrimSpace(li.Name), name) {
+				continue
+			}
+			h.Name = li.Name
+			h.Orders = append(h.Orders, personOrder{Date: s.Date, Order: li.Order, Parts: li.Parts})
+
+			key := order.ItemKey(li.Order)
+			if c, ok := counts[key]; ok {
+				c.Count++
+			} else {
+				counts[key] = &itemCount{Item: li.Order, Count: 1}
+			}
+		}
+	}
+
+	for _, c := range counts {
+		h.Favorites = append(h.Favorites, *c)
+	}
+	sort.Slice(h.Favorites, func(i, j int) bool {
+		a, b := h.Favorites[i], h.Favorites[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Item < b.Item
+	})
+	if len(h.Favorites) > topN {
+		h.Favorites = h.Favorites[:topN]
+	}
+	return h
+}
+
+// archivedPeople returns everyone who ordered at least once with their
+// number of orders, sorted by name
+func archivedPeople(snapshots []*Snapshot) []itemCount {
+	counts := make(map[string]int)
+	for _, s := range snapshots {
+		for _, li := range s.Overview().LineItems() {
+			counts[li.Name]++
+		}
+	}
+	people := make([]itemCount, 0, len(counts))
+	for name, n := range counts {
+		people = append(people, itemCount{Item: name, Count: n})
+	}
+	sort.Slice(people, func(i, j int) bool { return order.LessName(people[i].Item, people[j].Item) })
+	return people
+}
+
+// handlePeople lists everyone at /people and shows one person's history at
+// /people/<name>
+func (s *Server) handlePeople(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := s.archive.Range("", "")
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/people"), "/")
+	if name == "" {
+		render(w, r, "people", map[string]interface{}{"People": archivedPeople(snapshots)})
+		return
+	}
+
+	h := historyFor(name, snapshots, 10)
+	if len(h.Orders) == 0 {
+		http.Error(w, "no orders found for "+name, http.StatusNotFound)
+		return
+	}
+	render(w, r, "person", map[string]interface{}{"History": h, "Nutrition": s.summarizeNutrition(h)})
+}
@@ -0,0 +1,280 @@
+package web
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagPickupVolunteers stringList
+
+func init() {
+	flag.Var(&flagPickupVolunteers, "pickup-volunteer", `name in the sheet who takes turns picking up lunch on days it isn't delivered, see the vendor's "pickup" (repeatable, default: everyone who ordered)`)
+}
+
+// pickupDay is how lunch gets to the office on one day, when someone changed
+// it or it was decided
+type pickupDay struct {
+	// Mode is "pickup" or "delivery" when set on the page, "" for the
+	// vendor's default
+	Mode string `json:"mode,omitempty"`
+	// Who picks up, fixed by the rotation or by volunteering
+	Who string `json:"who,omitempty"`
+	// Volunteered is whether Who stepped in instead of the rotation
+	Volunteered bool `json:"volunteered,omitempty"`
+}
+
+// pickupStore keeps the pickup days in <data-dir>/pickups.json
+type pickupStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newPickupStore(dataDir string) *pickupStore {
+	return &pickupStore{path: filepath.Join(dataDir, "pickups.json")}
+}
+
+func (p *pickupStore) load() (map[string]*pickupDay, error) {
+	all := make(map[string]*pickupDay)
+	b, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+	return all, nil
+}
+
+func (p *pickupStore) save(all map[string]*pickupDay) error {
+	b, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, b)
+}
+
+// All returns the pickup days by date (2006-01-02)
+func (p *pickupStore) All() (map[string]*pickupDay, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.load()
+}
+
+// Update changes the pickup day of date with change and stores it
+func (p *pickupStore) Update(date string, change func(day *pickupDay)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return err
+	}
+	day := all[date]
+	if day == nil {
+		day = &pickupDay{}
+	}
+	change(day)
+	if *day == (pickupDay{}) {
+		delete(all, date)
+	} else {
+		all[date] = day
+	}
+	return p.save(all)
+}
+
+// Purge deletes the pickup days before cutoff (2006-01-02)
+func (p *pickupStore) Purge(cutoff string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for date := range all {
+		if date < cutoff {
+			delete(all, date)
+			purged++
+		}
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, p.save(all)
+}
+
+// pickupEnabled reports whether anyone ever picks up lunch, so the page and
+// the summary say who does
+func (c *config) pickupEnabled() bool {
+	if len(c.pickupVolunteers) > 0 {
+		return true
+	}
+	for _, v := range c.vendors {
+		if v.Pickup {
+			return true
+		}
+	}
+	return false
+}
+
+// pickupView is how lunch gets to the office on a day
+type pickupView struct {
+	Pickup bool
+	// Who picks up, "" when nobody can
+	Who         string
+	Volunteered bool
+}
+
+func (p *pickupView) String() string {
+	switch {
+	case !p.Pickup:
+		return "Lunch is delivered today."
+	case p.Who == "":
+		return "Lunch needs picking up today, but nobody who ordered is a volunteer."
+	case p.Volunteered:
+		return p.Who + " volunteered to pick up lunch today."
+	}
+	return p.Who + " picks up lunch today."
+}
+
+// nextPickup returns whose turn it is to pick up among candidates: who
+// picked up the fewest times, and of those who did it the longest ago
+func nextPickup(days map[string]*pickupDay, candidates []string, before string) string {
+	count := make(map[string]int)
+	last := make(map[string]string)
+	for date, day := range days {
+		if day.Who == "" || date >= before {
+			continue
+		}
+		count[day.Who]++
+		if date > last[day.Who] {
+			last[day.Who] = date
+		}
+	}
+	next := ""
+	for _, name := range candidates {
+		if next == "" || count[name] < count[next] || count[name] == count[next] && last[name] < last[next] {
+			next = name
+		}
+	}
+	return next
+}
+
+// pickupOn returns how lunch gets to the office on the day of t, nil unless
+// pickupEnabled. fix stores whose turn it is, so the rotation moves on;
+// until then the turn goes to the next volunteer who ordered.
+func (s *Server) pickupOn(t time.Time, oo *order.Overview, fix bool) (*pickupView, error) {
+	if !s.cfg.pickupEnabled() {
+		return nil, nil
+	}
+	date := t.Format(timeLayout)
+	days, err := s.pickups.All()
+	if err != nil {
+		return nil, err
+	}
+	day := days[date]
+	if day == nil {
+		day = &pickupDay{}
+	}
+	view := &pickupView{Who: day.Who, Volunteered: day.Volunteered}
+	switch day.Mode {
+	case "pickup":
+		view.Pickup = true
+	case "delivery":
+		return view, nil
+	default:
+		v := s.cfg.vendorFor(t)
+		view.Pickup = v != nil && v.Pickup
+	}
+	if !view.Pickup || view.Who != "" {
+		return view, nil
+	}
+
+	var candidates []string
+	for _, li := range oo.LineItems() {
+		if len(s.cfg.pickupVolunteers) == 0 || slices.Contains(s.cfg.pickupVolunteers, li.Name) {
+			candidates = append(candidates, li.Name)
+		}
+	}
+	view.Who = nextPickup(days, candidates, date)
+	if fix && view.Who != "" {
+		err = s.pickups.Update(date, func(day *pickupDay) { day.Who = view.Who })
+	}
+	return view, err
+}
+
+// handlePickup switches today between delivery and pickup, or lets the
+// visitor pick up instead of whoever's turn it is
+func (s *Server) handlePickup(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	if !s.cfg.pickupEnabled() {
+		s.notFound(w, r)
+		return
+	}
+	oo, err := s.todaysOrders(r.Context(), requestLogger(r))
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	var change func(day *pickupDay)
+	var detail string
+	switch do := r.FormValue("do"); do {
+	case "pickup":
+		change, detail = func(day *pickupDay) { day.Mode = "pickup" }, "switched to pickup"
+	case "delivery":
+		change, detail = func(day *pickupDay) { *day = pickupDay{Mode: "delivery"} }, "switched to delivery"
+	case "volunteer":
+		me := s.claimedName(r, oo.Names)
+		if me == "" {
+			http.Error(w, "pick who you are before volunteering", http.StatusForbidden)
+			return
+		}
+		change, detail = func(day *pickupDay) { day.Mode, day.Who, day.Volunteered = "pickup", me, true }, me+" picks up"
+	default:
+		http.Error(w, fmt.Sprintf("invalid action %q", do), http.StatusBadRequest)
+		return
+	}
+	if err := s.pickups.Update(s.cfg.now().Format(timeLayout), change); err != nil {
+		requestLogger(r).Error("could not store pickup", "err", err)
+		http.Error(w, "could not store pickup", http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r, "pickup", detail)
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "pickup")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// pickupText is the line on who picks up for the summary of oo, "" when
+// nobody ever does
+func (s *Server) pickupText(oo *order.Overview, fix bool) string {
+	view, err := s.pickupOn(s.cfg.now(), oo, fix)
+	if err != nil {
+		slog.Error("could not read pickups", "err", err)
+	}
+	if view == nil {
+		return ""
+	}
+	return view.String() + "\n"
+}
@@ -0,0 +1,490 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/payment"
+)
+
+// preference is how someone wants to be reminded of the deadline
+type preference struct {
+	// Channel is "email", "slack" or "" for no personal reminder
+	Channel string `json:"channel"`
+	// Lead is how many minutes before the deadline, 0 for -reminder
+	Lead int `json:"lead_minutes,omitempty"`
+	// Language is the language to translate the reminder into, "" for none
+	Language string `json:"language,omitempty"`
+	// Calendar is the address of their calendar, whose busy all-day events
+	// are absences
+	Calendar string `json:"calendar,omitempty"`
+	// Account is where they get paid with the payment provider, like their
+	// PayPal.me name
+	Account string `json:"account,omitempty"`
+	// Standing are their orders every week by weekday, like "tue": "Poke
+	// bowl", placed each morning unless they ordered otherwise or are away
+	Standing map[string]string `json:"standing,omitempty"`
+	Updated  time.Time         `json:"updated"`
+}
+
+// reminderLeads are the lead times in minutes people can pick
+var reminderLeads = []int{10, 15, 30, 60, 120}
+
+// preferenceStore keeps the preferences by name in
+// <data-dir>/preferences.json
+type preferenceStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newPreferenceStore(dataDir string) *preferenceStore {
+	return &preferenceStore{path: filepath.Join(dataDir, "preferences.json")}
+}
+
+// All returns the preferences by name
+func (p *preferenceStore) All() (map[string]preference, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.load()
+}
+
+func (p *preferenceStore) load() (map[string]preference, error) {
+	all := make(map[string]preference)
+	b, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+	return all, nil
+}
+
+// Set stores the preference of name
+func (p *preferenceStore) Set(name string, pref preference) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return err
+	}
+	all[name] = pref
+	b, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, b)
+}
+
+// reminderChannels lists the channels personal reminders can go through:
+// email with -smtp-addr, Slack with the Slack app
+func (s *Server) reminderChannels() []string {
+	var channels []string
+	if emailNotifier() != nil {
+		channels = append(channels, "email")
+	}
+	if s.slackClient != nil {
+		channels = append(channels, "slack")
+	}
+	return channels
+}
+
+// leadOf returns how long before the deadline pref asks to be reminded
+func (c *config) leadOf(pref preference) time.Duration {
+	if pref.Lead > 0 {
+		return time.Duration(pref.Lead) * time.Minute
+	}
+	if c.reminder > 0 {
+		return c.reminder
+	}
+	return 30 * time.Minute
+}
+
+// emailOf returns the email address of name, from -addresses or else the
+// directory, "" when there is none
+func (c *config) emailOf(name string) string {
+	if list := c.addresses[name]; len(list) > 0 {
+		return list[0]
+	}
+	members, _ := people.Members()
+	for _, m := range members {
+		if m.Email != "" && c.directoryColumn([]string{name}, m, members) != "" {
+			return m.Email
+		}
+	}
+	return ""
+}
+
+// peopleNames returns the names of everyone expected to order, from the
+// sheet header and -directory
+func (s *Server) peopleNames(ctx context.Context, logger *slog.Logger) ([]string, error) {
+	rows, err := s.sheet.Rows(ctx, logger)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errSheetUnreachable, err)
+	}
+	header, err := s.cfg.layout.HeaderRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	names, _ := s.cfg.withDirectory(header, nil)
+	return order.New(names, nil).Names, nil
+}
+
+// handleMe shows the reminder preferences and absences of the visitor on /me,
+// and saves them for logged-in users
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	names, err := s.peopleNames(r.Context(), logger)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	name := s.claimedName(r, names)
+	all, err := s.preferences.All()
+	if err != nil {
+		logger.Error("could not read preferences", "err", err)
+		http.Error(w, "could not read preferences", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		// a claimed name is anyone's to pick, so a public site's /me is
+		// read-only
+		if identityFromRequest(r) == nil {
+			http.Error(w, "log in to change your preferences", http.StatusForbidden)
+			return
+		}
+		if name == "" {
+			http.Error(w, "your login matches nobody in the sheet", http.StatusForbidden)
+			return
+		}
+		msg, err := s.changeMe(r, name, all[name])
+		if err != nil {
+			msg = "Failed: " + err.Error()
+		}
+		http.Redirect(w, r, "/me?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	away, err := s.absences.Of(name)
+	if err != nil {
+		logger.Error("could not read absences", "err", err)
+		http.Error(w, "could not read absences", http.StatusInternalServerError)
+		return
+	}
+	// PayPal.me names are set here
+	_, paypal := s.cfg.paymentProviders[""].(payment.PayPalMe)
+	render(w, r, "me", map[string]interface{}{
+		"Name":       name,
+		"LoggedIn":   identityFromRequest(r) != nil,
+		"Message":    r.URL.Query().Get("msg"),
+		"Preference": all[name],
+		"Absences":   away,
+		"Today":      s.cfg.now().Format(timeLayout),
+		"Channels":   s.reminderChannels(),
+		"Leads":      reminderLeads,
+		"Default":    int(s.cfg.leadOf(preference{}) / time.Minute),
+		"Languages":  languages(),
+		"Email":      s.cfg.emailOf(name),
+		"PayPal":     paypal,
+		"Standing":   s.cfg.standingDays(all[name]),
+		"From":       s.cfg.standingTime.String(),
+	})
+}
+
+// changeMe does what name posted on /me, with their preference pref so far,
+// and returns the message for them
+func (s *Server) changeMe(r *http.Request, name string, pref preference) (string, error) {
+	switch r.FormValue("do") {
+	case "away":
+		ab, err := s.parseAbsence(r)
+		if err != nil {
+			return "", err
+		}
+		if err := s.absences.Update(name, func(list []absence) ([]absence, error) {
+			return append(list, ab), nil
+		}); err != nil {
+			return "", err
+		}
+		s.audit.Record(r, "absence/add", fmt.Sprintf("%s: %s to %s", name, ab.From, ab.To))
+		return fmt.Sprintf("Away from %s to %s", ab.From, ab.To), nil
+	case "back":
+		from := r.FormValue("from")
+		if err := s.absences.Update(name, func(list []absence) ([]absence, error) {
+			for i, ab := range list {
+				if ab.From == from && !ab.Calendar {
+					return append(list[:i], list[i+1:]...), nil
+				}
+			}
+			return nil, fmt.Errorf("no absence from %s to remove", from)
+		}); err != nil {
+			return "", err
+		}
+		s.audit.Record(r, "absence/remove", fmt.Sprintf("%s: from %s", name, from))
+		return "Removed the absence from " + from, nil
+	case "calendar":
+		pref.Calendar = strings.TrimSpace(r.FormValue("calendar"))
+		pref.Updated = s.cfg.now()
+		msg := "Your calendar is no longer synced"
+		if pref.Calendar != "" {
+			if err := validCalendarURL(pref.Calendar); err != nil {
+				return "", err
+			}
+			n, err := s.syncAbsences(r.Context(), name, pref.Calendar)
+			if err != nil {
+				// what the calendar's server answered stays in the log
+				requestLogger(r).Warn("could not read calendar", "name", name, "err", err)
+				return "", errors.New("could not read your calendar, check that the address is a public https:// or webcal:// link to an iCal file")
+			}
+			msg = fmt.Sprintf("Found %d absences in your calendar, it's synced every hour", n)
+		} else if err := s.absences.Update(name, func(list []absence) ([]absence, error) {
+			var kept []absence
+			for _, ab := range list {
+				if !ab.Calendar {
+					kept = append(kept, ab)
+				}
+			}
+			return kept, nil
+		}); err != nil {
+			return "", err
+		}
+		if err := s.preferences.Set(name, pref); err != nil {
+			return "", err
+		}
+		s.audit.Record(r, "preferences/calendar", name)
+		return msg, nil
+	case "account":
+		if identityFromRequest(r) == nil {
+			return "", errors.New("log in to set where you get paid")
+		}
+		pref.Account = strings.TrimPrefix(strings.TrimSpace(r.FormValue("account")), "paypal.me/")
+		pref.Updated = s.cfg.now()
+		if strings.ContainsAny(pref.Account, "/?# ") {
+			return "", fmt.Errorf("invalid PayPal.me name %q", pref.Account)
+		}
+		if err := s.preferences.Set(name, pref); err != nil {
+			return "", err
+		}
+		s.audit.Record(r, "preferences/account", name)
+		return "Saved", nil
+	case "standing":
+		standing, err := s.cfg.parseStanding(r.FormValue)
+		if err != nil {
+			return "", err
+		}
+		pref.Standing = standing
+		pref.Updated = s.cfg.now()
+		if err := s.preferences.Set(name, pref); err != nil {
+			return "", err
+		}
+		s.audit.Record(r, "preferences/standing", fmt.Sprintf("%s: %d days", name, len(standing)))
+		return "Saved your standing orders", nil
+	}
+
+	pref, err := s.parsePreference(r, name, pref)
+	if err != nil {
+		return "", err
+	}
+	if err := s.preferences.Set(name, pref); err != nil {
+		return "", err
+	}
+	s.audit.Record(r, "preferences", fmt.Sprintf("%s: %q %dm %q", name, pref.Channel, pref.Lead, pref.Language))
+	return "Saved", nil
+}
+
+// parseAbsence reads an absence posted on /me, a single day without "to"
+func (s *Server) parseAbsence(r *http.Request) (absence, error) {
+	ab := absence{From: r.FormValue("from"), To: r.FormValue("to"), Note: strings.TrimSpace(r.FormValue("note"))}
+	if ab.To == "" {
+		ab.To = ab.From
+	}
+	for _, date := range []string{ab.From, ab.To} {
+		if _, err := time.Parse(timeLayout, date); err != nil {
+			return ab, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	if ab.To < ab.From {
+		return ab, fmt.Errorf("%s is before %s", ab.To, ab.From)
+	}
+	if ab.To < s.cfg.now().Format(timeLayout) {
+		return ab, fmt.Errorf("%s is in the past", ab.To)
+	}
+	if utf8.RuneCountInString(ab.Note) > maxCommentLength {
+		return ab, fmt.Errorf("notes have up to %d characters", maxCommentLength)
+	}
+	return ab, nil
+}
+
+// parsePreference reads the reminder preference posted on /me for name over
+// pref
+func (s *Server) parsePreference(r *http.Request, name string, pref preference) (preference, error) {
+	pref.Channel = r.FormValue("channel")
+	pref.Language = r.FormValue("language")
+	pref.Lead = 0
+	pref.Updated = s.cfg.now()
+	if pref.Channel != "" && !slices.Contains(s.reminderChannels(), pref.Channel) {
+		return pref, fmt.Errorf("reminders can't be sent by %q", pref.Channel)
+	}
+	if pref.Channel == "email" && s.cfg.emailOf(name) == "" {
+		return pref, errors.New("there is no email address for you, ask an admin to add it to -addresses")
+	}
+	if lead := r.FormValue("lead"); lead != "" {
+		minutes, err := strconv.Atoi(lead)
+		if err != nil || !slices.Contains(reminderLeads, minutes) {
+			return pref, fmt.Errorf("invalid reminder time %q", lead)
+		}
+		pref.Lead = minutes
+	}
+	if pref.Language != "" && !slices.Contains(languages(), pref.Language) {
+		return pref, fmt.Errorf("can't translate into %q", pref.Language)
+	}
+	return pref, nil
+}
+
+// personalReminderText is the reminder for name, translated into their
+// language when -translate can
+func (s *Server) personalReminderText(ctx context.Context, name string, pref preference, deadline time.Time) string {
+	closes := "lunch orders close"
+	if vendor := s.cfg.vendorName(deadline); vendor != "" {
+		closes = fmt.Sprintf("lunch orders for %s close", vendor)
+	}
+	text := fmt.Sprintf("Hi %s, %s at %s, in %s, and you haven't ordered yet.\nSheet: %s\n",
+		name, closes, deadline.Format("15:04"), formatLeft(deadline.Sub(s.cfg.now())), s.cfg.sheetURL)
+	if pref.Language == "" || translations == nil {
+		return text
+	}
+	ctx, cancel := context.WithTimeout(ctx, translateTimeout)
+	defer cancel()
+	translated, err := translations.translator.Translate(ctx, []string{text}, pref.Language)
+	if err != nil || len(translated) != 1 {
+		slog.Error("could not translate reminder", "language", pref.Language, "err", err)
+		return text
+	}
+	return translated[0]
+}
+
+// sendPersonalReminder reminds name of deadline through their channel
+func (s *Server) sendPersonalReminder(ctx context.Context, name string, pref preference, deadline time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	// what they owe isn't translated, to keep its payment link intact
+	text := s.personalReminderText(ctx, name, pref, deadline) + s.owedText(ctx, name)
+	switch pref.Channel {
+	case "email":
+		email := emailNotifier()
+		to := s.cfg.emailOf(name)
+		if email == nil || to == "" {
+			return fmt.Errorf("no email notifier or address")
+		}
+		return email.Notify(ctx, notify.Message{
+			Subject: fmt.Sprintf("Lunch orders close at %s", deadline.Format("15:04")),
+			Text:    text,
+			To:      []string{to},
+		})
+	case "slack":
+		to := s.cfg.emailOf(name)
+		if s.slackClient == nil || to == "" {
+			return fmt.Errorf("no Slack app or email address to find the Slack user by")
+		}
+		user, err := s.slackClient.LookupByEmail(ctx, to)
+		if err != nil {
+			return err
+		}
+		return s.slackClient.PostMessage(ctx, user.ID, text)
+	}
+	return nil
+}
+
+// runPersonalReminders reminds everyone who asked for it on /me and hasn't
+// ordered yet, each their chosen time before the deadline, until ctx is
+// done
+func (s *Server) runPersonalReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// who was reminded on which date, so everyone gets one reminder a day
+	reminded := make(map[string]string)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runJob("personal reminders", func() { s.sendPersonalReminders(ctx, reminded) })
+	}
+}
+
+// sendPersonalReminders reminds everyone whose time before the deadline
+// came and who hasn't ordered or been reminded today, noting them in
+// reminded
+func (s *Server) sendPersonalReminders(ctx context.Context, reminded map[string]string) {
+	now := s.cfg.now()
+	deadline, ok := s.cfg.deadlineOn(now)
+	if !ok || !now.Before(deadline) || inMaintenance() {
+		return
+	}
+	all, err := s.preferences.All()
+	if err != nil {
+		slog.Error("could not read preferences", "err", err)
+		return
+	}
+	date := now.Format(timeLayout)
+	away, err := s.absences.Away(date)
+	if err != nil {
+		slog.Error("could not read absences", "err", err)
+		return
+	}
+	var due []string
+	for name, pref := range all {
+		if _, absent := away[name]; absent {
+			continue
+		}
+		if pref.Channel != "" && reminded[name] != date && !now.Before(deadline.Add(-s.cfg.leadOf(pref))) {
+			due = append(due, name)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	oo, err := s.ordersOn(ctx, slog.Default(), now)
+	if err != nil {
+		slog.Error("could not fetch orders for reminders", "err", err)
+		return
+	}
+	for _, name := range due {
+		reminded[name] = date
+		if oo.OrderOf(name) != "" {
+			continue
+		}
+		if err := s.sendPersonalReminder(ctx, name, all[name], deadline); err != nil {
+			slog.Error("could not send personal reminder", "name", name, "channel", all[name].Channel, "err", err)
+			continue
+		}
+		slog.Info("sent personal reminder", "name", name, "channel", all[name].Channel)
+		s.audit.Record(nil, "notify/reminder-"+all[name].Channel, name)
+	}
+}
@@ -0,0 +1,171 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// preorderDays is how far ahead orders can be placed on the page
+const preorderDays = 30
+
+// preorderDates returns the days after the one of now, up to preorderDays
+// ahead, that have a row in the sheet and the office is open, in order
+func (s *Server) preorderDates(ctx context.Context, logger *slog.Logger, now time.Time) ([]time.Time, error) {
+	rows, err := s.sheet.Rows(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	today := now.Format(timeLayout)
+	last := now.AddDate(0, 0, preorderDays).Format(timeLayout)
+	var dates []time.Time
+	for _, d := range s.cfg.layout.Dates(rows) {
+		date := d.Format(timeLayout)
+		if date <= today || date > last || closures.Reason(date) != "" {
+			continue
+		}
+		t, err := time.ParseInLocation(timeLayout, date, s.cfg.location)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, t)
+	}
+	return dates, nil
+}
+
+// preorderDay is a later day the visitor ordered for
+type preorderDay struct {
+	Date   time.Time
+	Vendor string
+	Order  string
+}
+
+// preorderView is the pre-order form on the page, with what the visitor
+// ordered for the days ahead
+type preorderView struct {
+	Me string
+	// Min and Max bound the date picker
+	Min, Max string
+	Days     []preorderDay
+}
+
+// preordersOn returns the pre-order form for the visitor on the page at t,
+// nil when they haven't picked their name or there are no days ahead
+func (s *Server) preordersOn(r *http.Request, t time.Time, names []string) (*preorderView, error) {
+	me := s.claimedName(r, names)
+	if me == "" {
+		return nil, nil
+	}
+	logger := requestLogger(r)
+	dates, err := s.preorderDates(r.Context(), logger, t)
+	if err != nil || len(dates) == 0 {
+		return nil, err
+	}
+	view := &preorderView{Me: me, Min: dates[0].Format(timeLayout), Max: dates[len(dates)-1].Format(timeLayout)}
+	for _, date := range dates {
+		oo, err := s.ordersOn(r.Context(), logger, date)
+		if err != nil {
+			return nil, err
+		}
+		if item := oo.OrderOf(me); item != "" {
+			view.Days = append(view.Days, preorderDay{Date: date, Vendor: s.cfg.vendorName(date), Order: item})
+		}
+	}
+	return view, nil
+}
+
+// handlePreorder places the visitor's order for a later day that has a row
+// in the sheet, like an order by email for that day: it's kept in
+// <data-dir>/orders rather than written into the sheet, and editing the
+// sheet afterwards takes precedence
+func (s *Server) handlePreorder(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	if inMaintenance() {
+		http.Error(w, errOrdersFrozen.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	date := r.FormValue("date")
+	t, err := time.ParseInLocation(timeLayout, date, s.cfg.location)
+	if err != nil {
+		http.Error(w, "pick a day to order for", http.StatusBadRequest)
+		return
+	}
+	dates, err := s.preorderDates(r.Context(), logger, now)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	if !slices.ContainsFunc(dates, t.Equal) {
+		http.Error(w, fmt.Sprintf("can't order for %s: only days after today up to %d days ahead with a row in the sheet, when the office is open", date, preorderDays), http.StatusBadRequest)
+		return
+	}
+	item := strings.TrimSpace(r.FormValue("order"))
+	if utf8.RuneCountInString(item) > maxCommentLength {
+		http.Error(w, fmt.Sprintf("orders have up to %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+	names, cells, err := s.sheetRow(r.Context(), logger, t)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	name := s.claimedName(r, names)
+	if name == "" {
+		http.Error(w, "pick who you are before ordering ahead", http.StatusForbidden)
+		return
+	}
+	entry := orderEntry{
+		Order: item,
+		Was:   order.New(names, cells).OrderOf(name),
+		Via:   "the page",
+		Time:  now,
+	}
+	day, err := s.entries.Load(date)
+	if err != nil {
+		logger.Error("could not read pre-orders", "err", err)
+		http.Error(w, "could not store the order", http.StatusInternalServerError)
+		return
+	}
+	prev, placed := day[name]
+	var last *orderEntry
+	if placed {
+		last = &prev
+	}
+	err = s.entries.SetIf(date, name, last, entry)
+	if errors.Is(err, errEntryChanged) {
+		day, err := s.entries.Load(date)
+		if err != nil {
+			logger.Error("could not read pre-orders", "err", err)
+			http.Error(w, "could not store the order", http.StatusInternalServerError)
+			return
+		}
+		conflict := &orderConflict{Name: name, Order: order.New(overlay(day, names, cells)).OrderOf(name)}
+		http.Error(w, conflict.notRecorded(item), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error("could not store pre-order", "err", err)
+		http.Error(w, "could not store the order", http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r, "order/preorder", fmt.Sprintf("%s for %s: %q", name, date, item))
+	if isHTMX(r) {
+		w.Header().Set("HX-Trigger", "preordered")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
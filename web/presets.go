@@ -0,0 +1,301 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// preset is a common group order, like "Friday pizza", that admins apply to
+// a day in one go
+type preset struct {
+	Name      string       `json:"name"`
+	Items     []presetItem `json:"items"`
+	Updated   time.Time    `json:"updated"`
+	UpdatedBy string       `json:"updated_by"`
+}
+
+// presetItem is an item of a preset and who gets it by default
+type presetItem struct {
+	Name  string `json:"name"`
+	Order string `json:"order"`
+}
+
+// Text writes the items one per line, "Joe: Margherita", as edited on the
+// admin page
+func (p *preset) Text() string {
+	var b strings.Builder
+	for _, item := range p.Items {
+		fmt.Fprintf(&b, "%s: %s\n", item.Name, item.Order)
+	}
+	return b.String()
+}
+
+// parsePresetItems reads the items of a preset, one "Name: order" per line
+func parsePresetItems(text string) ([]presetItem, error) {
+	var items []presetItem
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, item, ok := strings.Cut(line, ":")
+		name, item = order.NormalizeName(name), strings.TrimSpace(item)
+		if !ok || name == "" || item == "" {
+			return nil, fmt.Errorf("line %d: expected \"Name: order\", got %q", i+1, line)
+		}
+		items = append(items, presetItem{Name: name, Order: item})
+	}
+	if len(items) == 0 {
+		return nil, errors.New("a preset needs at least one \"Name: order\" line")
+	}
+	return items, nil
+}
+
+// presetStore keeps the presets by name in <data-dir>/presets.json
+type presetStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newPresetStore(dataDir string) *presetStore {
+	return &presetStore{path: filepath.Join(dataDir, "presets.json")}
+}
+
+func (p *presetStore) load() (map[string]*preset, error) {
+	all := make(map[string]*preset)
+	b, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+	return all, nil
+}
+
+func (p *presetStore) save(all map[string]*preset) error {
+	b, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, b)
+}
+
+// List returns the presets by name
+func (p *presetStore) List() ([]*preset, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*preset, 0, len(all))
+	for _, pr := range all {
+		list = append(list, pr)
+	}
+	sort.Slice(list, func(i, j int) bool { return order.LessName(list[i].Name, list[j].Name) })
+	return list, nil
+}
+
+// Get returns the preset called name, nil when there is none
+func (p *presetStore) Get(name string) (*preset, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return all[name], nil
+}
+
+// Save stores pr, replacing the preset called was, which may be its old
+// name
+func (p *presetStore) Save(was string, pr *preset) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return err
+	}
+	if pr.Name != was && all[pr.Name] != nil {
+		return fmt.Errorf("there already is a preset %q", pr.Name)
+	}
+	delete(all, was)
+	all[pr.Name] = pr
+	return p.save(all)
+}
+
+// Remove deletes the preset called name
+func (p *presetStore) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all, err := p.load()
+	if err != nil {
+		return err
+	}
+	if all[name] == nil {
+		return fmt.Errorf("no preset %q", name)
+	}
+	delete(all, name)
+	return p.save(all)
+}
+
+// applyPreset pre-fills the orders of pr on a date (2006-01-02) for everyone
+// in it who hasn't ordered yet, like orders placed by email: editing the
+// sheet afterwards takes precedence. It returns what happened for the admin.
+func (s *Server) applyPreset(r *http.Request, pr *preset, date string) (string, error) {
+	if inMaintenance() {
+		return "", errOrdersFrozen
+	}
+	now := s.cfg.now()
+	t, err := time.ParseInLocation(timeLayout, date, s.cfg.location)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	if date < now.Format(timeLayout) {
+		return "", fmt.Errorf("%s is in the past", date)
+	}
+	if deadline, ok := s.cfg.deadlineOn(t); ok && now.After(deadline) {
+		return "", fmt.Errorf("orders for %s closed at %s", date, deadline.Format("15:04"))
+	}
+	names, cells, err := s.sheetRow(r.Context(), requestLogger(r), t)
+	if errors.Is(err, sheet.ErrNoRow) {
+		return "", fmt.Errorf("the sheet has no row for %s yet", date)
+	}
+	if err != nil {
+		return "", err
+	}
+	day, err := s.entries.Load(date)
+	if err != nil {
+		return "", err
+	}
+	oo := order.New(names, cells)
+
+	var applied int
+	var skipped []string
+	for _, item := range pr.Items {
+		name := matchName(oo.Names, item.Name)
+		if name == "" {
+			skipped = append(skipped, item.Name+" (not in the sheet)")
+			continue
+		}
+		if _, placed := day[name]; placed || oo.OrderOf(name) != "" {
+			skipped = append(skipped, name+" (already ordered)")
+			continue
+		}
+		entry := orderEntry{Order: item.Order, Via: "preset " + pr.Name, Time: now}
+		// not over an order that came in since reading them
+		err := s.entries.SetIf(date, name, nil, entry)
+		if errors.Is(err, errEntryChanged) {
+			skipped = append(skipped, name+" (ordered meanwhile)")
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not store the order of %s: %v", name, err)
+		}
+		applied++
+	}
+	msg := fmt.Sprintf("Applied %s to %s: %d orders", pr.Name, date, applied)
+	if len(skipped) > 0 {
+		msg += ", skipped " + strings.Join(skipped, ", ")
+	}
+	return msg, nil
+}
+
+// handlePresets lists the presets on /admin/presets, and saves, removes and
+// applies them
+func (s *Server) handlePresets(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		msg, err := s.changePresets(r)
+		if err != nil {
+			msg = "Failed: " + err.Error()
+		}
+		s.audit.Record(r, "admin/presets", msg)
+		http.Redirect(w, r, "/admin/presets?msg="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	list, err := s.presets.List()
+	if err != nil {
+		logger.Error("could not read presets", "err", err)
+		http.Error(w, "could not read presets", http.StatusInternalServerError)
+		return
+	}
+	var editing *preset
+	if name := r.URL.Query().Get("edit"); name != "" {
+		for _, pr := range list {
+			if pr.Name == name {
+				editing = pr
+			}
+		}
+	}
+	render(w, r, "presets", map[string]interface{}{
+		"Message": r.URL.Query().Get("msg"),
+		"Presets": list,
+		"Editing": editing,
+		"Today":   s.cfg.now().Format(timeLayout),
+	})
+}
+
+// changePresets does what the admin posted on /admin/presets and returns the
+// message for them
+func (s *Server) changePresets(r *http.Request) (string, error) {
+	if name := r.FormValue("remove"); name != "" {
+		if err := s.presets.Remove(name); err != nil {
+			return "", err
+		}
+		return "Removed " + name, nil
+	}
+	if name := r.FormValue("apply"); name != "" {
+		pr, err := s.presets.Get(name)
+		if err != nil {
+			return "", err
+		}
+		if pr == nil {
+			return "", fmt.Errorf("no preset %q", name)
+		}
+		return s.applyPreset(r, pr, r.FormValue("date"))
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return "", errors.New("a preset needs a name")
+	}
+	items, err := parsePresetItems(r.FormValue("items"))
+	if err != nil {
+		return "", err
+	}
+	pr := &preset{
+		Name:      name,
+		Items:     items,
+		Updated:   s.cfg.now(),
+		UpdatedBy: identityFromRequest(r).String(),
+	}
+	if err := s.presets.Save(r.FormValue("was"), pr); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Saved %s with %d items", name, len(items)), nil
+}
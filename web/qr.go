@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+
+	"rsc.io/qr"
+)
+
+// handleQR answers a QR code of the order sheet, or of this app with
+// ?for=app, so people at the coffee machine can open it on their phone
+func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
+	target := s.cfg.sheetURL
+	if r.URL.Query().Get("for") == "app" {
+		target = s.appURL(r)
+	}
+	code, err := qr.Encode(target, qr.M)
+	if err != nil {
+		requestLogger(r).Error("could not make QR code", "url", target, "err", err)
+		http.Error(w, "could not make QR code", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	// the index page reloads its images with the order list
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(code.PNG())
+}
+
+// appURL returns the address of the index page the way the visitor reached
+// it, through the proxy with -trust-proxy
+func (s *Server) appURL(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if s.cfg.trustProxy {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+			host = h
+		}
+	}
+	return scheme + "://" + host + "/"
+}
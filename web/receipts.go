@@ -0,0 +1,201 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// maxReceiptSize limits uploaded receipts, enough for a phone photo
+const maxReceiptSize = 10 << 20
+
+// receiptTypes are the receipt formats accepted, by sniffed content type,
+// with the extension they are stored under
+var receiptTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// receiptPrefix starts the name of a day's receipt in the archive, after the
+// date: 2006-01-02.receipt.jpg next to 2006-01-02.json
+const receiptPrefix = ".receipt"
+
+// SaveReceipt stores the receipt of a date (2006-01-02) with the given
+// extension, replacing an earlier one
+func (a *archiveStore) SaveReceipt(date, ext string, b []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	old, err := a.receipt(date)
+	if err != nil {
+		return err
+	}
+	name := date + receiptPrefix + ext
+	if err := writeFileAtomic(filepath.Join(a.dir, name), b); err != nil {
+		return err
+	}
+	if old != "" && old != name {
+		return os.Remove(filepath.Join(a.dir, old))
+	}
+	return nil
+}
+
+// Receipt returns the file name of the receipt of a date, or "" when there is
+// none
+func (a *archiveStore) Receipt(date string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.receipt(date)
+}
+
+func (a *archiveStore) receipt(date string) (string, error) {
+	for _, ext := range receiptTypes {
+		name := date + receiptPrefix + ext
+		if _, err := os.Stat(filepath.Join(a.dir, name)); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// removeReceipt deletes the receipt of a date, if any; a.mu must be held
+func (a *archiveStore) removeReceipt(date string) error {
+	name, err := a.receipt(date)
+	if err != nil || name == "" {
+		return err
+	}
+	err = os.Remove(filepath.Join(a.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// receiptDay is an archived day of a monthly report, with its receipt
+type receiptDay struct {
+	Date    string
+	Vendor  string
+	Receipt bool
+	// Invoice is nil until it's entered
+	Invoice *invoice
+}
+
+// receiptDays lists the days of the snapshots, whether their receipt was
+// uploaded and their invoice
+func (s *Server) receiptDays(snapshots []*Snapshot) ([]receiptDay, error) {
+	days := make([]receiptDay, 0, len(snapshots))
+	for _, snap := range snapshots {
+		name, err := s.archive.Receipt(snap.Date)
+		if err != nil {
+			return nil, err
+		}
+		inv, err := s.archive.Invoice(snap.Date)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, receiptDay{snap.Date, s.cfg.vendorName(snap.Time()), name != "", inv})
+	}
+	return days, nil
+}
+
+// paidFor reports whether the visitor may upload the receipt of the day of
+// snap: whoever paid it according to its invoice inv, nil for none, -payee
+// or an admin
+func (s *Server) paidFor(r *http.Request, snap *Snapshot, inv *invoice) bool {
+	if s.isAdmin(r) {
+		return true
+	}
+	name := order.NameKey(s.claimedName(r, snap.Names))
+	if name == "" {
+		return false
+	}
+	if inv != nil && inv.PaidBy != "" && order.NameKey(inv.PaidBy) == name {
+		return true
+	}
+	return s.cfg.payee != "" && order.NameKey(s.cfg.payee) == name
+}
+
+// handleReceipt shows the receipt of an archived day at /receipts/2006-01-02,
+// and lets whoever paid upload it there with a POST of the "receipt" file
+func (s *Server) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	date := strings.TrimPrefix(r.URL.Path, "/receipts/")
+	if _, err := time.Parse(timeLayout, date); err != nil {
+		s.notFound(w, r)
+		return
+	}
+	snap, err := s.archive.Load(date)
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+	if snap == nil {
+		s.notFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		name, err := s.archive.Receipt(date)
+		if err != nil {
+			requestLogger(r).Error("could not read receipt", "err", err)
+			http.Error(w, "could not read receipt", http.StatusInternalServerError)
+			return
+		}
+		if name == "" {
+			s.notFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Disposition", `inline; filename="lunch-`+date+filepath.Ext(name)+`"`)
+		http.ServeFile(w, r, filepath.Join(s.archive.dir, name))
+		return
+	}
+
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	inv, err := s.archive.Invoice(date)
+	if err != nil {
+		requestLogger(r).Error("could not read invoice", "err", err)
+		http.Error(w, "could not read invoice", http.StatusInternalServerError)
+		return
+	}
+	if !s.paidFor(r, snap, inv) {
+		http.Error(w, "only whoever paid, as entered with the invoice, or an admin can upload the receipt", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxReceiptSize+1<<20)
+	f, _, err := r.FormFile("receipt")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("upload a photo or PDF of the receipt, up to %d MB", maxReceiptSize>>20), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxReceiptSize+1))
+	if err != nil || len(b) > maxReceiptSize {
+		http.Error(w, fmt.Sprintf("receipts are at most %d MB", maxReceiptSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	ext, ok := receiptTypes[http.DetectContentType(b)]
+	if !ok {
+		http.Error(w, "receipts are JPEG, PNG, WebP or PDF", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err := s.archive.SaveReceipt(date, ext, b); err != nil {
+		requestLogger(r).Error("could not store receipt", "err", err)
+		http.Error(w, "could not store receipt", http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r, "receipt", date)
+	http.Redirect(w, r, "/reports/"+date[:7], http.StatusSeeOther)
+}
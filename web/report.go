@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+)
+
+// errorEvent is a problem worth telling the maintainer about
+type errorEvent struct {
+	Time      time.Time         `json:"time"`
+	Message   string            `json:"message"`
+	Error     string            `json:"error,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// errorReporter sends error events to an external service
+type errorReporter interface {
+	Report(ctx context.Context, e errorEvent) error
+}
+
+// reporters receive every reported error
+var reporters []errorReporter
+
+const reportTimeout = 10 * time.Second
+
+func setupReporters() error {
+	reporters = nil
+	if *flagSentryDSN != "" {
+		s, err := newSentryReporter(*flagSentryDSN)
+		if err != nil {
+			return fmt.Errorf("invalid -sentry-dsn: %v", err)
+		}
+		reporters = append(reporters, s)
+	}
+	if *flagErrorWebhook != "" {
+		reporters = append(reporters, &webhookReporter{url: *flagErrorWebhook})
+	}
+	return nil
+}
+
+// reportError sends an error to the configured reporters in the background.
+// r may be nil for errors outside of a request.
+func reportError(r *http.Request, msg string, err error, extra map[string]string) {
+	if len(reporters) == 0 {
+		return
+	}
+	e := errorEvent{Time: time.Now(), Message: msg, Extra: extra}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if r != nil {
+		e.Path = r.URL.Path
+		e.RequestID, _ = r.Context().Value(requestIDKey).(string)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+		defer cancel()
+		for _, rep := range reporters {
+			if err := rep.Report(ctx, e); err != nil {
+				slog.Warn("could not report error", "reporter", fmt.Sprintf("%T", rep), "err", err)
+			}
+		}
+	}()
+}
+
+// webhookReporter posts events as JSON to a URL
+type webhookReporter struct {
+	url string
+}
+
+func (w *webhookReporter) Report(ctx context.Context, e errorEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return notify.PostJSON(ctx, w.url, body, nil)
+}
+
+// sentryReporter sends events to Sentry's store endpoint
+type sentryReporter struct {
+	endpoint string
+	key      string
+}
+
+// newSentryReporter parses a DSN like https://<key>@o1.ingest.sentry.io/<project>
+func newSentryReporter(dsn string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("missing public key")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("missing project id")
+	}
+	// projects can live under a path prefix: https://key@host/prefix/<project>
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	return &sentryReporter{
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		key:      u.User.Username(),
+	}, nil
+}
+
+func (s *sentryReporter) Report(ctx context.Context, e errorEvent) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	extra := map[string]string{}
+	for k, v := range e.Extra {
+		extra[k] = v
+	}
+	if e.Error != "" {
+		extra["error"] = e.Error
+	}
+	event := map[string]interface{}{
+		"event_id":  hex.EncodeToString(id),
+		"timestamp": e.Time.UTC().Format("2006-01-02T15:04:05"),
+		"level":     "error",
+		"logger":    "lunchweb",
+		"platform":  "go",
+		"message":   e.Message,
+		"extra":     extra,
+		"tags": map[string]string{
+			"request_id": e.RequestID,
+			"path":       e.Path,
+		},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=lunchweb/1.0, sentry_key=%s", s.key)
+	return notify.PostJSON(ctx, s.endpoint, body, map[string]string{"X-Sentry-Auth": auth})
+}
@@ -0,0 +1,253 @@
+package web
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// spendLine is the spend of one person, vendor or day
+type spendLine struct {
+	Name     string
+	Orders   int
+	Unpriced int
+	Total    order.Money
+}
+
+// reportOrder is a single order in a monthly report
+type reportOrder struct {
+	Date   string
+	Vendor string
+	Name   string
+	Order  string
+	Price  order.Money
+	Priced bool
+}
+
+// monthlyReport summarizes the spend of one month (2006-01)
+type monthlyReport struct {
+	Month    string
+	Total    order.Money
+	Orders   []reportOrder
+	Unpriced int
+	People   []spendLine
+	Vendors  []spendLine
+	// DailySpend is the priced total per day, in units of the currency
+	DailySpend []chartPoint
+	// Budget is -budget-month and DaysOver the days over -budget-day, as
+	// far as they are set
+	Budget   order.Money
+	DaysOver int
+}
+
+// BudgetLeft returns what is left of the month's budget, 0 when it's spent
+func (m *monthlyReport) BudgetLeft() order.Money {
+	return max(m.Budget-m.Total, 0)
+}
+
+// BudgetOver returns by how much the month's budget was exceeded
+func (m *monthlyReport) BudgetOver() order.Money {
+	return max(m.Total-m.Budget, 0)
+}
+
+// buildMonthlyReport adds up the prices found in the archived orders
+func (s *Server) buildMonthlyReport(month string, snapshots []*Snapshot) *monthlyReport {
+	report := &monthlyReport{Month: month, Budget: s.cfg.budgetMonth}
+	people := make(map[string]*spendLine)
+	vendorLines := make(map[string]*spendLine)
+
+	for _, snap := range snapshots {
+		vendor := s.cfg.vendorName(snap.Time())
+		var daily order.Money
+		items := snap.Overview().LineItems()
+		shared := order.SharedItems(items)
+		for _, li := range items {
+			price, ok := li.Price()
+			if !ok {
+				// "1/2 pizza" costs half of what the other half wrote
+				price, ok = order.SharedPrice(shared, li.Name)
+			}
+			report.Orders = append(report.Orders, reportOrder{
+				Date:   snap.Date,
+				Vendor: vendor,
+				Name:   li.Name,
+				Order:  li.Order,
+				Price:  price,
+				Priced: ok,
+			})
+			for _, line := range []*spendLine{
+				addSpendLine(people, li.Name),
+				addSpendLine(vendorLines, vendor),
+			} {
+				line.Orders++
+				if ok {
+					line.Total += price
+				} else {
+					line.Unpriced++
+				}
+			}
+			if ok {
+				report.Total += price
+				daily += price
+			} else {
+				report.Unpriced++
+			}
+		}
+		if s.cfg.budgetDay > 0 && daily > s.cfg.budgetDay {
+			report.DaysOver++
+		}
+		report.DailySpend = append(report.DailySpend, chartPoint{snap.Date, float64(daily) / 100, daily.String()})
+	}
+
+	report.People = sortedSpendLines(people)
+	report.Vendors = sortedSpendLines(vendorLines)
+	return report
+}
+
+func addSpendLine(lines map[string]*spendLine, name string) *spendLine {
+	if name == "" {
+		name = "(unknown)"
+	}
+	line, ok := lines[name]
+	if !ok {
+		line = &spendLine{Name: name}
+		lines[name] = line
+	}
+	return line
+}
+
+// sortedSpendLines orders lines by total spend, biggest first
+func sortedSpendLines(lines map[string]*spendLine) []spendLine {
+	sorted := make([]spendLine, 0, len(lines))
+	for _, line := range lines {
+		sorted = append(sorted, *line)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+		return order.LessName(sorted[i].Name, sorted[j].Name)
+	})
+	return sorted
+}
+
+// archivedMonths returns the months with archived orders, newest first
+func (s *Server) archivedMonths() ([]string, error) {
+	dates, err := s.archive.Dates()
+	if err != nil {
+		return nil, err
+	}
+	var months []string
+	for i := len(dates) - 1; i >= 0; i-- {
+		month := dates[i][:7]
+		if len(months) == 0 || months[len(months)-1] != month {
+			months = append(months, month)
+		}
+	}
+	return months, nil
+}
+
+// handleReports lists the months at /reports and shows a month at
+// /reports/2006-01, as CSV at /reports/2006-01.csv, or for the expense
+// system at /reports/2006-01.expenses.csv
+func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
+	month := strings.Trim(strings.TrimPrefix(r.URL.Path, "/reports"), "/")
+	if month == "" {
+		months, err := s.archivedMonths()
+		if err != nil {
+			requestLogger(r).Error("could not read archive", "err", err)
+			http.Error(w, "could not read archive", http.StatusInternalServerError)
+			return
+		}
+		render(w, r, "reports", map[string]interface{}{"Months": months})
+		return
+	}
+
+	month, asExpenses := strings.CutSuffix(month, ".expenses.csv")
+	month, asCSV := strings.CutSuffix(month, ".csv")
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		http.Error(w, "invalid month, expected YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	end := start.AddDate(0, 1, -1)
+	snapshots, err := s.archive.Range(start.Format(timeLayout), end.Format(timeLayout))
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+	if asExpenses {
+		rows, err := s.expenseRows(snapshots)
+		if err != nil {
+			requestLogger(r).Error("could not read invoices", "err", err)
+			http.Error(w, "could not read invoices", http.StatusInternalServerError)
+			return
+		}
+		s.writeExpenseCSV(w, month, rows)
+		return
+	}
+	report := s.buildMonthlyReport(month, snapshots)
+
+	if asCSV {
+		writeReportCSV(w, report)
+		return
+	}
+	days, err := s.receiptDays(snapshots)
+	if err != nil {
+		requestLogger(r).Error("could not read receipts", "err", err)
+		http.Error(w, "could not read receipts", http.StatusInternalServerError)
+		return
+	}
+	// a visit only shows the payment requests made, as making them is up to
+	// the reminders and admins
+	settlement, err := s.settlementOf(r.Context(), month, snapshots, false)
+	if err != nil {
+		requestLogger(r).Error("could not settle up", "err", err)
+		http.Error(w, "could not read invoices", http.StatusInternalServerError)
+		return
+	}
+	render(w, r, "report", map[string]interface{}{
+		"Report":     report,
+		"Days":       days,
+		"Invoices":   sumInvoices(days),
+		"Settlement": settlement,
+	})
+}
+
+// writeReportCSV exports every order of the month with its price
+func writeReportCSV(w http.ResponseWriter, report *monthlyReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="lunch-`+report.Month+`.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "vendor", "name", "order", "price"})
+	for _, o := range report.Orders {
+		price := ""
+		if o.Priced {
+			price = o.Price.Decimal()
+		}
+		cw.Write([]string{o.Date, csvCell(o.Vendor), csvCell(o.Name), csvCell(o.Order), price})
+	}
+	cw.Write([]string{"", "", "", "total (" + strconv.Itoa(report.Unpriced) + " unpriced)", report.Total.Decimal()})
+	cw.Flush()
+}
+
+// csvCell keeps a spreadsheet opening the CSV from taking cell for a formula,
+// like "=HYPERLINK(…)" typed in the sheet as an order, by putting a ' in front
+// of text starting with =, +, -, @, a tab or a carriage return. Numbers like
+// -3.50 are left alone.
+func csvCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
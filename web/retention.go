@@ -0,0 +1,185 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retention is a flag holding how long to keep personal data, like "90d",
+// "8w", "13m" or "2y". The zero value keeps data forever.
+type retention struct {
+	n    int
+	unit byte
+}
+
+func (r *retention) String() string {
+	if r.n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%c", r.n, r.unit)
+}
+
+func (r *retention) Set(value string) error {
+	if value == "" || value == "0" {
+		*r = retention{}
+		return nil
+	}
+	unit := value[len(value)-1]
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 || !strings.ContainsRune("dwmy", rune(unit)) {
+		return fmt.Errorf("expected a number followed by d, w, m or y, like 13m")
+	}
+	*r = retention{n: n, unit: unit}
+	return nil
+}
+
+// IsSet reports whether data should be purged at all
+func (r *retention) IsSet() bool {
+	return r.n > 0
+}
+
+// Cutoff returns the first day that is still retained relative to t
+func (r *retention) Cutoff(t time.Time) time.Time {
+	day := startOfDay(t)
+	switch r.unit {
+	case 'd':
+		return day.AddDate(0, 0, -r.n)
+	case 'w':
+		return day.AddDate(0, 0, -7*r.n)
+	case 'm':
+		return day.AddDate(0, -r.n, 0)
+	case 'y':
+		return day.AddDate(-r.n, 0, 0)
+	}
+	return time.Time{}
+}
+
+// retained reports whether the day (2006-01-02) falls inside -retain
+func (c *config) retained(date string) bool {
+	if !c.retain.IsSet() {
+		return true
+	}
+	return date >= c.retain.Cutoff(c.now()).Format(timeLayout)
+}
+
+// Purge deletes the snapshots, receipts and invoices of every day before cutoff
+// (2006-01-02)
+func (a *archiveStore) Purge(cutoff string) (int, error) {
+	dates, err := a.Dates()
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	purged := 0
+	for _, date := range dates {
+		if date >= cutoff {
+			break
+		}
+		if err := a.removeReceipt(date); err != nil {
+			return purged, err
+		}
+		if err := a.removeInvoice(date); err != nil {
+			return purged, err
+		}
+		if err := os.Remove(filepath.Join(a.dir, date+".json")); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeExpired applies -retain to the archive and all other data by day,
+// from the comments and the orders placed outside the sheet to the audit log
+func (s *Server) purgeExpired() (int, error) {
+	if !s.cfg.retain.IsSet() {
+		return 0, fmt.Errorf("no retention configured, set -retain")
+	}
+	cutoff := s.cfg.retain.Cutoff(s.cfg.now()).Format(timeLayout)
+	purged, err := s.archive.Purge(cutoff)
+	if purged > 0 {
+		slog.Info("purged archived orders", "before", cutoff, "days", purged)
+	}
+	if err != nil {
+		return purged, err
+	}
+	if n, err := s.comments.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged comments", "before", cutoff, "days", n)
+	}
+	if n, err := s.entries.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged orders placed outside the sheet", "before", cutoff, "days", n)
+	}
+	if n, err := s.lateOrders.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged late orders", "before", cutoff, "days", n)
+	}
+	if n, err := s.leftovers.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged leftovers", "before", cutoff, "days", n)
+	}
+	if n, err := s.deliveries.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged delivery states", "before", cutoff, "days", n)
+	}
+	if n, err := s.paymentLinks.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged payment links", "links", n)
+	}
+	if n, err := s.orderChanges.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged order changes", "before", cutoff, "days", n)
+	}
+	if n, err := s.pickups.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged pickups", "before", cutoff, "days", n)
+	}
+	if n, err := s.approvals.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged approvals", "before", cutoff, "days", n)
+	}
+	if n, err := s.absences.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged absences", "before", cutoff, "absences", n)
+	}
+	if n, err := s.audit.Purge(cutoff); err != nil {
+		return purged, err
+	} else if n > 0 {
+		slog.Info("purged audit log", "before", cutoff, "entries", n)
+	}
+	return purged, nil
+}
+
+func init() {
+	adminActions = append(adminActions, adminAction{
+		Name:  "purge",
+		Label: "Purge data older than the retention period",
+		Run: func(s *Server, r *http.Request) (string, error) {
+			purged, err := s.purgeExpired()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Purged %d archived days", purged), nil
+		},
+	})
+}
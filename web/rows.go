@@ -0,0 +1,37 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var flagFirstRow = flag.Int("first-row", 0, "index of the first row with orders (default: the row after -header)")
+var flagLastRow = flag.Int("last-row", 0, "index of the last row with orders, to skip totals and notes below (default: the last row)")
+var flagMaxRows = flag.Int("max-rows", 0, "only look at this many of the last rows with orders (0 for all)")
+
+func checkRowFlags() error {
+	first := sheetLayout().FirstDataRow()
+	if *flagFirstRow != 0 && *flagFirstRow <= *flagHeader {
+		return fmt.Errorf("-first-row %d must be below -header %d", *flagFirstRow, *flagHeader)
+	}
+	if *flagLastRow != 0 && *flagLastRow < first {
+		return fmt.Errorf("-last-row %d must not be above the first row with orders (%d)", *flagLastRow, first)
+	}
+	if *flagMaxRows < 0 {
+		return fmt.Errorf("-max-rows must be 0 or more, got %d", *flagMaxRows)
+	}
+	return nil
+}
+
+// sheetLayout is where -header, -first-row, -last-row and -max-rows say the
+// names and orders are
+func sheetLayout() sheet.Layout {
+	return sheet.Layout{
+		Header:   *flagHeader,
+		FirstRow: *flagFirstRow,
+		LastRow:  *flagLastRow,
+		MaxRows:  *flagMaxRows,
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// runJob runs one go of the background job name, turning a panic into an
+// error in the log, so a malformed row can't stop the job for the life of
+// the process; it tries again next time
+func runJob(name string, job func()) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+		stack := string(debug.Stack())
+		slog.Error("panic in background job", "job", name, "panic", err, "stack", stack)
+		reportError(nil, "panic in background job", fmt.Errorf("%v", err), map[string]string{"job": name, "stack": stack})
+	}()
+	job()
+}
+
+// runDaily calls job at the given time of day until ctx is done. When days
+// is not empty, the job only runs on those weekdays.
+func (s *Server) runDaily(ctx context.Context, name string, at timeOfDay, days []time.Weekday, job func(ctx context.Context)) {
+	for {
+		next := nextRun(s.cfg.now(), at, days)
+		slog.Debug("scheduled job", "job", name, "next", next)
+
+		timer := time.NewTimer(next.Sub(s.cfg.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		slog.Info("running scheduled job", "job", name)
+		runJob(name, func() { job(ctx) })
+	}
+}
+
+// nextRun returns the first time after t at the time of day on one of days
+func nextRun(t time.Time, at timeOfDay, days []time.Weekday) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		next := at.On(day)
+		if !next.After(t) || !onWeekday(day, days) {
+			continue
+		}
+		return next
+	}
+	// unreachable unless days is invalid; try again tomorrow
+	return at.On(t.AddDate(0, 0, 1))
+}
+
+func onWeekday(t time.Time, days []time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if t.Weekday() == d {
+			return true
+		}
+	}
+	return false
+}
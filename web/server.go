@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/datacamp/lunchweb/mqtt"
+	"github.com/datacamp/lunchweb/ocr"
+	"github.com/datacamp/lunchweb/sheet"
+	"github.com/datacamp/lunchweb/slack"
+	"github.com/datacamp/lunchweb/teams"
+	"github.com/datacamp/lunchweb/wiki"
+)
+
+// Server answers requests and runs the background jobs. Its configuration
+// never changes; its stores and the clients of the services it talks to are
+// set up once by setup, before it starts, and lock for themselves.
+type Server struct {
+	cfg *config
+	// client fetches the sheet, sheet caches what it fetched
+	client *sheet.Client
+	sheet  *sheetCache
+	// allergyTab caches -allergies-csvurl, nil without
+	allergyTab *sheetCache
+
+	audit   *auditLog
+	archive *archiveStore
+	// paymentLinks keeps the payment requests made with the provider
+	paymentLinks *paymentLinkStore
+	comments     *commentStore
+	menus        *menuStore
+	entries      *entryStore
+	links        *linkStore
+	pickups      *pickupStore
+	presets      *presetStore
+	lateOrders   *lateStore
+	preferences  *preferenceStore
+	absences     *absenceStore
+	approvals    *approvalStore
+	leftovers    *leftoverStore
+	deliveries   *deliveryStore
+	orderChanges *changeStore
+	// nutritionFacts caches the nutrients of items, nil without -nutrition
+	nutritionFacts *nutritionStore
+
+	// the clients of the services, nil when they're not set up
+	slackClient   *slack.Client
+	teamsClient   *teams.Client
+	teamsVerifier *teams.Verifier
+	mqttClient    *mqtt.Client
+	ocrReader     ocr.Reader
+	publisher     wiki.Publisher
+}
+
+// newServer returns a server reading the sheet at -csvurl, without its
+// stores and clients yet
+func newServer(cfg *config) *Server {
+	client := &sheet.Client{
+		URL:        *flagCSVURL,
+		HTTPClient: &http.Client{Timeout: *flagFetchTimeout},
+		Layout:     cfg.layout,
+	}
+	return &Server{
+		cfg:    cfg,
+		client: client,
+		sheet:  newSheetCache(client.Rows, *flagCacheTTL, *flagErrorThreshold),
+
+		allergyTab: newAllergyTab(),
+	}
+}
+
+// routes returns the handler for every page
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/claim", s.allowMethods(handleClaim, http.MethodPost))
+	mux.HandleFunc("/comments", s.allowMethods(s.handleComment, http.MethodPost))
+	mux.HandleFunc("/reactions", s.allowMethods(s.handleReaction, http.MethodPost))
+	mux.HandleFunc("/pickup", s.allowMethods(s.handlePickup, http.MethodPost))
+	mux.HandleFunc("/preorder", s.allowMethods(s.handlePreorder, http.MethodPost))
+	mux.HandleFunc("/late", s.allowMethods(s.handleLate, http.MethodPost))
+	mux.HandleFunc("/leftovers", s.allowMethods(s.handleLeftovers, http.MethodPost))
+	mux.HandleFunc("/approvals", s.allowMethods(s.handleApproval, http.MethodPost))
+	mux.HandleFunc("/me", s.allowMethods(s.handleMe, http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/admin", s.allowMethods(s.requireAdmin(s.handleAdmin), http.MethodGet))
+	mux.HandleFunc("/admin/", s.allowMethods(s.requireAdmin(s.handleAdminAction), http.MethodPost))
+	mux.HandleFunc("/admin/menus", s.allowMethods(s.requireAdmin(s.handleMenuUpload), http.MethodPost))
+	mux.HandleFunc("/admin/menus/", s.allowMethods(s.requireAdmin(s.handleMenuItems), http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/admin/audit", s.allowMethods(s.requireAdmin(s.handleAudit), http.MethodGet))
+	mux.HandleFunc("/admin/links", s.allowMethods(s.requireAdmin(s.handleLinks), http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/admin/delivery", s.allowMethods(s.requireAdmin(s.handleDelivery), http.MethodPost))
+	mux.HandleFunc("/admin/tracking", s.allowMethods(s.requireAdmin(s.handleTracking), http.MethodPost))
+	mux.HandleFunc("/admin/late", s.allowMethods(s.requireAdmin(s.handlePromote), http.MethodPost))
+	mux.HandleFunc("/admin/presets", s.allowMethods(s.requireAdmin(s.handlePresets), http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/stats", s.allowMethods(s.handleStats, http.MethodGet))
+	mux.HandleFunc("/people", s.allowMethods(s.handlePeople, http.MethodGet))
+	mux.HandleFunc("/people/", s.allowMethods(s.handlePeople, http.MethodGet))
+	mux.HandleFunc("/leaderboard", s.allowMethods(s.handleLeaderboard, http.MethodGet))
+	mux.HandleFunc("/reports", s.allowMethods(s.handleReports, http.MethodGet))
+	mux.HandleFunc("/reports/", s.allowMethods(s.handleReports, http.MethodGet))
+	mux.HandleFunc("/receipts/", s.allowMethods(s.handleReceipt, http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/invoices/", s.allowMethods(s.handleInvoice, http.MethodGet, http.MethodPost))
+	mux.HandleFunc("/menus/", s.allowMethods(s.handleMenu, http.MethodGet))
+	mux.HandleFunc("/suggest", s.allowMethods(s.handleSuggest, http.MethodGet))
+	mux.HandleFunc("/history", s.allowMethods(s.handleHistory, http.MethodGet))
+	mux.HandleFunc("/changes", s.allowMethods(s.handleChanges, http.MethodGet))
+	mux.HandleFunc("/version", s.allowMethods(handleVersion, http.MethodGet))
+	mux.HandleFunc("/tickets.pdf", s.allowMethods(s.handleTickets, http.MethodGet))
+	mux.HandleFunc("/qr.png", s.allowMethods(s.handleQR, http.MethodGet))
+	mux.HandleFunc("/announce.mp3", s.allowMethods(s.handleAnnounce, http.MethodGet))
+	mux.HandleFunc("/static/", s.allowMethods(s.handleStatic, http.MethodGet))
+	mux.Handle("/debug/", s.requireAdmin(debugHandler().ServeHTTP))
+	mux.HandleFunc("/", s.allowMethods(s.handleIndex, http.MethodGet))
+	return mux
+}
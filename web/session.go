@@ -0,0 +1,75 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sessionKey signs cookies set by the server. Without -session-key a random
+// key is used, which logs everyone out on restart.
+var sessionKey []byte
+
+func setupSessionKey() {
+	if *flagSessionKey != "" {
+		sessionKey = []byte(*flagSessionKey)
+		return
+	}
+	sessionKey = make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		fatal("could not generate session key", err)
+	}
+}
+
+// setSignedCookie stores v as JSON in a tamper-proof cookie
+func setSignedCookie(w http.ResponseWriter, r *http.Request, name string, v interface{}, maxAge time.Duration) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value + "." + signCookie(name, value),
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || *flagTrustProxy && r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// signedCookie decodes a cookie set by setSignedCookie into v. It reports
+// false when the cookie is missing or has been tampered with.
+func signedCookie(r *http.Request, name string, v interface{}) bool {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+	value, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCookie(name, value))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+// clearCookie removes a cookie from the browser
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1})
+}
+
+func signCookie(name, value string) string {
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
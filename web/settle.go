@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// balance is what someone paid for the lunches of a month and what their
+// share of them was
+type balance struct {
+	Name string
+	Paid order.Money
+	Owed order.Money
+}
+
+// Net returns what the others owe name, negative when name owes them
+func (b *balance) Net() order.Money {
+	return b.Paid - b.Owed
+}
+
+// transfer settles part of the balances: From pays To
+type transfer struct {
+	From   string
+	To     string
+	Amount order.Money
+	// Link requests the payment, "" without a -payment-provider for it
+	Link string
+}
+
+func (t *transfer) String() string {
+	return fmt.Sprintf("%s pays %s %s", t.From, t.To, t.Amount)
+}
+
+// ledger adds up who paid for the archived days with an invoice and who
+// owes what for them: each their order's price, and an even share of the
+// rest of the invoice among the orders without a price, or among everyone
+// when all have one. It returns the balances of everyone involved, by name,
+// and how many days they cover. Names match like everywhere else, so "joe"
+// paying counts for "Joe" ordering; the balance has the first spelling seen.
+func (s *Server) ledger(snapshots []*Snapshot) ([]*balance, int, error) {
+	byName := make(map[string]*balance)
+	get := func(name string) *balance {
+		key := order.NameKey(name)
+		b := byName[key]
+		if b == nil {
+			b = &balance{Name: name}
+			byName[key] = b
+		}
+		return b
+	}
+	days := 0
+	for _, snap := range snapshots {
+		inv, err := s.archive.Invoice(snap.Date)
+		if err != nil {
+			return nil, 0, err
+		}
+		if inv == nil || inv.PaidBy == "" {
+			continue
+		}
+		items := snap.Overview().LineItems()
+		if len(items) == 0 {
+			continue
+		}
+		days++
+		get(inv.PaidBy).Paid += inv.Total
+		shared := order.SharedItems(items)
+		shares := make([]order.Money, len(items))
+		rest := inv.Total
+		var unpriced []int
+		for i, li := range items {
+			price, ok := li.Price()
+			if !ok {
+				price, ok = order.SharedPrice(shared, li.Name)
+			}
+			if ok {
+				shares[i] = price
+				rest -= price
+			} else {
+				unpriced = append(unpriced, i)
+			}
+		}
+		// what the prices leave is what the unpriced orders cost, or else
+		// what everyone adds, like delivery
+		if len(unpriced) == 0 {
+			for i := range items {
+				unpriced = append(unpriced, i)
+			}
+		}
+		for i, amount := range splitEvenly(rest, len(unpriced)) {
+			shares[unpriced[i]] += amount
+		}
+		for i, li := range items {
+			get(li.Name).Owed += shares[i]
+		}
+	}
+	balances := make([]*balance, 0, len(byName))
+	for _, b := range byName {
+		balances = append(balances, b)
+	}
+	sort.Slice(balances, func(i, j int) bool { return order.LessName(balances[i].Name, balances[j].Name) })
+	return balances, days, nil
+}
+
+// splitEvenly splits amount into n shares that differ by a cent at most, the
+// bigger ones first
+func splitEvenly(amount order.Money, n int) []order.Money {
+	shares := make([]order.Money, n)
+	each, extra := amount/order.Money(n), amount%order.Money(n)
+	for i := range shares {
+		shares[i] = each
+		switch {
+		case order.Money(i) < extra:
+			shares[i]++
+		case order.Money(i) < -extra:
+			shares[i]--
+		}
+	}
+	return shares
+}
+
+// settle returns transfers that even out the balances: whoever owes the most
+// pays whoever is owed the most, as much as either allows, until everyone is
+// even. That takes at most one transfer less than there are people.
+func settle(balances []*balance) []*transfer {
+	type side struct {
+		name   string
+		amount order.Money
+	}
+	var debtors, creditors []*side
+	for _, b := range balances {
+		switch net := b.Net(); {
+		case net < 0:
+			debtors = append(debtors, &side{b.Name, -net})
+		case net > 0:
+			creditors = append(creditors, &side{b.Name, net})
+		}
+	}
+	largest := func(list []*side) {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].amount > list[j].amount })
+	}
+	var transfers []*transfer
+	for len(debtors) > 0 && len(creditors) > 0 {
+		largest(debtors)
+		largest(creditors)
+		from, to := debtors[0], creditors[0]
+		amount := min(from.amount, to.amount)
+		transfers = append(transfers, &transfer{From: from.name, To: to.name, Amount: amount})
+		from.amount -= amount
+		to.amount -= amount
+		if from.amount == 0 {
+			debtors = debtors[1:]
+		}
+		if to.amount == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return transfers
+}
+
+// settlement is how to settle the lunches of a month
+type settlement struct {
+	Balances  []*balance
+	Transfers []*transfer
+	// Days is how many days have an invoice with who paid it
+	Days int
+}
+
+// settlementOf settles the lunches of a month (2006-01) in snapshots, with
+// payment links in the language each payer picked on /me, creating payment
+// requests with the provider for create (see paymentLink)
+func (s *Server) settlementOf(ctx context.Context, month string, snapshots []*Snapshot, create bool) (*settlement, error) {
+	balances, days, err := s.ledger(snapshots)
+	if err != nil {
+		return nil, err
+	}
+	st := &settlement{Balances: balances, Transfers: settle(balances), Days: days}
+	if len(s.cfg.paymentProviders) == 0 || len(st.Transfers) == 0 {
+		return st, nil
+	}
+	prefs, err := s.preferences.All()
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range st.Transfers {
+		tr.Link = s.paymentLink(ctx, prefs, tr.From, tr.To, tr.Amount, "Lunch "+month, create)
+	}
+	return st, nil
+}
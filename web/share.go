@@ -0,0 +1,20 @@
+package web
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// shareText is the summary of the orders on the day of t for pasting into a
+// chat, e.g. when someone calls the restaurant from a Signal group
+func (c *config) shareText(t time.Time, oo *order.Overview) string {
+	return c.mailSubjectOn(t) + "\n\n" + c.summary(oo)
+}
+
+// whatsappURL returns a link opening WhatsApp with text filled in, to pick
+// the chat to send it to
+func whatsappURL(text string) template.URL {
+	return template.URL("https://wa.me/?text=" + mailtoEscape(text))
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	sheetFetches     = expvar.NewInt("sheet_fetches")
+	sheetFetchErrors = expvar.NewInt("sheet_fetch_errors")
+)
+
+// sheetCache keeps the last fetched copy of the sheet around for -cache-ttl so
+// page views don't all hit Google Sheets
+type sheetCache struct {
+	ttl time.Duration
+	// errorThreshold is the number of failed fetches in a row to report
+	errorThreshold int
+	// source reads the sheet, with the sheet client unless -demo is on
+	source func(ctx context.Context) ([][]string, error)
+
+	mu            sync.Mutex
+	rows          [][]string
+	fetchedAt     time.Time
+	fetchDuration time.Duration
+	lastErr       error
+	lastErrAt     time.Time
+	// failures counts consecutive failed fetches
+	failures int
+}
+
+// sheetStatus describes the state of the cache for the admin page
+type sheetStatus struct {
+	FetchedAt     time.Time
+	FetchDuration time.Duration
+	Rows          int
+	LastError     error
+	LastErrorAt   time.Time
+}
+
+// Stale reports whether the last fetch failed, so the rows are older than
+// they should be
+func (s sheetStatus) Stale() bool {
+	return s.LastError != nil && s.LastErrorAt.After(s.FetchedAt) && !s.FetchedAt.IsZero()
+}
+
+func newSheetCache(source func(ctx context.Context) ([][]string, error), ttl time.Duration, errorThreshold int) *sheetCache {
+	return &sheetCache{ttl: ttl, errorThreshold: errorThreshold, source: source}
+}
+
+// Rows returns the cached rows, fetching the sheet when the cache is empty or
+// older than the TTL. When fetching fails the last good rows are returned
+// instead, see sheetStatus.Stale; a failed fetch is retried after the TTL.
+func (c *sheetCache) Rows(ctx context.Context, logger *slog.Logger) ([][]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rows != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.rows, nil
+	}
+	if c.rows != nil && c.lastErrAt.After(c.fetchedAt) && time.Since(c.lastErrAt) < c.ttl {
+		return c.rows, nil
+	}
+	rows, err := c.fetch(ctx, logger)
+	if err != nil && c.rows != nil {
+		logger.Warn("serving stale sheet", "fetched_at", c.fetchedAt, "err", err)
+		return c.rows, nil
+	}
+	return rows, err
+}
+
+// Refresh fetches the sheet regardless of the cache age
+func (c *sheetCache) Refresh(ctx context.Context, logger *slog.Logger) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.fetch(ctx, logger)
+	return err
+}
+
+// Status returns a snapshot of the cache state
+func (c *sheetCache) Status() sheetStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return sheetStatus{
+		FetchedAt:     c.fetchedAt,
+		FetchDuration: c.fetchDuration,
+		Rows:          len(c.rows),
+		LastError:     c.lastErr,
+		LastErrorAt:   c.lastErrAt,
+	}
+}
+
+// fetch downloads the sheet; c.mu must be held
+func (c *sheetCache) fetch(ctx context.Context, logger *slog.Logger) ([][]string, error) {
+	start := time.Now()
+	rows, err := c.source(ctx)
+	duration := time.Since(start)
+	sheetFetches.Add(1)
+	if err != nil {
+		sheetFetchErrors.Add(1)
+		c.lastErr = err
+		c.lastErrAt = time.Now()
+		c.failures++
+		logger.Error("could not fetch sheet", "fetch_duration", duration, "failures", c.failures, "err", err)
+		if c.failures == c.errorThreshold {
+			reportError(nil, "fetching the sheet keeps failing", err, map[string]string{
+				"failures": strconv.Itoa(c.failures),
+			})
+		}
+		return nil, err
+	}
+	c.failures = 0
+	logger.Debug("fetched sheet", "fetch_duration", duration, "rows", len(rows))
+
+	c.rows = rows
+	c.fetchedAt = time.Now()
+	c.fetchDuration = duration
+	return rows, nil
+}
@@ -0,0 +1,322 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/slack"
+)
+
+var flagSlackSigningSecret = flag.String("slack-signing-secret", "", "signing secret of a Slack app whose interactivity and slash command URL is /hooks/slack, to order in a Slack modal (requires -slack-bot-token)")
+var flagSlackBotToken = flag.String("slack-bot-token", "", "bot token of the Slack app, with the users:read and users:read.email scopes, and chat:write for reminders by direct message")
+
+// slackOrderView is the callback_id of the order modal
+const slackOrderView = "lunchweb_order"
+
+// newSlackClient returns the client of the Slack app's API, nil without
+// -slack-signing-secret
+func newSlackClient() (*slack.Client, error) {
+	if *flagSlackSigningSecret == "" && *flagSlackBotToken == "" {
+		return nil, nil
+	}
+	if *flagSlackSigningSecret == "" || *flagSlackBotToken == "" {
+		return nil, fmt.Errorf("-slack-signing-secret and -slack-bot-token go together")
+	}
+	return &slack.Client{Token: *flagSlackBotToken, Client: &http.Client{Timeout: 5 * time.Second}}, nil
+}
+
+// slackButton is the label of the order button under Slack notifications,
+// "" when Slack can't open the modal
+func slackButton() string {
+	if *flagSlackSigningSecret == "" {
+		return ""
+	}
+	return "Place my order"
+}
+
+// slackPayload is the part of a Slack interaction lunchweb reads
+type slackPayload struct {
+	Type      string `json:"type"`
+	TriggerID string `json:"trigger_id"`
+	User      struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+	} `json:"actions"`
+	View struct {
+		CallbackID      string `json:"callback_id"`
+		PrivateMetadata string `json:"private_metadata"`
+		State           struct {
+			Values map[string]map[string]struct {
+				Value          string `json:"value"`
+				SelectedOption *struct {
+					Value string `json:"value"`
+				} `json:"selected_option"`
+			} `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+// handleSlack answers the Slack app: the /lunch slash command and the order
+// button open the order modal, submitting it places the order
+func (s *Server) handleSlack(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read the request", http.StatusBadRequest)
+		return
+	}
+	// Slack signs with the real time, not -fake-now
+	if err := slack.Verify(s.cfg.slackSigningSecret, r.Header, body, time.Now()); err != nil {
+		logger.Warn("Slack hook called without a valid signature", "err", err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "could not read the request", http.StatusBadRequest)
+		return
+	}
+
+	var p slackPayload
+	if form.Has("command") {
+		p.Type = "command"
+		p.TriggerID = form.Get("trigger_id")
+		p.User.ID = form.Get("user_id")
+	} else if err := json.Unmarshal([]byte(form.Get("payload")), &p); err != nil {
+		http.Error(w, "could not read the payload", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case p.Type == "command" || p.Type == "block_actions" && len(p.Actions) > 0 && p.Actions[0].ActionID == notify.SlackOrderAction:
+		view, err := s.slackOrderModal(r, p.User.ID)
+		if err == nil {
+			err = s.slackClient.OpenView(r.Context(), p.TriggerID, view)
+		}
+		if err != nil {
+			logger.Error("could not open Slack order modal", "user", p.User.ID, "err", err)
+			if p.Type == "command" {
+				// shown to the user only
+				fmt.Fprintf(w, "Sorry, I couldn't open the order form: %v", err)
+			}
+		}
+	case p.Type == "view_submission" && p.View.CallbackID == slackOrderView:
+		s.submitSlackOrder(w, r, &p)
+	default:
+		// other interactions of the app, nothing to do
+	}
+}
+
+// slackOrderModal is the order form: today's menu to pick from and a text
+// field for anything else, filled in with the current order
+func (s *Server) slackOrderModal(r *http.Request, userID string) (map[string]interface{}, error) {
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	oo, err := s.ordersOn(r.Context(), logger, now)
+	if err != nil {
+		return nil, err
+	}
+	name, err := s.slackName(r, userID, oo.Names)
+	if err != nil {
+		return nil, err
+	}
+
+	intro := fmt.Sprintf("Ordering lunch as *%s*", name)
+	if vendor := s.cfg.vendorName(now); vendor != "" {
+		intro += fmt.Sprintf(" from *%s*", vendor)
+	}
+	if deadline, ok := s.cfg.deadlineOn(now); ok {
+		intro += fmt.Sprintf(", until %s", deadline.Format("15:04"))
+	}
+	intro += fmt.Sprintf(". %d out of %d ordered so far.", len(oo.LineItems()), oo.MaxCount())
+	blocks := []interface{}{slackSection(intro)}
+
+	var options []interface{}
+	allergies := s.allergies(r.Context(), logger)
+	for _, item := range s.menuItems(logger, s.cfg.vendorFor(now)) {
+		// Slack's limits: 100 options of 75 characters
+		if len(options) == 100 {
+			break
+		}
+		options = append(options, map[string]interface{}{
+			"text":  slackText(truncate(menuItemText(item, allergies, name), 75)),
+			"value": truncate(item.Name, 150),
+		})
+	}
+	if len(options) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "input",
+			"block_id": "menu",
+			"optional": true,
+			"label":    slackText("Pick from today's menu"),
+			"element":  map[string]interface{}{"type": "static_select", "action_id": "item", "options": options},
+		})
+	}
+	input := map[string]interface{}{"type": "plain_text_input", "action_id": "order", "max_length": 200}
+	if current := oo.OrderOf(name); current != "" {
+		input["initial_value"] = current
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "input",
+		"block_id": "text",
+		"optional": true,
+		"label":    slackText("Or write your order"),
+		"hint":     slackText(`"none" takes your order back`),
+		"element":  input,
+	})
+
+	metadata, err := json.Marshal(slackOrderMetadata{Name: name, Current: oo.OrderOf(name)})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":             "modal",
+		"callback_id":      slackOrderView,
+		"private_metadata": string(metadata),
+		"title":            slackText("Lunch order"),
+		"submit":           slackText("Order"),
+		"close":            slackText("Cancel"),
+		"blocks":           blocks,
+	}, nil
+}
+
+// slackOrderMetadata is what the modal remembers between opening and
+// submitting it
+type slackOrderMetadata struct {
+	Name string `json:"name"`
+	// Current is the order the text field started with
+	Current string `json:"current"`
+}
+
+// submitSlackOrder places the order submitted in the modal and shows what
+// was recorded in it
+func (s *Server) submitSlackOrder(w http.ResponseWriter, r *http.Request, p *slackPayload) {
+	var metadata slackOrderMetadata
+	if err := json.Unmarshal([]byte(p.View.PrivateMetadata), &metadata); err != nil || metadata.Name == "" {
+		http.Error(w, "invalid modal", http.StatusBadRequest)
+		return
+	}
+	name := metadata.Name
+	values := p.View.State.Values
+	item := strings.TrimSpace(values["text"]["order"].Value)
+	// a pick from the menu wins over the text still showing the old order
+	if selected := values["menu"]["item"].SelectedOption; selected != nil && (item == "" || item == metadata.Current) {
+		item = selected.Value
+	}
+	if item == "" {
+		writeSlackResponse(w, map[string]interface{}{
+			"response_action": "errors",
+			"errors":          map[string]string{"text": "Pick something from the menu or write your order"},
+		})
+		return
+	}
+	if cancelWords[strings.ToLower(item)] {
+		item = ""
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), identityKey, &Identity{Name: name, Subject: "slack:" + p.User.ID}))
+	reply, err := s.placeOrderOver(r, name, item, "Slack", &metadata.Current)
+	var conflict *orderConflict
+	if errors.As(err, &conflict) {
+		// show the form again as it is now, so they order knowing what
+		// came in meanwhile
+		view, err := s.slackOrderModal(r, p.User.ID)
+		if err != nil {
+			requestLogger(r).Error("could not show Slack order form", "err", err)
+			writeSlackResponse(w, map[string]interface{}{
+				"response_action": "errors",
+				"errors":          map[string]string{"text": fmt.Sprintf("Sorry, %v, open the form again to order", conflict)},
+			})
+			return
+		}
+		warning := slackSection(":warning: " + conflict.notRecorded(item))
+		view["blocks"] = append([]interface{}{warning}, view["blocks"].([]interface{})...)
+		writeSlackResponse(w, map[string]interface{}{"response_action": "update", "view": view})
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, errOrdersFrozen) {
+			requestLogger(r).Error("could not take order from Slack", "err", err)
+		}
+		writeSlackResponse(w, map[string]interface{}{
+			"response_action": "errors",
+			"errors":          map[string]string{"text": fmt.Sprintf("Sorry, I couldn't record your order: %v", err)},
+		})
+		return
+	}
+	writeSlackResponse(w, map[string]interface{}{
+		"response_action": "update",
+		"view": map[string]interface{}{
+			"type":   "modal",
+			"title":  slackText("Lunch order"),
+			"close":  slackText("Done"),
+			"blocks": []interface{}{slackSection(reply)},
+		},
+	})
+}
+
+// slackName returns the sheet name of a Slack user: the one of their email
+// address in -addresses, or else the one matching their name or the start
+// of their email address, like a login does
+func (s *Server) slackName(r *http.Request, userID string, names []string) (string, error) {
+	user, err := s.slackClient.UserInfo(r.Context(), userID)
+	if err != nil {
+		return "", err
+	}
+	if name := s.cfg.nameOf(user.Profile.Email); name != "" {
+		return name, nil
+	}
+	local, _, _ := strings.Cut(user.Profile.Email, "@")
+	if name := matchName(names, user.RealName, user.Profile.DisplayName, local); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("no column in the sheet matches your Slack name %q, ask the office to add your email address to lunchweb", user.RealName)
+}
+
+// matchName returns the first of names matching one of candidates, or ""
+func matchName(names []string, candidates ...string) string {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		for _, candidate := range candidates {
+			if candidate != "" && order.NameKey(name) == order.NameKey(candidate) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func slackText(text string) map[string]string {
+	return map[string]string{"type": "plain_text", "text": text}
+}
+
+func slackSection(markdown string) map[string]interface{} {
+	return map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": markdown}}
+}
+
+func writeSlackResponse(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// truncate shortens s to at most n bytes, ending in "…" when cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n-len("…")], "") + "…"
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var flagTwilioAuthToken = flag.String("twilio-auth-token", "", "take orders texted to a Twilio number whose messaging webhook is /hooks/sms, checking Twilio's signature with this auth token (default: off)")
+var flagPhones = flag.String("phones", "", `JSON file with everyone's phone numbers by sheet name, like {"Joe": ["+32470123456"]}, to take orders by SMS from`)
+
+// loadPhones reads the -phones file
+func loadPhones(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var phones map[string][]string
+	if err := json.Unmarshal(b, &phones); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, list := range phones {
+		for _, p := range list {
+			if !strings.HasPrefix(normalizePhone(p), "+") {
+				return nil, fmt.Errorf("%s: %s: phone number %q must start with + and the country code", path, name, p)
+			}
+		}
+	}
+	return phones, nil
+}
+
+// normalizePhone drops the spaces, dashes and parentheses people write
+// phone numbers with, as Twilio sends them without
+func normalizePhone(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '+' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+}
+
+// nameOfPhone returns the sheet name of whoever has the phone number, or ""
+func (c *config) nameOfPhone(number string) string {
+	number = normalizePhone(number)
+	for name, list := range c.phones {
+		for _, p := range list {
+			if normalizePhone(p) == number {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// twilioSignature is how Twilio signs a webhook request to url with form
+// params: HMAC-SHA1 of the URL followed by every param's name and value,
+// sorted by name
+func twilioSignature(authToken, url string, params map[string][]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(url))
+	for _, name := range names {
+		for _, value := range params[name] {
+			mac.Write([]byte(name + value))
+		}
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseOrderSMS returns the order in a text message, which is the whole
+// message, an "order:" in front optional. The order is "" for "none".
+func parseOrderSMS(body string) (string, bool) {
+	text := strings.Join(strings.Fields(body), " ")
+	if m := orderLineRe.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	if cancelWords[strings.ToLower(text)] {
+		return "", true
+	}
+	return text, text != ""
+}
+
+// handleSMS records the order texted to the Twilio number as the order of
+// the sender, who must be in -phones, and answers through Twilio
+func (s *Server) handleSMS(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "could not read the message", http.StatusBadRequest)
+		return
+	}
+	url := strings.TrimSuffix(s.appURL(r), "/") + r.URL.RequestURI()
+	want := twilioSignature(s.cfg.twilioAuthToken, url, r.PostForm)
+	if !hmac.Equal([]byte(r.Header.Get("X-Twilio-Signature")), []byte(want)) {
+		logger.Warn("SMS hook called without a valid Twilio signature", "url", url)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	from := r.PostForm.Get("From")
+	name := s.cfg.nameOfPhone(from)
+	if name == "" {
+		logger.Warn("order SMS from unknown number", "from", from)
+		writeTwiML(w, "Sorry, I don't know your number. Ask the office to add it to lunchweb.")
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), identityKey, &Identity{Name: name, Subject: from}))
+
+	item, ok := parseOrderSMS(r.PostForm.Get("Body"))
+	if !ok {
+		writeTwiML(w, `Text your order, like "BLT no mayo", or "none" to take it back.`)
+		return
+	}
+	reply, err := s.placeOrder(r, name, item, "SMS from "+from)
+	var conflict *orderConflict
+	switch {
+	case errors.As(err, &conflict):
+		reply = conflict.notRecorded(item)
+	case errors.Is(err, errOrdersFrozen):
+		reply = "Sorry, " + err.Error() + ", please use the sheet."
+	case err != nil:
+		// Twilio doesn't retry, so tell the sender
+		logger.Error("could not take order from SMS", "err", err)
+		reply = "Sorry, something went wrong and I couldn't record your order. Please use the sheet."
+	}
+	writeTwiML(w, reply)
+}
+
+// writeTwiML answers a Twilio webhook with a text message back
+func writeTwiML(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header+"<Response><Message>")
+	xml.EscapeText(w, []byte(strings.TrimSpace(text)))
+	fmt.Fprint(w, "</Message></Response>\n")
+}
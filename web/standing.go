@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+var flagStandingTime = timeOfDay(9 * time.Hour)
+
+func init() {
+	flag.Var(&flagStandingTime, "standing-time", "time of day from which standing orders set on /me are placed, once today's row is in the sheet")
+}
+
+// weekdayKey is how a weekday is written in standing orders, "tue"
+func weekdayKey(wd time.Weekday) string {
+	return strings.ToLower(wd.String()[:3])
+}
+
+// orderingWeekdays returns the weekdays lunch is ordered on: the days of
+// the -vendors, or else Monday to Friday
+func (c *config) orderingWeekdays() []time.Weekday {
+	days := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if len(c.vendors) == 0 {
+		return days
+	}
+	days = nil
+	for _, wd := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		for _, v := range c.vendors {
+			if slices.Contains(v.weekdays, wd) && !slices.Contains(days, wd) {
+				days = append(days, wd)
+			}
+		}
+	}
+	return days
+}
+
+// standingDay is a weekday with the visitor's standing order on /me
+type standingDay struct {
+	Key   string
+	Day   time.Weekday
+	Order string
+}
+
+// standingDays returns the ordering weekdays with the standing orders of pref
+func (c *config) standingDays(pref preference) []standingDay {
+	var days []standingDay
+	for _, wd := range c.orderingWeekdays() {
+		days = append(days, standingDay{weekdayKey(wd), wd, pref.Standing[weekdayKey(wd)]})
+	}
+	return days
+}
+
+// parseStanding reads the standing orders posted on /me
+func (c *config) parseStanding(form func(key string) string) (map[string]string, error) {
+	standing := make(map[string]string)
+	for _, wd := range c.orderingWeekdays() {
+		item := strings.TrimSpace(form("standing-" + weekdayKey(wd)))
+		if utf8.RuneCountInString(item) > maxCommentLength {
+			return nil, fmt.Errorf("orders have up to %d characters", maxCommentLength)
+		}
+		if item != "" {
+			standing[weekdayKey(wd)] = item
+		}
+	}
+	if len(standing) == 0 {
+		return nil, nil
+	}
+	return standing, nil
+}
+
+// placeStandingOrders places today's standing orders like orders by email,
+// for everyone who has one for the weekday, isn't away and hasn't ordered
+// otherwise, in the sheet or outside it. It returns sheet.ErrNoRow while
+// today's row isn't there yet. Like those, they're not written into the
+// sheet.
+func (s *Server) placeStandingOrders(ctx context.Context, now time.Time) (int, error) {
+	if inMaintenance() || !s.cfg.isOrderingDay(now) {
+		return 0, nil
+	}
+	date := now.Format(timeLayout)
+	all, err := s.preferences.All()
+	if err != nil {
+		return 0, err
+	}
+	names, cells, err := s.sheetRow(ctx, slog.Default(), now)
+	if err != nil {
+		return 0, err
+	}
+	names, cells = s.cfg.withDirectory(names, cells)
+	oo := order.New(names, cells)
+	day, err := s.entries.Load(date)
+	if err != nil {
+		return 0, err
+	}
+	away, err := s.absences.Away(date)
+	if err != nil {
+		return 0, err
+	}
+	placed := 0
+	for _, name := range slices.Sorted(maps.Keys(all)) {
+		item := all[name].Standing[weekdayKey(now.Weekday())]
+		if item == "" || !slices.Contains(oo.Names, name) {
+			continue
+		}
+		if _, ok := away[name]; ok {
+			continue
+		}
+		// an order by email or on the page, also an empty one, or in the
+		// sheet overrides the standing order
+		if _, ok := day[name]; ok || oo.OrderOf(name) != "" {
+			continue
+		}
+		entry := orderEntry{Order: item, Via: "standing order", Time: now}
+		// not over an order that came in since reading them
+		err := s.entries.SetIf(date, name, nil, entry)
+		if errors.Is(err, errEntryChanged) {
+			continue
+		}
+		if err != nil {
+			return placed, fmt.Errorf("could not store the order of %s: %v", name, err)
+		}
+		s.audit.Record(nil, "order/standing", fmt.Sprintf("%s: %q", name, item))
+		placed++
+	}
+	return placed, nil
+}
+
+// runStandingOrders places the standing orders every interval from
+// -standing-time until the deadline, once a day, until ctx is done. It only
+// runs behind a login.
+func (s *Server) runStandingOrders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var done string
+	for {
+		now := s.cfg.now()
+		date := now.Format(timeLayout)
+		deadline, ok := s.cfg.deadlineOn(now)
+		if date != done && !now.Before(s.cfg.standingTime.On(now)) && (!ok || now.Before(deadline)) {
+			runJob("standing orders", func() {
+				n, err := s.placeStandingOrders(ctx, now)
+				switch {
+				case errors.Is(err, sheet.ErrNoRow):
+					slog.Debug("no row for standing orders yet")
+				case err != nil:
+					slog.Error("could not place standing orders", "err", err)
+				default:
+					if n > 0 {
+						slog.Info("placed standing orders", "orders", n)
+					}
+					done = date
+				}
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var flagRenderOut *string
+
+func renderFlags(fs *flag.FlagSet) {
+	flagRenderOut = fs.String("out", "index.html", "file to write the page to, - for stdout")
+}
+
+// runRender writes today's page as a self-contained HTML file that can be
+// hosted without lunchweb serve
+func (s *Server) runRender(ctx context.Context, args []string) error {
+	if err := parseTemplates(); err != nil {
+		return fmt.Errorf("could not parse templates: %v", err)
+	}
+	oo, err := s.todaysOrders(ctx, slog.Default())
+	if err != nil {
+		return err
+	}
+
+	now := s.cfg.now()
+	vendor, _, err := s.vendorOrders(now.Format(timeLayout), oo)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	err = templates.ExecuteTemplate(&b, "index", map[string]interface{}{
+		"Now":         now.Format(time.RFC1123Z),
+		"Today":       now.Format(timeLayout),
+		"Mailto":      mailtoURL(s.cfg.orderRecipients(now), s.cfg.mailSubjectOn(now), s.cfg.summary(vendor)),
+		"SheetURL":    s.cfg.sheetURL,
+		"Order":       oo,
+		"Maintenance": s.cfg.maintenanceBanner(),
+		"Leaderboard": s.indexLeaderboard(nil),
+		"Nutrition":   s.nutritionNotes(oo),
+		"Static":      true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not render page: %w", err)
+	}
+
+	if *flagRenderOut == "-" {
+		_, err := os.Stdout.Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(*flagRenderOut, b.Bytes()); err != nil {
+		return err
+	}
+	slog.Info("rendered page", "out", *flagRenderOut, "orders", len(oo.LineItems()))
+	return nil
+}
+
+// writeFileAtomic writes through a temporary file so a web server never
+// serves a half written page
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+var flagSiteOut *string
+
+func siteFlags(fs *flag.FlagSet) {
+	flagSiteOut = fs.String("out", "site", "directory to write the site to")
+}
+
+// siteMonth groups the archived days of a month on the site index
+type siteMonth struct {
+	Name string
+	Days []*Snapshot
+}
+
+// runSite writes a browsable static site of every archived day plus all
+// time and yearly statistics to -out
+func (s *Server) runSite(ctx context.Context, args []string) error {
+	if err := parseTemplates(); err != nil {
+		return fmt.Errorf("could not parse templates: %v", err)
+	}
+	snapshots, err := s.archive.Range("", "")
+	if err != nil {
+		return fmt.Errorf("could not read archive: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(*flagSiteOut, "days"), 0755); err != nil {
+		return err
+	}
+
+	write := func(name, tmpl string, data interface{}) error {
+		var b bytes.Buffer
+		if err := templates.ExecuteTemplate(&b, tmpl, data); err != nil {
+			return fmt.Errorf("could not render %s: %w", name, err)
+		}
+		return writeFileAtomic(filepath.Join(*flagSiteOut, name), b.Bytes())
+	}
+
+	// newest first, like the history page
+	var months []*siteMonth
+	years := make(map[string][]*Snapshot)
+	var yearNames []string
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snap := snapshots[i]
+		if err := write(filepath.Join("days", snap.Date+".html"), "site-day", snap); err != nil {
+			return err
+		}
+		month := snap.Time().Format("January 2006")
+		if len(months) == 0 || months[len(months)-1].Name != month {
+			months = append(months, &siteMonth{Name: month})
+		}
+		months[len(months)-1].Days = append(months[len(months)-1].Days, snap)
+
+		year := snap.Date[:4]
+		if _, ok := years[year]; !ok {
+			yearNames = append(yearNames, year)
+		}
+		years[year] = append([]*Snapshot{snap}, years[year]...)
+	}
+
+	if err := write("stats.html", "stats", map[string]interface{}{
+		"Stats":  computeStats(snapshots, 20),
+		"Static": true,
+	}); err != nil {
+		return err
+	}
+	for _, year := range yearNames {
+		if err := write("stats-"+year+".html", "stats", map[string]interface{}{
+			"Stats":  computeStats(years[year], 20),
+			"Static": true,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := write("index.html", "site-index", map[string]interface{}{
+		"Generated": s.cfg.now().Format(time.RFC1123Z),
+		"Years":     yearNames,
+		"Months":    months,
+	}); err != nil {
+		return err
+	}
+
+	slog.Info("generated archive site", "out", *flagSiteOut, "days", len(snapshots))
+	return nil
+}
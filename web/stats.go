@@ -0,0 +1,188 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// itemCount is how often an item was ordered
+type itemCount struct {
+	Item  string
+	Count int
+}
+
+// weekdayStat summarizes the orders placed on one day of the week
+type weekdayStat struct {
+	Weekday time.Weekday
+	Days    int
+	Orders  int
+}
+
+// Average returns the mean number of orders on this weekday
+func (w weekdayStat) Average() float64 {
+	if w.Days == 0 {
+		return 0
+	}
+	return float64(w.Orders) / float64(w.Days)
+}
+
+// orderStats are the statistics shown on /stats
+type orderStats struct {
+	From        string
+	To          string
+	Days        int
+	TotalOrders int
+	// AverageParticipation is the mean percentage of people ordering
+	AverageParticipation float64
+	TopItems             []itemCount
+	Weekdays             []weekdayStat
+	// Participation is the percentage of people ordering, per day
+	Participation []chartPoint
+}
+
+// AverageOrders returns the mean number of orders per day
+func (s *orderStats) AverageOrders() float64 {
+	if s.Days == 0 {
+		return 0
+	}
+	return float64(s.TotalOrders) / float64(s.Days)
+}
+
+// computeStats aggregates archived snapshots, which must be sorted by date
+func computeStats(snapshots []*Snapshot, topN int) *orderStats {
+	stats := &orderStats{Days: len(snapshots)}
+	if len(snapshots) > 0 {
+		stats.From = snapshots[0].Date
+		stats.To = snapshots[len(snapshots)-1].Date
+	}
+
+	counts := make(map[string]*itemCount)
+	weekdays := make([]weekdayStat, 7)
+	for i := range weekdays {
+		weekdays[i].Weekday = time.Weekday(i)
+	}
+	var participation float64
+
+	for _, s := range snapshots {
+		oo := s.Overview()
+		items := oo.LineItems()
+		stats.TotalOrders += len(items)
+		participation += float64(oo.OrderPercent())
+		stats.Participation = append(stats.Participation, chartPoint{s.Date, float64(oo.OrderPercent()), ""})
+
+		wd := &weekdays[s.Time().Weekday()]
+		wd.Days++
+		wd.Orders += len(items)
+
+		countItems(counts, items)
+	}
+	if len(snapshots) > 0 {
+		stats.AverageParticipation = participation / float64(len(snapshots))
+	}
+
+	stats.TopItems = sortCounts(counts)
+	if len(stats.TopItems) > topN {
+		stats.TopItems = stats.TopItems[:topN]
+	}
+
+	// busiest weekdays first, skipping days we never order on
+	for _, wd := range weekdays {
+		if wd.Days > 0 {
+			stats.Weekdays = append(stats.Weekdays, wd)
+		}
+	}
+	sort.SliceStable(stats.Weekdays, func(i, j int) bool {
+		return stats.Weekdays[i].Average() > stats.Weekdays[j].Average()
+	})
+	return stats
+}
+
+// countItems adds the items ordered in items, one day's orders, to counts,
+// by order.ItemKey. Shares of an item count as the whole items they make up.
+func countItems(counts map[string]*itemCount, items []*order.LineItem) {
+	add := func(item string, n int) {
+		key := order.ItemKey(item)
+		if c, ok := counts[key]; ok {
+			c.Count += n
+		} else {
+			counts[key] = &itemCount{Item: item, Count: n}
+		}
+	}
+	// food and drink in separate columns are separate items
+	for _, li := range items {
+		for _, p := range li.Parts {
+			if _, _, shared := order.ParseShare(p.Order); !shared {
+				add(p.Order, 1)
+			}
+		}
+	}
+	for _, shared := range order.SharedItems(items) {
+		add(shared.Item, shared.Wholes())
+	}
+}
+
+// sortCounts returns counts, the most ordered items first
+func sortCounts(counts map[string]*itemCount) []itemCount {
+	var sorted []itemCount
+	for _, c := range counts {
+		sorted = append(sorted, *c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Item < b.Item
+	})
+	return sorted
+}
+
+// parseDateRange reads ?from= and ?to= (2006-01-02), or ?days= counting back
+// from today. Without parameters it returns the last defaultDays days.
+func (s *Server) parseDateRange(r *http.Request, defaultDays int) (from, to string, err error) {
+	q := r.URL.Query()
+	from, to = q.Get("from"), q.Get("to")
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(timeLayout, d); err != nil {
+			return "", "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+
+	days := defaultDays
+	if v := q.Get("days"); v != "" {
+		if _, err := fmt.Sscan(v, &days); err != nil || days < 0 {
+			return "", "", fmt.Errorf("invalid number of days %q", v)
+		}
+	}
+	if from == "" && to == "" && days > 0 {
+		from = s.cfg.now().AddDate(0, 0, -days+1).Format(timeLayout)
+	}
+	return from, to, nil
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	from, to, err := s.parseDateRange(r, 90)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snapshots, err := s.archive.Range(from, to)
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+
+	render(w, r, "stats", map[string]interface{}{
+		"From":  from,
+		"To":    to,
+		"Stats": computeStats(snapshots, 20),
+	})
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+// suggestDays is how far back suggestions look at what was ordered
+const suggestDays = 180
+
+// suggestion is an item someone might like, with its weight in the draw
+type suggestion struct {
+	Item   string
+	weight float64
+}
+
+// suggestionsFor weighs the items name could order: everything on the menu,
+// or without one everything ordered lately, counted once more for every
+// time name had it. The last order is nearly ruled out and the few before
+// it count half, so the surprise is a change.
+func suggestionsFor(name string, menu []string, snapshots []*Snapshot) []suggestion {
+	byKey := make(map[string]*suggestion)
+	var keys []string
+	add := func(item string) {
+		key := order.ItemKey(item)
+		if _, ok := byKey[key]; ok || key == "" {
+			return
+		}
+		byKey[key] = &suggestion{Item: strings.TrimSpace(item), weight: 1}
+		keys = append(keys, key)
+	}
+
+	if len(menu) > 0 {
+		for _, item := range menu {
+			add(item)
+		}
+	} else {
+		for _, s := range snapshots {
+			for _, li := range s.Overview().LineItems() {
+				add(li.Order)
+			}
+		}
+	}
+
+	h := historyFor(name, snapshots, 0)
+	for _, o := range h.Orders {
+		if s, ok := byKey[order.ItemKey(o.Order)]; ok {
+			s.weight++
+		}
+	}
+	// only after counting, or a usual order would still come up every time
+	for i, o := range h.Orders {
+		s, ok := byKey[order.ItemKey(o.Order)]
+		if !ok || i >= 5 {
+			continue
+		}
+		if i == 0 {
+			s.weight *= 0.1
+		} else {
+			s.weight *= 0.5
+		}
+	}
+
+	list := make([]suggestion, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, *byKey[key])
+	}
+	return list
+}
+
+// pickSuggestion draws an item with a chance proportional to its weight
+func pickSuggestion(list []suggestion) string {
+	total := 0.0
+	for _, s := range list {
+		total += s.weight
+	}
+	if total <= 0 {
+		return ""
+	}
+	x := rand.Float64() * total
+	for _, s := range list {
+		if x < s.weight {
+			return s.Item
+		}
+		x -= s.weight
+	}
+	return list[len(list)-1].Item
+}
+
+// handleSuggest picks something for the visitor to order today, for when
+// they can't decide. ?name= asks for someone else than the claimed name.
+func (s *Server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	now := s.cfg.now()
+	snapshots, err := s.archive.Range(now.AddDate(0, 0, -suggestDays).Format(timeLayout), "")
+	if err != nil {
+		requestLogger(r).Error("could not read archive", "err", err)
+		http.Error(w, "could not read archive", http.StatusInternalServerError)
+		return
+	}
+
+	var names []string
+	if oo, err := s.todaysOrders(r.Context(), requestLogger(r)); err == nil {
+		names = oo.Names
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = s.claimedName(r, names)
+	}
+	var menu []string
+	for _, item := range s.menuItems(requestLogger(r), s.cfg.vendorFor(now)) {
+		menu = append(menu, item.Name)
+	}
+	item := pickSuggestion(suggestionsFor(name, menu, snapshots))
+	var allergens []string
+	if item != "" && s.seesAllergiesOf(r, names, name) {
+		allergens = order.Allergens(item, s.allergies(r.Context(), requestLogger(r))[order.NameKey(name)])
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "item": item, "allergens": allergens})
+		return
+	}
+	var gloss string
+	if lang := requestLanguage(w, r); lang != "" && item != "" {
+		gloss = translations.Gloss(item, lang)
+	}
+	render(w, r, "suggest", map[string]interface{}{
+		"Name":      name,
+		"Item":      item,
+		"Gloss":     gloss,
+		"Allergens": strings.Join(allergens, ", "),
+		"Vendor":    s.cfg.vendorName(now),
+		"SheetURL":  s.cfg.sheetURL,
+	})
+}
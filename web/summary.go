@@ -0,0 +1,49 @@
+package web
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/datacamp/lunchweb/order"
+)
+
+var flagSummaryFormat = flag.String("summary-format", "{{.Name}}: {{.Order}}", "template for each line of the order summary, with .Name and .Order")
+var flagSummarySort = flag.String("summary-sort", order.SortName, "order of the summary lines: name, order (groups the same items) or column (as in the sheet)")
+
+// summaryTemplate checks -summary-format and -summary-sort and returns the
+// template for a summary line
+func summaryTemplate() (*template.Template, error) {
+	t, err := template.New("summary").Parse(*flagSummaryFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -summary-format: %v", err)
+	}
+	if err := t.Execute(io.Discard, &order.LineItem{Name: "Joe", Order: "BLT"}); err != nil {
+		return nil, fmt.Errorf("invalid -summary-format: %v", err)
+	}
+	switch *flagSummarySort {
+	case order.SortName, order.SortOrder, order.SortColumn:
+	default:
+		return nil, fmt.Errorf("invalid -summary-sort %q, expected name, order or column", *flagSummarySort)
+	}
+	return t, nil
+}
+
+// sharedText lists the items people share for a notification, with the
+// shares that don't add up, or "" without any
+func sharedText(shared []*order.SharedItem) string {
+	if len(shared) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nShared, to order as:\n")
+	for _, s := range shared {
+		b.WriteString(s.String() + "\n")
+		if conflict := s.Conflict(); conflict != "" {
+			b.WriteString("⚠️ " + conflict + "\n")
+		}
+	}
+	return b.String()
+}
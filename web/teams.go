@@ -0,0 +1,288 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/teams"
+)
+
+var flagTeamsAppID = flag.String("teams-app-id", "", "Microsoft app ID of a Teams bot whose messaging endpoint is /hooks/teams, to order with an adaptive card in Teams (requires -teams-app-password)")
+var flagTeamsAppPassword = flag.String("teams-app-password", "", "client secret of the Teams bot's app")
+
+// teamsOrderVerb is the verb of the card's order button
+const teamsOrderVerb = "order"
+
+// newTeamsClient returns the client answering as the Teams bot and the
+// verifier checking that activities come from the Bot Framework, both nil
+// without -teams-app-id
+func newTeamsClient() (*teams.Client, *teams.Verifier, error) {
+	if *flagTeamsAppID == "" && *flagTeamsAppPassword == "" {
+		return nil, nil, nil
+	}
+	if *flagTeamsAppID == "" || *flagTeamsAppPassword == "" {
+		return nil, nil, fmt.Errorf("-teams-app-id and -teams-app-password go together")
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &teams.Client{AppID: *flagTeamsAppID, Password: *flagTeamsAppPassword, Client: client},
+		&teams.Verifier{AppID: *flagTeamsAppID, Client: client}, nil
+}
+
+// teamsMentionRe finds the bot's mention, which Teams puts in the text of
+// messages in channels
+var teamsMentionRe = regexp.MustCompile(`<at>[^<]*</at>`)
+
+// teamsAction is the value of the invoke activity the order button sends
+type teamsAction struct {
+	Action struct {
+		Verb string `json:"verb"`
+		Data struct {
+			Item  string `json:"item"`
+			Order string `json:"order"`
+			// Current is the order the text field started with
+			Current string `json:"current"`
+		} `json:"data"`
+	} `json:"action"`
+}
+
+// handleTeams answers the Teams bot: a message gets the order card back, or
+// places the order when it says "order: ...", and the card's button places
+// the order picked or written in it
+func (s *Server) handleTeams(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read the request", http.StatusBadRequest)
+		return
+	}
+	var a teams.Activity
+	if err := json.Unmarshal(body, &a); err != nil {
+		http.Error(w, "could not read the activity", http.StatusBadRequest)
+		return
+	}
+	if err := s.teamsVerifier.Verify(r.Context(), r.Header.Get("Authorization"), a.ServiceURL); err != nil {
+		logger.Warn("Teams hook called without a valid token", "err", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case a.Type == "message":
+		s.answerTeamsMessage(r, &a)
+	case a.Type == "invoke" && a.Name == "adaptiveCard/action":
+		var action teamsAction
+		if err := json.Unmarshal(a.Value, &action); err != nil || action.Action.Verb != teamsOrderVerb {
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+		name, err := s.teamsName(r, &a)
+		if err != nil {
+			logger.Warn("could not find the Teams user in the sheet", "user", a.From.ID, "err", err)
+			writeTeamsResponse(w, teams.MessageResponse(fmt.Sprintf("Sorry, I couldn't record your order: %v", err)))
+			return
+		}
+		writeTeamsResponse(w, s.submitTeamsOrder(r, &a, name, &action))
+	default:
+		// conversation updates and the like, nothing to do
+	}
+}
+
+// answerTeamsMessage replies to a message with the order card, placing the
+// order first when the message is one
+func (s *Server) answerTeamsMessage(r *http.Request, a *teams.Activity) {
+	logger := requestLogger(r)
+	text := strings.TrimSpace(teamsMentionRe.ReplaceAllString(a.Text, ""))
+
+	name, err := s.teamsName(r, a)
+	if err != nil {
+		logger.Warn("could not find the Teams user in the sheet", "user", a.From.ID, "err", err)
+		if err := s.teamsClient.Reply(r.Context(), a, fmt.Sprintf("Sorry, %v", err)); err != nil {
+			logger.Error("could not reply in Teams", "user", a.From.ID, "err", err)
+		}
+		return
+	}
+	var notice string
+	if m := orderLineRe.FindStringSubmatch(text); m != nil {
+		item := m[1]
+		if cancelWords[strings.ToLower(item)] {
+			item = ""
+		}
+		notice = s.placeTeamsOrder(r, a, name, item, nil)
+	}
+	card, err := s.teamsOrderCard(r, name, notice)
+	if err != nil {
+		logger.Error("could not make Teams order card", "user", a.From.ID, "err", err)
+		err = s.teamsClient.Reply(r.Context(), a, fmt.Sprintf("Sorry, I couldn't show the order form: %v", err))
+	} else {
+		err = s.teamsClient.Reply(r.Context(), a, "", teams.CardAttachment(card))
+	}
+	if err != nil {
+		logger.Error("could not reply in Teams", "user", a.From.ID, "err", err)
+	}
+}
+
+// submitTeamsOrder places the order sent with the card's button and returns
+// the card to replace it with, showing what was recorded
+func (s *Server) submitTeamsOrder(r *http.Request, a *teams.Activity, name string, action *teamsAction) interface{} {
+	data := action.Action.Data
+	item := strings.TrimSpace(data.Order)
+	// a pick from the menu wins over the text still showing the old order
+	if data.Item != "" && (item == "" || item == data.Current) {
+		item = data.Item
+	}
+
+	notice := "Pick something from the menu or write your order."
+	if item != "" {
+		if cancelWords[strings.ToLower(item)] {
+			item = ""
+		}
+		notice = s.placeTeamsOrder(r, a, name, item, &data.Current)
+	}
+	card, err := s.teamsOrderCard(r, name, notice)
+	if err != nil {
+		requestLogger(r).Error("could not make Teams order card", "user", a.From.ID, "err", err)
+		return teams.MessageResponse(fmt.Sprintf("Sorry, I couldn't show the order form: %v", err))
+	}
+	return teams.CardResponse(card)
+}
+
+// placeTeamsOrder places item as the order of name, who sent a, over the
+// order they saw, nil when unknown, and returns what to tell them
+func (s *Server) placeTeamsOrder(r *http.Request, a *teams.Activity, name, item string, seen *string) string {
+	logger := requestLogger(r)
+	subject := a.From.AADObjectID
+	if subject == "" {
+		subject = a.From.ID
+	}
+	r = r.WithContext(context.WithValue(r.Context(), identityKey, &Identity{Name: name, Subject: "teams:" + subject}))
+	reply, err := s.placeOrderOver(r, name, item, "Teams", seen)
+	var conflict *orderConflict
+	if errors.As(err, &conflict) {
+		// the card shows their order as it is now
+		return conflict.notRecorded(item)
+	}
+	if err != nil {
+		if !errors.Is(err, errOrdersFrozen) {
+			logger.Error("could not take order from Teams", "err", err)
+		}
+		return fmt.Sprintf("Sorry, I couldn't record your order: %v", err)
+	}
+	return reply
+}
+
+// teamsOrderCard is the order form: what has been ordered so far, today's
+// menu to pick from and a text field for anything else, filled in with the
+// current order. notice, if any, goes on top.
+func (s *Server) teamsOrderCard(r *http.Request, name, notice string) (map[string]interface{}, error) {
+	logger := requestLogger(r)
+	now := s.cfg.now()
+	oo, err := s.ordersOn(r.Context(), logger, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []interface{}
+	if notice != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": notice, "wrap": true, "weight": "bolder"})
+	}
+	intro := fmt.Sprintf("Ordering lunch as **%s**", name)
+	if vendor := s.cfg.vendorName(now); vendor != "" {
+		intro += fmt.Sprintf(" from **%s**", vendor)
+	}
+	if deadline, ok := s.cfg.deadlineOn(now); ok {
+		intro += fmt.Sprintf(", until %s", deadline.Format("15:04"))
+	}
+	items := oo.LineItems()
+	intro += fmt.Sprintf(". %d out of %d ordered so far.", len(items), oo.MaxCount())
+	body = append(body, map[string]interface{}{"type": "TextBlock", "text": intro, "wrap": true})
+
+	counts := make(map[string]*itemCount)
+	countItems(counts, items)
+	var facts []interface{}
+	for _, c := range sortCounts(counts) {
+		if len(facts) == 10 {
+			break
+		}
+		facts = append(facts, map[string]string{"title": c.Item, "value": fmt.Sprintf("%d×", c.Count)})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	var choices []interface{}
+	allergies := s.allergies(r.Context(), logger)
+	for _, item := range s.menuItems(logger, s.cfg.vendorFor(now)) {
+		choices = append(choices, map[string]string{"title": menuItemText(item, allergies, name), "value": item.Name})
+	}
+	if len(choices) > 0 {
+		body = append(body, map[string]interface{}{
+			"type":        "Input.ChoiceSet",
+			"id":          "item",
+			"label":       "Pick from today's menu",
+			"placeholder": "Today's menu",
+			"choices":     choices,
+		})
+	}
+	current := oo.OrderOf(name)
+	body = append(body, map[string]interface{}{
+		"type":        "Input.Text",
+		"id":          "order",
+		"label":       "Or write your order",
+		"placeholder": `"none" takes your order back`,
+		"value":       current,
+		"maxLength":   200,
+	})
+
+	return map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		// Action.Execute needs 1.4
+		"version": "1.4",
+		"body":    body,
+		"actions": []interface{}{map[string]interface{}{
+			"type":  "Action.Execute",
+			"title": "Order",
+			"verb":  teamsOrderVerb,
+			"data":  map[string]string{"current": current},
+		}},
+	}, nil
+}
+
+// teamsName returns the sheet name of the sender of a: the one of their
+// email address in -addresses, or else the one matching their name or the
+// start of their email address, like a login does
+func (s *Server) teamsName(r *http.Request, a *teams.Activity) (string, error) {
+	member, err := s.teamsClient.Member(r.Context(), a)
+	if err != nil {
+		return "", err
+	}
+	oo, err := s.ordersOn(r.Context(), requestLogger(r), s.cfg.now())
+	if err != nil {
+		return "", err
+	}
+	email := member.Email
+	if email == "" {
+		email = member.UserPrincipalName
+	}
+	if name := s.cfg.nameOf(email); name != "" {
+		return name, nil
+	}
+	local, _, _ := strings.Cut(email, "@")
+	if name := matchName(oo.Names, member.Name, a.From.Name, local); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("no column in the sheet matches your Teams name %q, ask the office to add your email address to lunchweb", member.Name)
+}
+
+func writeTeamsResponse(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
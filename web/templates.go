@@ -0,0 +1,93 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+// templates holds every page. Pages share the "style" and "htmx"
+// definitions.
+var templates *template.Template
+
+// pageTemplates are parsed in order into templates, each from
+// templates/<name>.html in webFiles
+var pageTemplates = []string{
+	"style",
+	"htmx",
+	"index",
+	"admin",
+	"audit",
+	"links",
+	"presets",
+	"me",
+	"stats",
+	"people",
+	"person",
+	"leaderboard",
+	"reports",
+	"report",
+	"invoice",
+	"history",
+	"changes",
+	"suggest",
+	"menu",
+	"site-index",
+	"site-day",
+	"error",
+}
+
+// templateFuncs are available in every template
+var templateFuncs = template.FuncMap{
+	"add":      func(a, b int) int { return a + b },
+	"barchart": barChart,
+	"asset":    assetURL,
+}
+
+func parseTemplates() error {
+	t, err := loadTemplates()
+	if err != nil {
+		return err
+	}
+	templates = t
+	return nil
+}
+
+func loadTemplates() (*template.Template, error) {
+	t := template.New("").Funcs(templateFuncs)
+	for _, name := range pageTemplates {
+		text, err := fs.ReadFile(webFiles, "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(name).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("%s.html: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+// pages returns the parsed templates, read again from disk on every call
+// in -dev
+func pages() (*template.Template, error) {
+	if devMode {
+		return loadTemplates()
+	}
+	return templates, nil
+}
+
+// render executes the named template, reporting failures as a 500
+func render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	t, err := pages()
+	if err != nil {
+		requestLogger(r).Error("could not parse templates", "err", err)
+		http.Error(w, "error in template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := t.ExecuteTemplate(w, name, data); err != nil {
+		requestLogger(r).Error("could not render template", "template", name, "err", err)
+		reportError(r, "could not render template", err, map[string]string{"template": name})
+		http.Error(w, "error in template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/pdf"
+)
+
+// The tickets are laid out in a grid on A4, inside a margin printers can
+// print on
+const (
+	ticketColumns = 2
+	ticketRows    = 5
+	ticketMargin  = 20.0
+	ticketPadding = 14.0
+)
+
+// ticketText is the order on a person's ticket, its labelled parts on
+// lines of their own
+func ticketText(li *order.LineItem) []string {
+	if !li.Grouped() {
+		return []string{li.Order}
+	}
+	var lines []string
+	for _, p := range li.Parts {
+		if p.Label != "" {
+			lines = append(lines, p.Label+": "+p.Order)
+		} else {
+			lines = append(lines, p.Order)
+		}
+	}
+	return lines
+}
+
+// tickets lays out a ticket per line item, by name, with their order and
+// the day and vendor at the bottom, and dashed lines to cut along
+func (s *Server) tickets(t time.Time, items []*order.LineItem) *pdf.Document {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b *order.LineItem) int {
+		switch {
+		case order.LessName(a.Name, b.Name):
+			return -1
+		case order.LessName(b.Name, a.Name):
+			return 1
+		}
+		return 0
+	})
+	footer := t.Format("Mon 2 Jan")
+	if vendor := s.cfg.vendorName(t); vendor != "" {
+		footer += " · " + vendor
+	}
+
+	doc := pdf.New(pdf.A4)
+	size := doc.Size()
+	width := (size.Width - 2*ticketMargin) / ticketColumns
+	height := (size.Height - 2*ticketMargin) / ticketRows
+	perPage := ticketColumns * ticketRows
+	var page *pdf.Page
+	for i, li := range items {
+		if i%perPage == 0 {
+			page = doc.AddPage()
+			cutLines(page, size, width, height)
+		}
+		// left to right, top to bottom
+		cell := i % perPage
+		x := ticketMargin + float64(cell%ticketColumns)*width + ticketPadding
+		top := size.Height - ticketMargin - float64(cell/ticketColumns)*height - ticketPadding
+		bottom := top - height + 2*ticketPadding
+		textWidth := width - 2*ticketPadding
+
+		name := li.Name
+		if lines := pdf.Wrap(pdf.HelveticaBold, 18, textWidth, name); len(lines) > 0 {
+			name = lines[0]
+		}
+		page.Text(x, top-18, pdf.HelveticaBold, 18, name)
+		var lines []string
+		for _, text := range ticketText(li) {
+			lines = append(lines, pdf.Wrap(pdf.Helvetica, 12, textWidth, text)...)
+		}
+		// the order goes below the name, and what doesn't fit above the
+		// footer ends in "…"
+		first, lineHeight := top-18-20, 15.0
+		fit := int((first-bottom-15)/lineHeight) + 1
+		if len(lines) > fit {
+			lines = lines[:fit]
+			lines[fit-1] = strings.TrimSpace(lines[fit-1]) + " …"
+		}
+		for j, line := range lines {
+			page.Text(x, first-float64(j)*lineHeight, pdf.Helvetica, 12, line)
+		}
+		page.Text(x, bottom, pdf.Helvetica, 9, fmt.Sprintf("%d of %d · %s", i+1, len(items), footer))
+	}
+	if page == nil {
+		page = doc.AddPage()
+		page.Text(ticketMargin, size.Height-ticketMargin-18, pdf.HelveticaBold, 18, "Nobody ordered lunch for "+footer)
+	}
+	return doc
+}
+
+// cutLines draws the dashed lines between the tickets on a page
+func cutLines(page *pdf.Page, size pdf.Size, width, height float64) {
+	left, right := ticketMargin, size.Width-ticketMargin
+	bottom, top := ticketMargin, size.Height-ticketMargin
+	for c := 0; c <= ticketColumns; c++ {
+		x := left + float64(c)*width
+		page.Line(x, bottom, x, top, true)
+	}
+	for r := 0; r <= ticketRows; r++ {
+		y := bottom + float64(r)*height
+		page.Line(left, y, right, y, true)
+	}
+}
+
+// handleTickets answers /tickets.pdf, a ticket per person with their order
+// from today's line items, to tape onto the bags when handing out a big
+// delivery
+func (s *Server) handleTickets(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	oo, err := s.todaysOrders(r.Context(), logger)
+	if err != nil {
+		s.renderSheetError(w, r, err)
+		return
+	}
+	now := s.cfg.now()
+	// orders waiting for approval don't come with the delivery
+	vendor, _, err := s.vendorOrders(now.Format(timeLayout), oo)
+	if err != nil {
+		logger.Error("could not read approvals", "err", err)
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="lunch-tickets-`+now.Format(timeLayout)+`.pdf"`)
+	if err := s.tickets(now, vendor.LineItems()).Write(w); err != nil {
+		logger.Error("could not write tickets", "err", err)
+	}
+}
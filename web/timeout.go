@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// untimedPaths are the requests timeoutHandler leaves alone, as they take
+// long by nature: profiles on /debug/ take as many seconds as asked, admin
+// actions call one API after another, receipts are uploaded from phones and
+// announcements wait for text to speech
+var untimedPaths = []string{"/debug/", "/admin/", "/receipts/", "/announce.mp3"}
+
+// untimedLimit bounds the requests in untimedPaths instead, in place of
+// -read-timeout and -write-timeout
+const untimedLimit = 5 * time.Minute
+
+// timeoutHandler gives every request d to finish and answers 504 Gateway
+// Timeout when it doesn't. Like http.TimeoutHandler the response is buffered
+// until the handler returns, and the buffer handed to cacheAndCompress when
+// it comes next. Requests in untimedPaths get untimedLimit instead and
+// aren't buffered.
+func timeoutHandler(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range untimedPaths {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				rc := http.NewResponseController(w)
+				rc.SetReadDeadline(time.Now().Add(untimedLimit))
+				rc.SetWriteDeadline(time.Now().Add(untimedLimit))
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan *handlerPanic, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- &handlerPanic{value: p, stack: debug.Stack()}
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			// let recoverPanics further up deal with it
+			if p.value == http.ErrAbortHandler {
+				panic(p.value)
+			}
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			// cacheAndCompress holds back the response too, hand it ours
+			// rather than copying it
+			if bw, ok := w.(*bufferedWriter); !ok || !bw.adopt(&tw.buf) {
+				w.Write(tw.buf.Bytes())
+			}
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			requestLogger(r).Warn("request timed out", "path", r.URL.Path, "timeout", d)
+			http.Error(w, "the request took too long, please try again", http.StatusGatewayTimeout)
+		}
+	})
+}
+
+// handlerPanic carries a panic out of the goroutine timeoutHandler runs the
+// handler in, with the stack it happened on, as the stack it's raised again
+// on only leads back to timeoutHandler
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// timeoutWriter buffers a response for timeoutHandler
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+// isTimeout reports whether err means an upstream call ran out of time
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,250 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/translate"
+	"golang.org/x/text/language"
+)
+
+var flagTranslate = flag.String("translate", "", "translate orders and menu items into the viewer's language: deepl, or libretranslate=<server URL>")
+var flagTranslateKey = flag.String("translate-key", "", "API key of the -translate backend")
+var flagTranslateLanguages = flag.String("translate-languages", "en,nl,de,fr", "comma separated languages viewers can pick to translate into")
+
+const (
+	// langCookie remembers the language picked with ?lang=
+	langCookie = "lunchweb_lang"
+	// translateBatch is how many texts are translated in one request
+	translateBatch = 50
+	// translateTimeout bounds one request in the background
+	translateTimeout = 30 * time.Second
+)
+
+// translationRequest is a text waiting to be translated into a language
+type translationRequest struct {
+	target string
+	text   string
+}
+
+// translationStore caches translations by target language and lower case
+// text in <data-dir>/translations.json. Like nutritionStore, pages only
+// read the cache and what's missing is translated in the background.
+type translationStore struct {
+	translator translate.Translator
+	languages  []string
+	path       string
+
+	mu      sync.Mutex
+	entries map[string]map[string]string
+	pending map[translationRequest]bool
+	queue   chan translationRequest
+}
+
+// translations is nil without -translate
+var translations *translationStore
+
+func setupTranslate(dataDir string) error {
+	translations = nil
+	var t translate.Translator
+	switch backend, arg, _ := strings.Cut(*flagTranslate, "="); backend {
+	case "":
+		return nil
+	case "deepl":
+		if *flagTranslateKey == "" {
+			return fmt.Errorf("-translate deepl requires -translate-key")
+		}
+		t = &translate.DeepL{Key: *flagTranslateKey}
+	case "libretranslate":
+		if !strings.HasPrefix(arg, "https://") && !strings.HasPrefix(arg, "http://") {
+			return fmt.Errorf("-translate libretranslate requires the server's URL, like libretranslate=https://translate.example.com")
+		}
+		t = &translate.LibreTranslate{URL: arg, APIKey: *flagTranslateKey}
+	default:
+		return fmt.Errorf("invalid -translate %q, expected deepl or libretranslate=<URL>", *flagTranslate)
+	}
+
+	var languages []string
+	for _, l := range strings.Split(*flagTranslateLanguages, ",") {
+		tag, err := language.Parse(strings.TrimSpace(l))
+		if err != nil {
+			return fmt.Errorf("invalid -translate-languages %q: %v", l, err)
+		}
+		languages = append(languages, tag.String())
+	}
+
+	s := &translationStore{
+		translator: t,
+		languages:  languages,
+		path:       filepath.Join(dataDir, "translations.json"),
+		entries:    make(map[string]map[string]string),
+		pending:    make(map[translationRequest]bool),
+		queue:      make(chan translationRequest, 500),
+	}
+	b, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &s.entries); err != nil {
+			return fmt.Errorf("%s: %v", s.path, err)
+		}
+	}
+	translations = s
+	return nil
+}
+
+// Gloss returns item translated into target, or "" when the translation
+// isn't cached yet, in which case it is queued, or says the same
+func (s *translationStore) Gloss(item, target string) string {
+	text := order.WithoutPrice(item)
+	key := strings.ToLower(text)
+	if key == "" || target == "" {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if translated, ok := s.entries[target][key]; ok {
+		if strings.EqualFold(strings.TrimSpace(translated), text) {
+			return ""
+		}
+		return translated
+	}
+	req := translationRequest{target, text}
+	if !s.pending[req] {
+		select {
+		case s.queue <- req:
+			s.pending[req] = true
+		default:
+			// full, the item comes up again on the next page
+		}
+	}
+	return ""
+}
+
+// run translates the queued texts until ctx is done, a batch per language
+func (s *translationStore) run(ctx context.Context) {
+	for {
+		var first translationRequest
+		select {
+		case <-ctx.Done():
+			return
+		case first = <-s.queue:
+		}
+		batch := map[string][]string{first.target: {first.text}}
+	collect:
+		for n := 1; n < translateBatch; n++ {
+			select {
+			case req := <-s.queue:
+				batch[req.target] = append(batch[req.target], req.text)
+			default:
+				break collect
+			}
+		}
+		for target, texts := range batch {
+			s.translate(ctx, target, texts)
+		}
+	}
+}
+
+func (s *translationStore) translate(ctx context.Context, target string, texts []string) {
+	ctx, cancel := context.WithTimeout(ctx, translateTimeout)
+	defer cancel()
+	translated, err := s.translator.Translate(ctx, texts, target)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, text := range texts {
+		delete(s.pending, translationRequest{target, text})
+	}
+	if err != nil {
+		// not cached, so the next page asks again
+		slog.Warn("could not translate", "translator", s.translator.Name(), "target", target, "texts", len(texts), "err", err)
+		return
+	}
+	if s.entries[target] == nil {
+		s.entries[target] = make(map[string]string)
+	}
+	for i, text := range texts {
+		s.entries[target][strings.ToLower(text)] = translated[i]
+	}
+	b, err := json.MarshalIndent(s.entries, "", "\t")
+	if err == nil {
+		err = writeFileAtomic(s.path, b)
+	}
+	if err != nil {
+		slog.Error("could not store translations", "err", err)
+	}
+}
+
+// requestLanguage returns the language to translate the page into: ?lang=
+// when given, which is then remembered in a cookie, else the remembered
+// one. "" shows everything as written, as does a language not in
+// -translate-languages.
+func requestLanguage(w http.ResponseWriter, r *http.Request) string {
+	if translations == nil {
+		return ""
+	}
+	var lang string
+	if r.URL.Query().Has("lang") {
+		lang = r.URL.Query().Get("lang")
+		if lang == "" {
+			clearCookie(w, langCookie)
+		} else if err := setSignedCookie(w, r, langCookie, lang, claimDuration); err != nil {
+			requestLogger(r).Warn("could not remember language", "err", err)
+		}
+	} else {
+		signedCookie(r, langCookie, &lang)
+	}
+	for _, l := range translations.languages {
+		if l == lang {
+			return lang
+		}
+	}
+	return ""
+}
+
+// glosses returns the translation of every order into lang by name, shown
+// next to it
+func glosses(oo *order.Overview, lang string) map[string]string {
+	if translations == nil || lang == "" {
+		return nil
+	}
+	notes := make(map[string]string)
+	for _, li := range oo.LineItems() {
+		if len(li.Parts) == 0 {
+			if g := translations.Gloss(li.Order, lang); g != "" {
+				notes[li.Name] = g
+			}
+			continue
+		}
+		var parts []string
+		for _, p := range li.Parts {
+			if g := translations.Gloss(p.Order, lang); g != "" {
+				parts = append(parts, g)
+			}
+		}
+		if len(parts) > 0 {
+			notes[li.Name] = strings.Join(parts, "; ")
+		}
+	}
+	return notes
+}
+
+// languages lists the languages viewers can pick, nil without -translate
+func languages() []string {
+	if translations == nil {
+		return nil
+	}
+	return translations.languages
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// tzCookie remembers the time zone picked with ?tz=
+	tzCookie   = "lunchweb_tz"
+	tzDuration = 365 * 24 * time.Hour
+)
+
+// requestLocation returns the time zone to show the page in: ?tz= when
+// given, which is then remembered in a cookie, else the remembered one, else
+// -tz. An empty ?tz= goes back to -tz.
+func (s *Server) requestLocation(w http.ResponseWriter, r *http.Request) (*time.Location, error) {
+	if r.URL.Query().Has("tz") {
+		name := r.URL.Query().Get("tz")
+		if name == "" {
+			clearCookie(w, tzCookie)
+			return s.cfg.location, nil
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", name)
+		}
+		if err := setSignedCookie(w, r, tzCookie, name, tzDuration); err != nil {
+			return nil, err
+		}
+		return loc, nil
+	}
+
+	var name string
+	if signedCookie(r, tzCookie, &name) {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, nil
+		}
+	}
+	return s.cfg.location, nil
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/order"
+	"github.com/datacamp/lunchweb/sheet"
+)
+
+// sheetProblem is a problem in a cell of the sheet. Row and Column count
+// from 0; String shows them the way the spreadsheet does (A1, C5, ...).
+type sheetProblem struct {
+	Row     int
+	Column  int
+	Message string
+}
+
+func (p sheetProblem) String() string {
+	return fmt.Sprintf("%s%d: %s", columnName(p.Column), p.Row+1, p.Message)
+}
+
+// columnName returns the spreadsheet name of column i: A, B, ..., Z, AA, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// validateSheet looks for cells that keep lunchweb from reading the sheet:
+// empty or duplicate names in the header, rows with a different number of
+// columns than the header and dates that don't parse
+func validateSheet(layout sheet.Layout, rows [][]string) []sheetProblem {
+	if len(rows) <= layout.Header {
+		return []sheetProblem{{Row: len(rows), Message: fmt.Sprintf("there is no header row, the sheet has %d rows", len(rows))}}
+	}
+
+	var problems []sheetProblem
+	header := rows[layout.Header]
+	names := make(map[string]int)
+	for col, name := range header {
+		if col == 0 {
+			continue
+		}
+		name = order.NormalizeName(name)
+		if name == "" {
+			problems = append(problems, sheetProblem{layout.Header, col, "empty name in the header"})
+			continue
+		}
+		key := order.NameKey(name)
+		if prev, ok := names[key]; ok {
+			problems = append(problems, sheetProblem{layout.Header, col, fmt.Sprintf("%q is also the name in column %s, their orders are merged", name, columnName(prev))})
+			continue
+		}
+		names[key] = col
+	}
+
+	start, data := layout.DataRows(rows)
+	for i, row := range data {
+		index := start + i
+		if len(row) != len(header) {
+			problems = append(problems, sheetProblem{index, 0, fmt.Sprintf("row has %d columns, the header has %d", len(row), len(header))})
+		}
+		if len(row) == 0 {
+			continue
+		}
+		date := strings.TrimSpace(row[0])
+		if date == "" {
+			if hasOrders(row) {
+				problems = append(problems, sheetProblem{index, 0, "row has orders but no date"})
+			}
+			continue
+		}
+		if _, err := time.Parse(timeLayout, date); err != nil {
+			problems = append(problems, sheetProblem{index, 0, fmt.Sprintf("%q is not a date, expected YYYY-MM-DD", date)})
+		}
+	}
+	return problems
+}
+
+// hasOrders reports whether any cell after the date is filled in
+func hasOrders(row []string) bool {
+	for _, cell := range row[1:] {
+		if strings.TrimSpace(cell) != "" {
+			return true
+		}
+	}
+	return false
+}
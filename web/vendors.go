@@ -0,0 +1,113 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Vendor is a restaurant we order from, configured through -vendors
+type Vendor struct {
+	Name string `json:"name"`
+	// Days are the weekdays we order from this vendor, e.g. ["mon", "thu"]
+	Days []string `json:"days"`
+	// Email receives the order email on this vendor's days instead of -email
+	Email string `json:"email"`
+	// Menu lists the items "surprise me" picks from, besides those stored
+	// on the admin page
+	Menu []string `json:"menu"`
+	// MenuURL is an image or PDF of the menu, shown on the page on this
+	// vendor's days unless one is uploaded on the admin page
+	MenuURL string `json:"menu_url"`
+	// Deadline is the time of day orders must be in on this vendor's days,
+	// e.g. "10:00", instead of -deadline
+	Deadline string `json:"deadline"`
+	// Pickup is whether someone picks lunch up from this vendor instead of
+	// it being delivered, taking turns among -pickup-volunteer
+	Pickup bool `json:"pickup"`
+	// ETAURL is polled for the estimated arrival once the order is placed,
+	// a URL template with .Date and .Tracking, the tracking URL set on the
+	// admin page. The JSON response has the arrival in ETAField, "eta" by
+	// default, as a time like "12:25" or RFC 3339, or as minutes from now.
+	ETAURL   string `json:"eta_url"`
+	ETAField string `json:"eta_field"`
+
+	weekdays []time.Weekday
+	deadline timeOfDay
+	etaURL   *template.Template
+}
+
+// loadVendors reads a JSON list of vendors
+func loadVendors(path string) ([]*Vendor, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Vendor
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, v := range list {
+		if v.Name == "" {
+			return nil, fmt.Errorf("%s: vendor without name", path)
+		}
+		for _, d := range v.Days {
+			wd, ok := parseWeekday(d)
+			if !ok {
+				return nil, fmt.Errorf("%s: vendor %q: unknown day %q", path, v.Name, d)
+			}
+			v.weekdays = append(v.weekdays, wd)
+		}
+		if v.MenuURL != "" && !strings.HasPrefix(v.MenuURL, "https://") && !strings.HasPrefix(v.MenuURL, "http://") {
+			return nil, fmt.Errorf("%s: vendor %q: menu_url must be an http(s) URL", path, v.Name)
+		}
+		if err := v.deadline.Set(v.Deadline); err != nil {
+			return nil, fmt.Errorf("%s: vendor %q: invalid deadline %q: %v", path, v.Name, v.Deadline, err)
+		}
+		if v.ETAURL != "" {
+			if v.etaURL, err = parseETAURL(v.ETAURL); err != nil {
+				return nil, fmt.Errorf("%s: vendor %q: invalid eta_url: %v", path, v.Name, err)
+			}
+		}
+	}
+	return list, nil
+}
+
+// vendorFor returns the vendor we order from on the given day, or nil
+func (c *config) vendorFor(day time.Time) *Vendor {
+	for _, v := range c.vendors {
+		for _, wd := range v.weekdays {
+			if wd == day.Weekday() {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// vendorName returns the name of the day's vendor, or "" when unknown
+func (c *config) vendorName(day time.Time) string {
+	if v := c.vendorFor(day); v != nil {
+		return v.Name
+	}
+	return ""
+}
+
+// parseWeekday accepts English day names and their three letter
+// abbreviations in any case
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 {
+		return 0, false
+	}
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set at build time:
+//
+//	go install -ldflags "-X github.com/datacamp/lunchweb/web.version=1.4.0" ./cmd/lunchweb
+var version = "dev"
+
+var flagVersion = flag.Bool("version", false, "print the version and exit")
+
+// buildInfo identifies the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuild returns the version plus the commit and build date the Go
+// toolchain stamped into the binary, when it was built from a checkout
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Commit = s.Value
+		case "vcs.time":
+			b.BuildDate = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+func (b buildInfo) String() string {
+	s := "lunchweb " + b.Version
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit
+		if b.Modified {
+			s += ", modified"
+		}
+		if b.BuildDate != "" {
+			s += ", " + b.BuildDate
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s %s", s, b.GoVersion)
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentBuild())
+}
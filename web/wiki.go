@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/datacamp/lunchweb/notify"
+	"github.com/datacamp/lunchweb/sheet"
+	"github.com/datacamp/lunchweb/wiki"
+)
+
+var flagWiki = flag.String("wiki", "", "publish the day's orders and the weekly digest to a wiki: confluence=<base URL> like confluence=https://example.atlassian.net/wiki, or the URL of a webhook of another wiki")
+var flagWikiSpace = flag.String("wiki-space", "", "key of the Confluence space to publish to")
+var flagWikiParent = flag.String("wiki-parent", "", "ID of the Confluence page to publish under (default: the space's home)")
+var flagWikiUser = flag.String("wiki-user", "", "Confluence Cloud user of -wiki-token (default: the token is a personal access token)")
+var flagWikiToken = flag.String("wiki-token", "", "Confluence API token or personal access token")
+var flagWikiTime timeOfDay
+
+func init() {
+	flag.Var(&flagWikiTime, "wiki-time", "time of day serve publishes the day's orders to -wiki, e.g. 14:00 (default: only lunchweb send and the digest publish)")
+}
+
+// newPublisher returns what writes pages to the wiki, nil without -wiki
+func newPublisher() (wiki.Publisher, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch {
+	case *flagWiki == "":
+		if flagWikiTime.IsSet() {
+			return nil, fmt.Errorf("-wiki-time requires -wiki")
+		}
+		return nil, nil
+	case strings.HasPrefix(*flagWiki, "confluence="):
+		if *flagWikiSpace == "" || *flagWikiToken == "" {
+			return nil, fmt.Errorf("-wiki confluence=... requires -wiki-space and -wiki-token")
+		}
+		return &wiki.Confluence{
+			URL:    strings.TrimPrefix(*flagWiki, "confluence="),
+			Space:  *flagWikiSpace,
+			Parent: *flagWikiParent,
+			User:   *flagWikiUser,
+			Token:  *flagWikiToken,
+			Client: client,
+		}, nil
+	case strings.HasPrefix(*flagWiki, "https://") || strings.HasPrefix(*flagWiki, "http://"):
+		return &wiki.Webhook{URL: *flagWiki, Client: client}, nil
+	}
+	return nil, fmt.Errorf("invalid -wiki %q, expected confluence=<URL> or a URL", *flagWiki)
+}
+
+// publish writes page to the wiki, if there is one, and records it in the
+// audit log. Like notifications, nothing is published in maintenance mode.
+// r is the request that triggered it, or nil for scheduled ones.
+func (s *Server) publish(ctx context.Context, r *http.Request, page wiki.Page) error {
+	if s.publisher == nil {
+		return nil
+	}
+	if inMaintenance() {
+		slog.Info("not publishing in maintenance mode", "title", page.Title)
+		return fmt.Errorf("publishing is frozen in maintenance mode")
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	if err := s.publisher.Publish(ctx, page); err != nil {
+		return fmt.Errorf("could not publish %q to %s: %v", page.Title, s.publisher.Name(), err)
+	}
+	slog.Info("published to wiki", "publisher", s.publisher.Name(), "title", page.Title)
+	s.audit.Record(r, "publish/"+s.publisher.Name(), page.Title)
+	return nil
+}
+
+// notifyAndPublish sends msg through the notifiers and publishes it to the
+// wiki, which archives it even when a notifier fails. Offices that only
+// archive don't need a notifier.
+func (s *Server) notifyAndPublish(ctx context.Context, r *http.Request, msg notify.Message) error {
+	var err error
+	if len(notifiers) > 0 || s.publisher == nil {
+		err = s.notifyAll(ctx, r, msg)
+	}
+	return errors.Join(err, s.publish(ctx, r, wiki.Page{Title: msg.Subject, Text: msg.Text}))
+}
+
+// publishToday publishes today's orders, the page lunchweb send publishes
+func (s *Server) publishToday(ctx context.Context) error {
+	oo, err := s.todaysOrders(ctx, slog.Default())
+	if err != nil {
+		return err
+	}
+	msg := s.ordersMessage(ctx, oo)
+	return s.publish(ctx, nil, wiki.Page{Title: msg.Subject, Text: msg.Text})
+}
+
+// runWiki publishes the day's orders every day at the time of day at
+func (s *Server) runWiki(ctx context.Context, at timeOfDay) {
+	s.runDaily(ctx, "wiki", at, nil, func(ctx context.Context) {
+		err := s.publishToday(ctx)
+		switch {
+		case errors.Is(err, sheet.ErrNoRow):
+			// weekends and holidays
+			slog.Info("not publishing to wiki, the sheet has no row for today")
+		case err != nil:
+			slog.Error("could not publish the day's orders", "err", err)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package wiki publishes pages, like the daily orders and the weekly digest,
+// to a wiki where they are archived.
+//
+//	p := &wiki.Confluence{URL: "https://example.atlassian.net/wiki", Space: "OPS", User: "bot@example.com", Token: token}
+//	err := p.Publish(ctx, wiki.Page{Title: "Order (2026-10-16)", Text: summary})
+package wiki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Page is a page to publish, its text plain with "- " list items
+type Page struct {
+	Title string
+	Text  string
+}
+
+// Publisher writes pages to a wiki, replacing the page with the same title
+type Publisher interface {
+	// Name identifies the publisher in logs and the audit log
+	Name() string
+	Publish(ctx context.Context, p Page) error
+}
+
+// HTML renders the text of p: paragraphs split by blank lines, and lists of
+// lines starting with "- "
+func (p Page) HTML() string {
+	var b strings.Builder
+	for _, paragraph := range strings.Split(strings.TrimSpace(p.Text), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(paragraph), "\n")
+		var items, text []string
+		for _, line := range lines {
+			if item, ok := strings.CutPrefix(line, "- "); ok {
+				items = append(items, item)
+			} else {
+				text = append(text, line)
+			}
+		}
+		// a list is usually introduced by a line like "Most ordered:"
+		if len(text) > 0 {
+			escaped := make([]string, len(text))
+			for i, line := range text {
+				escaped[i] = html.EscapeString(line)
+			}
+			fmt.Fprintf(&b, "<p>%s</p>", strings.Join(escaped, "<br/>"))
+		}
+		if len(items) > 0 {
+			b.WriteString("<ul>")
+			for _, item := range items {
+				fmt.Fprintf(&b, "<li>%s</li>", html.EscapeString(item))
+			}
+			b.WriteString("</ul>")
+		}
+	}
+	return b.String()
+}
+
+// Confluence publishes pages into a space of Confluence Cloud, Server or
+// Data Center
+type Confluence struct {
+	// URL is the base URL of Confluence, e.g.
+	// https://example.atlassian.net/wiki
+	URL   string
+	Space string
+	// Parent is the ID of the page to publish under, the space's home when
+	// empty
+	Parent string
+	// User and Token log in with an API token on Cloud; without User the
+	// token is a personal access token of Server or Data Center
+	User  string
+	Token string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (c *Confluence) Name() string { return "confluence" }
+
+// Publish creates the page or updates the one with its title
+func (c *Confluence) Publish(ctx context.Context, p Page) error {
+	var found struct {
+		Results []struct {
+			ID      string `json:"id"`
+			Version struct {
+				Number int `json:"number"`
+			} `json:"version"`
+		} `json:"results"`
+	}
+	query := url.Values{"spaceKey": {c.Space}, "title": {p.Title}, "expand": {"version"}}
+	if err := c.call(ctx, http.MethodGet, "/rest/api/content?"+query.Encode(), nil, &found); err != nil {
+		return err
+	}
+
+	page := map[string]interface{}{
+		"type":  "page",
+		"title": p.Title,
+		"space": map[string]string{"key": c.Space},
+		"body": map[string]interface{}{
+			"storage": map[string]string{"value": p.HTML(), "representation": "storage"},
+		},
+	}
+	if len(found.Results) == 0 {
+		if c.Parent != "" {
+			page["ancestors"] = []map[string]string{{"id": c.Parent}}
+		}
+		return c.call(ctx, http.MethodPost, "/rest/api/content", page, nil)
+	}
+	existing := found.Results[0]
+	page["id"] = existing.ID
+	page["version"] = map[string]int{"number": existing.Version.Number + 1}
+	return c.call(ctx, http.MethodPut, "/rest/api/content/"+url.PathEscape(existing.ID), page, nil)
+}
+
+// call sends body as JSON to the REST API at path and decodes the answer
+// into v
+func (c *Confluence) call(ctx context.Context, method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("confluence: %s %s: unexpected status %s", method, path, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("confluence: invalid response: %v", err)
+	}
+	return nil
+}
+
+// Webhook publishes to any other wiki through a hook of its own: it POSTs
+// the page as JSON, {"title": ..., "text": ..., "html": ...}
+type Webhook struct {
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+func (w *Webhook) Name() string { return "wiki-webhook" }
+
+func (w *Webhook) Publish(ctx context.Context, p Page) error {
+	body, err := json.Marshal(map[string]string{"title": p.Title, "text": p.Text, "html": p.HTML()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}